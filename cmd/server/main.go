@@ -1,43 +1,91 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
-
-	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
+	"os"
+	"os/signal"
+	"syscall"
 
 	api "your_project/internal/api/http"
 	ws "your_project/internal/api/ws"
+	"your_project/internal/config"
+	"your_project/internal/notify"
 	"your_project/internal/pkg/database"
+	"your_project/internal/pkg/shutdown"
+	"your_project/internal/pkg/throttle"
+	"your_project/internal/repository"
 )
 
 func main() {
-	godotenv.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db := database.Connect(cfg.Database)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(db, os.Args[2:])
+		return
+	}
+	warnPendingMigrations(db)
+	repos := repository.New(db)
 
-	database.Connect()
+	var notifier notify.Notifier = notify.Nop{}
+	if cfg.FCM.ServiceAccount != "" {
+		notifier = notify.NewFCM(repos.Users, cfg.FCM.ServiceAccount)
+	} else {
+		log.Println("FCM_SERVICE_ACCOUNT не задан, push-уведомления выключены")
+	}
 
-	// ФИX: инициализируем GlobalHub с подключением к БД
-	ws.InitHub(database.DB)
+	// Без REDIS_URL хаб работает в пределах одного процесса
+	var broker ws.Broker = ws.NewLocalBroker()
+	var throttleStore throttle.Store
+	if cfg.RedisURL != "" {
+		rdb := database.ConnectRedis(cfg.RedisURL)
+		broker = ws.NewRedisBroker(rdb)
+		// счётчики попыток входа общие для всех реплик
+		throttleStore = throttle.NewRedisStore(rdb)
+	}
 
-	r := mux.NewRouter()
+	srv := api.NewServer(cfg, repos, broker, notifier)
+	if throttleStore != nil {
+		srv.Guard.Close()
+		srv.Guard = throttle.NewGuard(throttleStore)
+	}
+	srv.StartBackground()
 
-	// CORS middleware
-	r.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			if r.Method == "OPTIONS" {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-			next.ServeHTTP(w, r)
-		})
-	})
+	httpServer := &http.Server{Addr: cfg.Addr, Handler: srv.Router()}
+	go func() {
+		log.Printf("Сервер запущен на %s (%s)", cfg.Addr, cfg.Env)
+		if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
 
-	api.RegisterRoutes(r)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+	log.Println("Остановка сервера...")
 
-	log.Println("Сервер запущен на :8080")
-	log.Fatal(http.ListenAndServe(":8080", r))
+	// Один тайм-аут на всю остановку: деплой не должен ждать вечно
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	// Сначала перестаём принимать соединения и ждём начатые HTTP-запросы,
+	// затем закрываем WebSocket и ждём их обработчики и push, потом брокер
+	// (с ним и клиент Redis, общий со счётчиками входа) и только потом БД
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Println("HTTP-запросы не завершились вовремя:", err)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("WebSocket-обработчики и push не завершились вовремя:", err)
+	}
+	if err := broker.Close(); err != nil {
+		log.Println("Ошибка закрытия брокера:", err)
+	}
+	if err := shutdown.Wait(ctx, func() { db.Close() }); err != nil {
+		log.Println("Пул БД не закрылся вовремя:", err)
+	}
+	log.Println("Сервер остановлен")
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+
+	"your_project/internal/pkg/migrate"
+	"your_project/migrations"
+)
+
+const migrateUsage = "использование: server migrate [up | down [N] | status]"
+
+// runMigrate — подкоманда `server migrate`
+func runMigrate(db *sql.DB, args []string) {
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		log.Fatal("Ошибка чтения миграций:", err)
+	}
+
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "up":
+		done, err := m.Up()
+		for _, mig := range done {
+			log.Printf("применена %03d_%s", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(done) == 0 {
+			log.Println("Схема актуальна")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatal(migrateUsage)
+			}
+		}
+		done, err := m.Down(steps)
+		for _, mig := range done {
+			log.Printf("откачена %03d_%s", mig.Version, mig.Name)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			state := "не применена"
+			if s.AppliedAt != nil {
+				state = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%03d_%-24s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		log.Fatal(migrateUsage)
+	}
+}
+
+// warnPendingMigrations напоминает при старте сервера о неприменённых миграциях
+func warnPendingMigrations(db *sql.DB) {
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		log.Println("Ошибка чтения миграций:", err)
+		return
+	}
+	n, err := m.Pending()
+	if err != nil {
+		log.Println("Не удалось проверить миграции:", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("Не применено миграций: %d, запусти `server migrate up`", n)
+	}
+}
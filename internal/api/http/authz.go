@@ -0,0 +1,25 @@
+package http
+
+import (
+	"log"
+	"net/http"
+
+	"your_project/internal/authz"
+)
+
+// writeAuthzError переводит отказ Authorizer в HTTP-ответ; код отказа в
+// X-Error-Code тот же, что WebSocket присылает в событии error
+func writeAuthzError(w http.ResponseWriter, err error) {
+	w.Header().Set("X-Error-Code", authz.Code(err))
+	switch err {
+	case authz.ErrNotMember:
+		http.Error(w, "Нет доступа", http.StatusForbidden)
+	case authz.ErrNotAdmin:
+		http.Error(w, "Нет прав", http.StatusForbidden)
+	case authz.ErrBlocked:
+		http.Error(w, "Пользователь заблокирован", http.StatusForbidden)
+	default:
+		log.Println("Ошибка проверки доступа:", err)
+		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
+	}
+}
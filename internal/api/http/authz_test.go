@@ -0,0 +1,74 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"your_project/internal/authz"
+)
+
+// httpCode — статус и X-Error-Code ответа
+func (e *testEnv) httpCode(method, path, token string, body interface{}) (int, string) {
+	e.t.Helper()
+	resp := e.request(method, path, token, body)
+	return resp.StatusCode, resp.Header.Get("X-Error-Code")
+}
+
+// wsCode отправляет запрос и возвращает code пришедшего в ответ error
+func wsCode(t *testing.T, conn *websocket.Conn, request map[string]interface{}) string {
+	t.Helper()
+	send(t, conn, request)
+	got := readType(t, conn, "error")
+	if got["request_type"] != request["type"] {
+		t.Fatalf("error на %v, ждали на %v", got["request_type"], request["type"])
+	}
+	code, _ := got["code"].(string)
+	return code
+}
+
+// Один и тот же отказ Authorizer приходит с одинаковым кодом по HTTP и WebSocket
+func TestAuthzErrorCodesMatch(t *testing.T) {
+	e := newTestEnv(t)
+	aliceToken, aliceID := e.user("alice")
+	bobToken, bobID := e.user("bob")
+	eveToken, _ := e.user("eve")
+	conv := e.conversation(aliceToken, bobID)
+	group := e.group(aliceToken, bobID)
+	alice, eve := e.dial(aliceToken), e.dial(eveToken)
+
+	send(t, alice, map[string]interface{}{"type": "group_message", "group_id": group, "content": "x"})
+	groupMsg := int(readType(t, alice, "group_message")["message_id"].(float64))
+
+	check := func(name string, status int, httpCode, wsCode, want string) {
+		t.Helper()
+		if status != http.StatusForbidden || httpCode != want || wsCode != want {
+			t.Errorf("%s: HTTP %d %q, WS %q, ждали 403 %q", name, status, httpCode, wsCode, want)
+		}
+	}
+
+	// посторонний и диалог
+	status, hc := e.httpCode("GET", fmt.Sprintf("/api/messages?conversation_id=%d", conv), eveToken, nil)
+	wc := wsCode(t, eve, map[string]interface{}{"type": "message", "conversation_id": conv, "content": "x"})
+	check("посторонний в диалоге", status, hc, wc, authz.CodeForbidden)
+
+	// не участник и группа
+	status, hc = e.httpCode("GET", fmt.Sprintf("/api/groups/messages?group_id=%d", group), eveToken, nil)
+	wc = wsCode(t, eve, map[string]interface{}{"type": "group_message", "group_id": group, "content": "x"})
+	check("не участник группы", status, hc, wc, authz.CodeForbidden)
+
+	// не админ: закреплять в группе может только админ, по WebSocket таких
+	// действий нет, поэтому код сверяется с тем, что отдал бы sendAuthzError
+	status, hc = e.httpCode("POST", "/api/messages/pin", bobToken, map[string]int{"message_id": groupMsg, "group_id": group})
+	check("не админ группы", status, hc, authz.Code(authz.ErrNotAdmin), authz.CodeForbidden)
+
+	// заблокированный отправляет: пересылка по HTTP и сообщение по WebSocket
+	e.do("POST", "/api/users/block", bobToken, map[string]int{"blocked_user_id": aliceID}, http.StatusOK, nil)
+	status, hc = e.httpCode("POST", "/api/messages/forward", aliceToken, map[string]interface{}{
+		"message_ids": []int{groupMsg}, "source_group_id": group, "conversation_id": conv,
+	})
+	wc = wsCode(t, alice, map[string]interface{}{"type": "message", "conversation_id": conv, "content": "x"})
+	check("заблокированный", status, hc, wc, authz.CodeBlocked)
+}
@@ -0,0 +1,260 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"your_project/internal/middleware"
+	"your_project/internal/pkg/auth"
+	"your_project/internal/pkg/mailer"
+	"your_project/internal/pkg/throttle"
+	"your_project/internal/repository"
+)
+
+const (
+	verifyTokenTTL = 24 * time.Hour
+	resetTokenTTL  = time.Hour
+)
+
+// normalizeEmail принимает только голый адрес (без имени) и приводит его к нижнему регистру
+func normalizeEmail(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s || len(s) > 255 {
+		return "", false
+	}
+	return strings.ToLower(s), true
+}
+
+// emailLink — ссылка на страницу клиента с токеном; без APP_URL в письме
+// остаётся только сам токен
+func (s *Server) emailLink(path, token string) string {
+	if s.Config.AppURL == "" {
+		return token
+	}
+	return fmt.Sprintf("%s%s?token=%s", s.Config.AppURL, path, token)
+}
+
+// sendEmailToken выпускает одноразовый токен и отправляет его письмом
+func (s *Server) sendEmailToken(userID int, purpose, email string, ttl time.Duration, subject, text, path string) error {
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return err
+	}
+	repo := s.Repos.EmailTokens
+	if err := repo.Create(userID, purpose, email, hash, time.Now().Add(ttl)); err != nil {
+		return err
+	}
+	return s.Mailer.Send(mailer.Message{
+		To:      email,
+		Subject: subject,
+		Body:    text + "\n\n" + s.emailLink(path, token) + "\n\nЕсли это были не вы, просто проигнорируйте письмо.",
+	})
+}
+
+// sendVerificationEmail не прерывает запрос при ошибке почты:
+// письмо можно запросить повторно через /api/email/resend
+func (s *Server) sendVerificationEmail(userID int, email string) {
+	err := s.sendEmailToken(userID, repository.TokenVerifyEmail, email, verifyTokenTTL,
+		"Подтвердите email в Elowy",
+		"Чтобы подтвердить адрес, перейдите по ссылке (действует 24 часа):",
+		"/verify-email",
+	)
+	if err != nil {
+		log.Printf("Ошибка отправки письма подтверждения для user %d: %v", userID, err)
+	}
+}
+
+// POST /api/email/update {email, password} — сменить адрес; новый нужно подтвердить
+func (s *Server) UpdateEmail(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		http.Error(w, "Неверный email", http.StatusBadRequest)
+		return
+	}
+	users := s.Repos.Users
+	user, err := users.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Пользователь не найден", http.StatusNotFound)
+		return
+	}
+	// адрес — это способ восстановить доступ, поэтому без пароля его не меняем
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) != nil {
+		http.Error(w, "Неверный пароль", http.StatusUnauthorized)
+		return
+	}
+	// занятость адреса здесь не сообщается, иначе по ответу можно перебирать
+	// зарегистрированные адреса: её проверит подтверждение из письма
+	if err := users.SetEmail(userID, email); err != nil {
+		http.Error(w, "Ошибка сохранения email", http.StatusInternalServerError)
+		return
+	}
+	tokens := s.Repos.EmailTokens
+	tokens.InvalidateAll(userID, repository.TokenVerifyEmail)
+	s.sendVerificationEmail(userID, email)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Письмо для подтверждения отправлено"})
+}
+
+// POST /api/email/resend — повторить письмо подтверждения
+func (s *Server) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	key := mailKey("verify:user:", fmt.Sprint(userID))
+	if wait, locked := s.Guard.Check(key); wait > 0 {
+		tooManyAttempts(w, wait, locked)
+		return
+	}
+	users := s.Repos.Users
+	user, err := users.GetProfile(userID)
+	if err != nil {
+		http.Error(w, "Пользователь не найден", http.StatusNotFound)
+		return
+	}
+	email := user.Email
+	if email == "" {
+		http.Error(w, "Email не указан", http.StatusBadRequest)
+		return
+	}
+	if user.EmailVerified {
+		http.Error(w, "Email уже подтверждён", http.StatusConflict)
+		return
+	}
+	s.Guard.Fail(key)
+	s.sendVerificationEmail(userID, email)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Письмо для подтверждения отправлено"})
+}
+
+// POST /api/email/verify {token} — переход по ссылке из письма, без авторизации
+func (s *Server) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	tokens := s.Repos.EmailTokens
+	userID, email, err := tokens.Consume(auth.HashToken(req.Token), repository.TokenVerifyEmail)
+	if err == repository.ErrTokenInvalid {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Ошибка подтверждения", http.StatusInternalServerError)
+		return
+	}
+	users := s.Repos.Users
+	ok, err := users.MarkEmailVerified(userID, email)
+	if err == repository.ErrEmailTaken {
+		// это узнаёт только владелец ящика, получивший письмо
+		http.Error(w, "Адрес уже подтверждён другим аккаунтом", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Ошибка подтверждения", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		// адрес успели сменить после отправки письма
+		http.Error(w, repository.ErrTokenInvalid.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "Email подтверждён"})
+}
+
+// POST /api/password/forgot {email} — ответ одинаковый независимо от того,
+// есть ли такой адрес, чтобы по нему нельзя было перебирать пользователей
+func (s *Server) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	email, ok := normalizeEmail(req.Email)
+	if !ok {
+		http.Error(w, "Неверный email", http.StatusBadRequest)
+		return
+	}
+	keys := []throttle.Key{
+		mailKey("forgot:ip:", s.clientIP(r)),
+		mailKey("forgot:email:", email),
+	}
+	if wait, locked := s.Guard.Check(keys...); wait > 0 {
+		tooManyAttempts(w, wait, locked)
+		return
+	}
+	s.Guard.Fail(keys...)
+
+	users := s.Repos.Users
+	if user, err := users.GetUserByVerifiedEmail(email); err == nil {
+		err = s.sendEmailToken(user.ID, repository.TokenResetPassword, user.Email, resetTokenTTL,
+			"Сброс пароля в Elowy",
+			"Для аккаунта "+user.Username+" запрошен сброс пароля. Ссылка действует 1 час:",
+			"/reset-password",
+		)
+		if err != nil {
+			log.Printf("Ошибка отправки письма сброса пароля для user %d: %v", user.ID, err)
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Если адрес зарегистрирован и подтверждён, письмо отправлено"})
+}
+
+// POST /api/password/reset {token, new_password} — новый пароль по ссылке из
+// письма. Все сессии пользователя завершаются.
+func (s *Server) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	if len(req.NewPassword) < 6 {
+		http.Error(w, "Пароль должен быть не менее 6 символов", http.StatusBadRequest)
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Ошибка хеширования пароля", http.StatusInternalServerError)
+		return
+	}
+	tokens := s.Repos.EmailTokens
+	userID, _, err := tokens.Consume(auth.HashToken(req.Token), repository.TokenResetPassword)
+	if err == repository.ErrTokenInvalid {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Ошибка сброса пароля", http.StatusInternalServerError)
+		return
+	}
+	users := s.Repos.Users
+	if err := users.SetPassword(userID, string(hash)); err != nil {
+		http.Error(w, "Ошибка сброса пароля", http.StatusInternalServerError)
+		return
+	}
+	tokens.InvalidateAll(userID, repository.TokenResetPassword)
+	if err := s.revokeOtherSessions(userID, ""); err != nil {
+		log.Printf("Ошибка отзыва сессий user %d после сброса пароля: %v", userID, err)
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "Пароль изменён, войдите заново"})
+}
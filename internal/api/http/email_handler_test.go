@@ -0,0 +1,79 @@
+package http
+
+import (
+	"net/http"
+	"regexp"
+	"sync"
+	"testing"
+
+	"your_project/internal/pkg/mailer"
+)
+
+// captureMailer запоминает письма вместо отправки
+type captureMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *captureMailer) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+var linkToken = regexp.MustCompile(`token=(\S+)`)
+
+// lastToken — токен из последнего письма на адрес to
+func (m *captureMailer) lastToken(t *testing.T, to string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.sent) - 1; i >= 0; i-- {
+		if m.sent[i].To == to {
+			if match := linkToken.FindStringSubmatch(m.sent[i].Body); match != nil {
+				return match[1]
+			}
+		}
+	}
+	t.Fatalf("нет письма с токеном на %s", to)
+	return ""
+}
+
+// Неподтверждённый адрес не занимает email: ни регистрация, ни смена адреса
+// не отвечают иначе, если он уже чей-то, а занять его можно только подтвердив
+func TestEmailUniquenessOnlyForVerified(t *testing.T) {
+	e := newTestEnv(t)
+	e.s.Config.AppURL = "https://app.example"
+	mail := &captureMailer{}
+	e.s.Mailer = mail
+	const email = "victim@example.com"
+
+	// захватчик указывает чужой адрес первым
+	e.do("POST", "/api/register", "", map[string]string{
+		"username": "squatter", "password": "secret123", "email": email,
+	}, http.StatusCreated, nil)
+	squatterToken := mail.lastToken(t, email)
+
+	// владелец регистрируется с тем же адресом и подтверждает его
+	e.do("POST", "/api/register", "", map[string]string{
+		"username": "victim", "password": "secret123", "email": email,
+	}, http.StatusCreated, nil)
+	e.do("POST", "/api/email/verify", "", map[string]string{"token": mail.lastToken(t, email)}, http.StatusOK, nil)
+
+	// заявка захватчика снята, его письмо больше не действует
+	e.do("POST", "/api/email/verify", "", map[string]string{"token": squatterToken}, http.StatusBadRequest, nil)
+
+	// смена адреса на подтверждённый чужой отвечает так же, как на свободный
+	token, _ := e.user("other")
+	var taken, free map[string]string
+	e.do("POST", "/api/email/update", token, map[string]string{"email": email, "password": "secret123"}, http.StatusOK, &taken)
+	e.do("POST", "/api/email/update", token, map[string]string{"email": "free@example.com", "password": "secret123"}, http.StatusOK, &free)
+	if taken["message"] != free["message"] {
+		t.Fatalf("ответы различаются: %v и %v", taken, free)
+	}
+
+	// подтвердить чужой подтверждённый адрес нельзя
+	e.do("POST", "/api/email/update", token, map[string]string{"email": email, "password": "secret123"}, http.StatusOK, nil)
+	e.do("POST", "/api/email/verify", "", map[string]string{"token": mail.lastToken(t, email)}, http.StatusConflict, nil)
+}
@@ -4,16 +4,12 @@ import (
 	"encoding/json"
 	"net/http"
 
-	"your_project/internal/pkg/database"
+	"your_project/internal/middleware"
 )
 
 // POST /api/fcm/token — сохраняем FCM токен пользователя
-func SaveFcmToken(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
+func (s *Server) SaveFcmToken(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
 	var req struct {
 		FcmToken string `json:"fcm_token"`
 	}
@@ -21,11 +17,8 @@ func SaveFcmToken(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	_, err = database.DB.Exec(
-		`UPDATE users SET fcm_token = $1 WHERE id = $2`,
-		req.FcmToken, userID,
-	)
-	if err != nil {
+	repo := s.Repos.Users
+	if err := repo.SetFCMToken(userID, req.FcmToken); err != nil {
 		http.Error(w, "DB error", http.StatusInternalServerError)
 		return
 	}
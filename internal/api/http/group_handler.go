@@ -1,57 +1,48 @@
 package http
 
 import (
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strconv"
 
-	"your_project/internal/pkg/database"
+	"your_project/internal/authz"
+	"your_project/internal/middleware"
+	"your_project/internal/models"
+	"your_project/internal/repository"
 )
 
 // Создать группу
-func CreateGroup(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
-	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
+func (s *Server) CreateGroup(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
 
 	var body struct {
 		Name      string `json:"name"`
 		AvatarURL string `json:"avatar_url"`
 		MemberIDs []int  `json:"member_ids"`
 	}
-	json.NewDecoder(r.Body).Decode(&body)
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
 
 	if body.Name == "" {
 		http.Error(w, "Название обязательно", http.StatusBadRequest)
 		return
 	}
 
-	var groupID int
-	err = database.DB.QueryRow(
-		`INSERT INTO group_chats (name, avatar_url, created_by) VALUES ($1, $2, $3) RETURNING id`,
-		body.Name, body.AvatarURL, userID,
-	).Scan(&groupID)
+	groupID, joined, err := s.Repos.Groups.Create(body.Name, body.AvatarURL, userID, body.MemberIDs)
 	if err != nil {
 		http.Error(w, "Ошибка создания группы", http.StatusInternalServerError)
 		return
 	}
 
-	// Добавляем создателя как админа
-	database.DB.Exec(
-		`INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'admin')`,
-		groupID, userID,
-	)
-
-	// Добавляем остальных участников
-	for _, memberID := range body.MemberIDs {
-		if memberID != userID {
-			database.DB.Exec(
-				`INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'member')`,
-				groupID, memberID,
-			)
-		}
+	// Каждому участнику — событие о вступлении в ленту синхронизации
+	for _, memberID := range joined {
+		data, _ := json.Marshal(models.MembershipEvent{
+			Type: "group_membership", GroupID: groupID, UserID: memberID, Action: "added",
+		})
+		s.Hub.Emit(memberID, "group_membership", data)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -59,43 +50,16 @@ func CreateGroup(w http.ResponseWriter, r *http.Request) {
 }
 
 // Список групп пользователя
-func GetGroups(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
-	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
+func (s *Server) GetGroups(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
 
-	rows, err := database.DB.Query(`
-		SELECT g.id, g.name, COALESCE(g.avatar_url,''),
-			COALESCE((SELECT content FROM group_messages WHERE group_id = g.id ORDER BY created_at DESC LIMIT 1), '') as last_message,
-			g.created_by
-		FROM group_chats g
-		JOIN group_members gm ON g.id = gm.group_id
-		WHERE gm.user_id = $1
-		ORDER BY g.created_at DESC`, userID)
+	groups, err := s.Repos.Groups.ListForUser(userID)
 	if err != nil {
 		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-
-	type Group struct {
-		ID          int    `json:"id"`
-		Name        string `json:"name"`
-		AvatarURL   string `json:"avatar_url"`
-		LastMessage string `json:"last_message"`
-		CreatedBy   int    `json:"created_by"`
-	}
-
-	var groups []Group
-	for rows.Next() {
-		var g Group
-		rows.Scan(&g.ID, &g.Name, &g.AvatarURL, &g.LastMessage, &g.CreatedBy)
-		groups = append(groups, g)
-	}
 	if groups == nil {
-		groups = []Group{}
+		groups = []models.GroupSummary{}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -103,204 +67,254 @@ func GetGroups(w http.ResponseWriter, r *http.Request) {
 }
 
 // Сообщения группы
-func GetGroupMessages(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
-	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
+func (s *Server) GetGroupMessages(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
 
 	groupIDStr := r.URL.Query().Get("group_id")
 	groupID, _ := strconv.Atoi(groupIDStr)
 
-	// Проверяем что пользователь в группе
-	var count int
-	database.DB.QueryRow(
-		`SELECT COUNT(*) FROM group_members WHERE group_id=$1 AND user_id=$2`,
-		groupID, userID,
-	).Scan(&count)
-	if count == 0 {
-		http.Error(w, "Нет доступа", http.StatusForbidden)
+	if err := s.Authz.CanReadGroup(userID, groupID); err != nil {
+		writeAuthzError(w, err)
 		return
 	}
 
-	rows, err := database.DB.Query(`
-		SELECT gm.id, gm.group_id, gm.sender_id, u.username,
-			gm.content, COALESCE(gm.media_url,''), COALESCE(gm.media_type,''), gm.created_at
-		FROM group_messages gm
-		JOIN users u ON gm.sender_id = u.id
-		WHERE gm.group_id = $1
-		ORDER BY gm.created_at ASC`, groupID)
-	if err != nil {
-		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
+	repo := s.Repos.Messages
+
+	if page, ok := parsePage(r); ok {
+		result, err := repo.GetGroupMessagesPage(groupID, page)
+		if err == nil {
+			err = attachGroupReactions(s.Repos, userID, result.Messages)
+		}
+		if err != nil {
+			http.Error(w, "Ошибка БД", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
 		return
 	}
-	defer rows.Close()
 
-	type GroupMessage struct {
-		ID             int    `json:"id"`
-		GroupID        int    `json:"group_id"`
-		SenderID       int    `json:"sender_id"`
-		SenderUsername string `json:"sender_username"`
-		Content        string `json:"content"`
-		MediaURL       string `json:"media_url"`
-		MediaType      string `json:"media_type"`
-		CreatedAt      string `json:"created_at"`
+	msgs, err := repo.GetGroupMessages(groupID)
+	if err == nil {
+		err = attachGroupReactions(s.Repos, userID, msgs)
 	}
-
-	var msgs []GroupMessage
-	for rows.Next() {
-		var m GroupMessage
-		rows.Scan(&m.ID, &m.GroupID, &m.SenderID, &m.SenderUsername,
-			&m.Content, &m.MediaURL, &m.MediaType, &m.CreatedAt)
-		msgs = append(msgs, m)
+	if err != nil {
+		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
+		return
 	}
 	if msgs == nil {
-		msgs = []GroupMessage{}
+		msgs = []models.GroupMessage{}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(msgs)
 }
 
-// Информация о группе с участниками
-func GetGroupInfo(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
+// Ветка: корень и страница ответов (before_id/after_id/around_id/limit;
+// без курсора — последние ответы)
+func (s *Server) GetGroupThread(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	groupID, _ := strconv.Atoi(r.URL.Query().Get("group_id"))
+	rootID, _ := strconv.Atoi(r.URL.Query().Get("root_id"))
+
+	if err := s.Authz.CanReadGroup(userID, groupID); err != nil {
+		writeAuthzError(w, err)
+		return
+	}
+
+	page, _ := parsePage(r)
+	result, err := s.Repos.Messages.GetThreadPage(groupID, rootID, page)
+	if err == repository.ErrThreadNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
+		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
+		return
+	}
+	// корень и ответы — одним запросом сводки
+	all := append([]models.GroupMessage{result.Root}, result.Messages...)
+	if err := attachGroupReactions(s.Repos, userID, all); err != nil {
+		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
 		return
 	}
+	result.Root, result.Messages = all[0], all[1:]
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// Информация о группе с участниками
+func (s *Server) GetGroupInfo(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
 
 	groupIDStr := r.URL.Query().Get("group_id")
 	groupID, _ := strconv.Atoi(groupIDStr)
 
-	var count int
-	database.DB.QueryRow(
-		`SELECT COUNT(*) FROM group_members WHERE group_id=$1 AND user_id=$2`,
-		groupID, userID,
-	).Scan(&count)
-	if count == 0 {
-		http.Error(w, "Нет доступа", http.StatusForbidden)
+	if err := s.Authz.CanReadGroup(userID, groupID); err != nil {
+		writeAuthzError(w, err)
 		return
 	}
 
-	type Member struct {
-		ID       int    `json:"id"`
-		Username string `json:"username"`
-		Role     string `json:"role"`
-		Avatar   string `json:"avatar_url"`
+	info, err := s.Repos.Groups.Get(groupID)
+	if err != nil {
+		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
+		return
+	}
+	pins, err := s.Repos.Pins.Latest(models.ChatGroup, []int{groupID})
+	if err != nil {
+		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
+		return
 	}
+	if pin, ok := pins[groupID]; ok {
+		info.PinnedMessage = &pin
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
 
-	type GroupInfo struct {
-		ID        int      `json:"id"`
-		Name      string   `json:"name"`
-		AvatarURL string   `json:"avatar_url"`
-		CreatedBy int      `json:"created_by"`
-		Members   []Member `json:"members"`
+// Участники группы: сначала админы, затем по имени
+func (s *Server) GetGroupMembers(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	groupID, err := strconv.Atoi(r.URL.Query().Get("group_id"))
+	if err != nil {
+		http.Error(w, "Неверный ID группы", http.StatusBadRequest)
+		return
 	}
 
-	var info GroupInfo
-	database.DB.QueryRow(
-		`SELECT id, name, COALESCE(avatar_url,''), created_by FROM group_chats WHERE id=$1`,
-		groupID,
-	).Scan(&info.ID, &info.Name, &info.AvatarURL, &info.CreatedBy)
+	if err := s.Authz.CanReadGroup(userID, groupID); err != nil {
+		writeAuthzError(w, err)
+		return
+	}
 
-	rows, _ := database.DB.Query(`
-		SELECT u.id, u.username, gm.role, COALESCE(u.avatar_url,'')
-		FROM group_members gm
-		JOIN users u ON gm.user_id = u.id
-		WHERE gm.group_id = $1`, groupID)
-	defer rows.Close()
-	for rows.Next() {
-		var m Member
-		rows.Scan(&m.ID, &m.Username, &m.Role, &m.Avatar)
-		info.Members = append(info.Members, m)
+	members, err := s.Repos.Groups.Members(groupID)
+	if err != nil {
+		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
+		return
+	}
+	if members == nil {
+		members = []models.GroupMember{}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(info)
+	json.NewEncoder(w).Encode(members)
 }
 
-// Обновить группу
-func UpdateGroup(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
+// Кто получил и прочитал сообщение группы: «прочитано N из M»
+func (s *Server) GetGroupMessageReceipts(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	groupID, _ := strconv.Atoi(r.URL.Query().Get("group_id"))
+	messageID, _ := strconv.Atoi(r.URL.Query().Get("message_id"))
+
+	if err := s.Authz.CanReadGroup(userID, groupID); err != nil {
+		writeAuthzError(w, err)
+		return
+	}
+
+	repo := s.Repos.Receipts
+	receipt, err := repo.GetGroupReceipt(groupID, messageID)
+	if err == repository.ErrMessageNotFound {
+		http.Error(w, "Сообщение не найдено", http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
+		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipt)
+}
+
+// Обновить группу
+func (s *Server) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
 	var body struct {
 		GroupID   int    `json:"group_id"`
 		Name      string `json:"name"`
 		AvatarURL string `json:"avatar_url"`
 	}
-	json.NewDecoder(r.Body).Decode(&body)
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.GroupID == 0 {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
 
-	// Проверяем что пользователь админ
-	var role string
-	database.DB.QueryRow(
-		`SELECT role FROM group_members WHERE group_id=$1 AND user_id=$2`,
-		body.GroupID, userID,
-	).Scan(&role)
-	if role != "admin" {
-		http.Error(w, "Нет прав", http.StatusForbidden)
+	if err := s.Authz.RequireGroupAdmin(userID, body.GroupID); err != nil {
+		writeAuthzError(w, err)
 		return
 	}
 
-	database.DB.Exec(
-		`UPDATE group_chats SET name=$1, avatar_url=$2 WHERE id=$3`,
-		body.Name, body.AvatarURL, body.GroupID,
-	)
+	if err := s.Repos.Groups.Update(body.GroupID, body.Name, body.AvatarURL); err != nil {
+		http.Error(w, "Ошибка обновления", http.StatusInternalServerError)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Обновлено"})
 }
 
 // Добавить участника
-func AddGroupMember(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
-	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
+func (s *Server) AddGroupMember(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
 
 	var body struct {
 		GroupID  int `json:"group_id"`
 		MemberID int `json:"member_id"`
 	}
-	json.NewDecoder(r.Body).Decode(&body)
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.GroupID == 0 || body.MemberID == 0 {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
 
-	var role string
-	database.DB.QueryRow(
-		`SELECT role FROM group_members WHERE group_id=$1 AND user_id=$2`,
-		body.GroupID, userID,
-	).Scan(&role)
-	if role != "admin" {
-		http.Error(w, "Нет прав", http.StatusForbidden)
+	if err := s.Authz.RequireGroupAdmin(userID, body.GroupID); err != nil {
+		writeAuthzError(w, err)
+		return
+	}
+	// у удалённого аккаунта пароль стёрт: добавлять его так же нельзя
+	member, err := s.Repos.Users.GetUserByID(body.MemberID)
+	if err == sql.ErrNoRows || err == nil && member.Password == "" {
+		http.Error(w, "Пользователь не найден", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
+		return
+	}
+	// добавить в группу — всё равно что написать: блокировка мешает, как в личке
+	if err := s.Authz.CanContact(userID, body.MemberID); err != nil {
+		writeAuthzError(w, err)
 		return
 	}
 
-	database.DB.Exec(
-		`INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'member') ON CONFLICT DO NOTHING`,
-		body.GroupID, body.MemberID,
-	)
+	added, err := s.Repos.Groups.AddMember(body.GroupID, body.MemberID, authz.RoleMember)
+	if err != nil {
+		http.Error(w, "Ошибка добавления", http.StatusInternalServerError)
+		return
+	}
+	if added {
+		s.Hub.EmitMembership(body.GroupID, body.MemberID, "added")
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
 // Удалить участника / покинуть группу
-func RemoveGroupMember(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
-	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
+func (s *Server) RemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
 
 	var body struct {
 		GroupID  int `json:"group_id"`
 		MemberID int `json:"member_id"`
 	}
-	json.NewDecoder(r.Body).Decode(&body)
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.GroupID == 0 {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
 
 	targetID := body.MemberID
 	if targetID == 0 {
@@ -308,21 +322,24 @@ func RemoveGroupMember(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if targetID != userID {
-		var role string
-		database.DB.QueryRow(
-			`SELECT role FROM group_members WHERE group_id=$1 AND user_id=$2`,
-			body.GroupID, userID,
-		).Scan(&role)
-		if role != "admin" {
-			http.Error(w, "Нет прав", http.StatusForbidden)
+		if err := s.Authz.RequireGroupAdmin(userID, body.GroupID); err != nil {
+			writeAuthzError(w, err)
 			return
 		}
 	}
 
-	database.DB.Exec(
-		`DELETE FROM group_members WHERE group_id=$1 AND user_id=$2`,
-		body.GroupID, targetID,
-	)
+	// ушёл последний админ — права уже переданы старейшему участнику
+	removed, newAdminID, err := s.Repos.Groups.RemoveMember(body.GroupID, targetID)
+	if err != nil {
+		http.Error(w, "Ошибка удаления", http.StatusInternalServerError)
+		return
+	}
+	if removed {
+		s.Hub.EmitMembership(body.GroupID, targetID, "removed")
+	}
+	if newAdminID != 0 {
+		s.Hub.EmitMembership(body.GroupID, newAdminID, "promoted")
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
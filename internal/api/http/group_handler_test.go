@@ -0,0 +1,104 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"your_project/internal/authz"
+)
+
+// Битое тело — 400, а не действие по нулевым полям: раньше RemoveGroupMember
+// с нечитаемым телом выводил участника из группы
+func TestGroupHandlersRejectBadBody(t *testing.T) {
+	e := newTestEnv(t)
+	adminToken, _ := e.user("admin")
+	memberToken, memberID := e.user("member")
+	group := e.group(adminToken, memberID)
+
+	for _, path := range []string{
+		"/api/groups/create",
+		"/api/groups/update",
+		"/api/groups/members/add",
+		"/api/groups/members/remove",
+	} {
+		req, err := http.NewRequest("POST", e.ts.URL+path, strings.NewReader(`{"group_id":`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+memberToken)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s с битым телом: %d, ждали 400", path, resp.StatusCode)
+		}
+	}
+
+	e.do("POST", "/api/groups/members/add", adminToken, map[string]int{"member_id": memberID}, http.StatusBadRequest, nil)
+	e.do("POST", "/api/groups/members/add", adminToken, map[string]int{"group_id": group}, http.StatusBadRequest, nil)
+
+	var groups []struct {
+		ID int `json:"id"`
+	}
+	e.do("GET", "/api/groups", memberToken, nil, http.StatusOK, &groups)
+	if len(groups) != 1 || groups[0].ID != group {
+		t.Fatalf("участник после битых запросов в группах %+v", groups)
+	}
+
+	// явный выход без member_id по-прежнему работает
+	e.do("POST", "/api/groups/members/remove", memberToken, map[string]int{"group_id": group}, http.StatusOK, nil)
+	e.do("GET", "/api/groups", memberToken, nil, http.StatusOK, &groups)
+	if len(groups) != 0 {
+		t.Fatalf("после выхода группы участника %+v", groups)
+	}
+}
+
+// Добавить можно только существующий аккаунт и только если между админом
+// и ним нет блокировки
+func TestAddGroupMemberChecks(t *testing.T) {
+	e := newTestEnv(t)
+	adminToken, adminID := e.user("admin")
+	blockerToken, blockerID := e.user("blocker")
+	_, goneID := e.user("gone")
+	group := e.group(adminToken)
+
+	add := func(memberID int) (int, string) {
+		return e.httpCode("POST", "/api/groups/members/add", adminToken, map[string]int{"group_id": group, "member_id": memberID})
+	}
+	if status, _ := add(999999); status != http.StatusNotFound {
+		t.Fatalf("несуществующий участник: %d, ждали 404", status)
+	}
+	if err := e.s.Repos.Users.SoftDelete(goneID); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := add(goneID); status != http.StatusNotFound {
+		t.Fatalf("удалённый аккаунт: %d, ждали 404", status)
+	}
+	e.do("POST", "/api/users/block", blockerToken, map[string]int{"blocked_user_id": adminID}, http.StatusOK, nil)
+	if status, code := add(blockerID); status != http.StatusForbidden || code != authz.CodeBlocked {
+		t.Fatalf("заблокировавший админа: %d %q", status, code)
+	}
+}
+
+// Последний админ может выйти: права получает вошедший раньше остальных
+func TestLastAdminLeaveHandsOver(t *testing.T) {
+	e := newTestEnv(t)
+	adminToken, adminID := e.user("admin")
+	memberToken, memberID := e.user("member")
+	_, otherID := e.user("other")
+	group := e.group(adminToken, memberID, otherID)
+	member := e.dial(memberToken)
+
+	e.do("POST", "/api/groups/members/remove", adminToken, map[string]int{"group_id": group}, http.StatusOK, nil)
+	if got := readType(t, member, "group_membership"); got["action"] != "removed" || got["user_id"] != float64(adminID) {
+		t.Fatalf("событие выхода = %v", got)
+	}
+	if got := readType(t, member, "group_membership"); got["action"] != "promoted" || got["user_id"] != float64(memberID) {
+		t.Fatalf("событие передачи прав = %v", got)
+	}
+	// новый админ управляет группой
+	e.do("POST", "/api/groups/members/remove", memberToken, map[string]int{"group_id": group, "member_id": otherID}, http.StatusOK, nil)
+}
@@ -3,18 +3,15 @@ package http
 import (
 	"encoding/json"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
-	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/websocket"
 	"golang.org/x/crypto/bcrypt"
 
 	ws "your_project/internal/api/ws"
+	"your_project/internal/middleware"
 	"your_project/internal/models"
-	"your_project/internal/pkg/database"
 	"your_project/internal/repository"
 )
 
@@ -22,7 +19,22 @@ var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
-func RegisterUser(w http.ResponseWriter, r *http.Request) {
+// dummyPasswordHash сверяется с паролем, когда пользователя нет или его
+// аккаунт удалён: bcrypt выполняется всегда, и по времени ответа нельзя
+// узнать, существует ли имя
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// checkLoginPassword сверяет пароль за одно и то же время для любого user
+func checkLoginPassword(user *models.User, password string) bool {
+	hash := []byte(user.Password)
+	if user.ID == 0 || user.Password == "" {
+		hash = dummyPasswordHash
+	}
+	ok := bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+	return ok && user.ID != 0 && user.Password != ""
+}
+
+func (s *Server) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	var req models.RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
@@ -32,70 +44,106 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Имя пользователя и пароль обязательны", http.StatusBadRequest)
 		return
 	}
+	if strings.HasPrefix(strings.ToLower(req.Username), "deleted_") {
+		http.Error(w, "Недопустимое имя пользователя", http.StatusBadRequest)
+		return
+	}
+	if req.Email != "" {
+		email, ok := normalizeEmail(req.Email)
+		if !ok {
+			http.Error(w, "Неверный email", http.StatusBadRequest)
+			return
+		}
+		req.Email = email
+	}
+	regKey := registerKey(s.clientIP(r))
+	if wait, locked := s.Guard.Check(regKey); wait > 0 {
+		tooManyAttempts(w, wait, locked)
+		return
+	}
+	s.Guard.Fail(regKey)
 	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		http.Error(w, "Ошибка хеширования пароля", http.StatusInternalServerError)
 		return
 	}
-	repo := repository.UserRepository{DB: database.DB}
+	repo := s.Repos.Users
 	user := models.User{
 		Username:    req.Username,
 		Password:    string(hash),
 		DisplayName: req.DisplayName,
 		UserTag:     req.UserTag,
+		Email:       req.Email,
 	}
-	if err := repo.CreateUser(user); err != nil {
-		http.Error(w, "Пользователь уже существует", http.StatusConflict)
+	// email уникален только подтверждённый, поэтому конфликт здесь — только
+	// по имени, и ответ не выдаёт, зарегистрирован ли адрес
+	userID, err := repo.CreateUser(user)
+	if err != nil {
+		http.Error(w, "Имя пользователя занято", http.StatusConflict)
 		return
 	}
+	if req.Email != "" {
+		s.sendVerificationEmail(userID, req.Email)
+	}
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{"message": "Пользователь создан"})
 }
 
-func LoginUser(w http.ResponseWriter, r *http.Request) {
+func (s *Server) LoginUser(w http.ResponseWriter, r *http.Request) {
 	var req models.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
 		return
 	}
-	repo := repository.UserRepository{DB: database.DB}
+	repo := s.Repos.Users
 	user, err := repo.GetUserByUsername(req.Username)
 	if err != nil {
-		http.Error(w, "Неверное имя пользователя или пароль", http.StatusUnauthorized)
+		// несуществующее имя тоже считаем и сверяем с dummyPasswordHash,
+		// чтобы ни по задержкам, ни по времени ответа нельзя было отличить
+		// его от существующего
+		user = &models.User{}
+	}
+	keys := loginKeys(req.Username, s.clientIP(r))
+	// отказ по счётчику в журнал не пишется: иначе каждый запрос во время
+	// блокировки добавлял бы строку; блокировку отмечает неудача, что её вызвала
+	if wait, locked := s.Guard.Check(keys...); wait > 0 {
+		tooManyAttempts(w, wait, locked)
 		return
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	if !checkLoginPassword(user, req.Password) {
+		locked := s.Guard.Fail(keys...)
+		s.recordLoginFailure(r, user.ID, req.Username, models.LoginBadPassword, locked)
 		http.Error(w, "Неверное имя пользователя или пароль", http.StatusUnauthorized)
 		return
 	}
-	token, err := generateJWT(user.ID, user.Username)
+	// счётчик IP не сбрасываем: иначе перебор чужих паролей можно
+	// перемежать входами в свой аккаунт
+	s.Guard.Reset(keys[0])
+	// При включённой 2FA вместо токенов — challenge для второго шага
+	if s.twoFactorChallenge(w, user.ID) {
+		return
+	}
+	tokens, err := s.startSession(r, user.ID, user.Username, req.DeviceName)
 	if err != nil {
 		http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
 		return
 	}
 	user.Password = ""
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.LoginResponse{Token: token, User: *user})
+	json.NewEncoder(w).Encode(models.LoginResponse{TokenPair: tokens, User: *user})
 }
 
-func GetUserProfile(w http.ResponseWriter, r *http.Request) {
-	userID, username, err := parseJWTFromRequest(r)
-	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
+func (s *Server) GetUserProfile(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	username := middleware.GetUsername(r)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"id": userID, "username": username})
 }
 
-func GetUsers(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
-	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
+func (s *Server) GetUsers(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
 	tag := r.URL.Query().Get("tag")
-	repo := repository.UserRepository{DB: database.DB}
+	repo := s.Repos.Users
 	users, err := repo.SearchByTag(tag, userID)
 	if err != nil || users == nil {
 		users = []models.User{}
@@ -104,37 +152,56 @@ func GetUsers(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(users)
 }
 
-func GetConversations(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
+func (s *Server) GetConversations(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	repo := s.Repos.Conversations
+	convs, err := repo.ListForUser(userID)
 	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
+		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
 		return
 	}
-	repo := repository.MessageRepository{DB: database.DB}
-	convs, err := repo.GetConversations(userID)
+	if convs == nil {
+		convs = []models.Conversation{}
+	}
+	ids := make([]int, len(convs))
+	for i := range convs {
+		ids[i] = convs[i].ID
+	}
+	pins, err := s.Repos.Pins.Latest(models.ChatDirect, ids)
 	if err != nil {
 		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
 		return
 	}
-	if convs == nil {
-		convs = []models.Conversation{}
+	for i := range convs {
+		if pin, ok := pins[convs[i].ID]; ok {
+			convs[i].PinnedMessage = &pin
+		}
+		if s.Hub.IsBlockedBetween(userID, convs[i].OtherUserID) {
+			convs[i].OtherLastSeenAt = nil
+			continue
+		}
+		convs[i].OtherOnline = s.Hub.IsOnline(convs[i].OtherUserID)
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(convs)
 }
 
-func StartConversation(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
-	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
+func (s *Server) StartConversation(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
 	var body struct {
 		OtherUserID int `json:"other_user_id"`
 	}
 	json.NewDecoder(r.Body).Decode(&body)
-	repo := repository.MessageRepository{DB: database.DB}
-	convID, err := repo.GetOrCreateConversation(userID, body.OtherUserID)
+	if body.OtherUserID == 0 || body.OtherUserID == userID {
+		http.Error(w, "Неверный собеседник", http.StatusBadRequest)
+		return
+	}
+	if err := s.Authz.CanContact(userID, body.OtherUserID); err != nil {
+		writeAuthzError(w, err)
+		return
+	}
+	repo := s.Repos.Conversations
+	convID, err := repo.GetOrCreate(userID, body.OtherUserID)
 	if err != nil {
 		http.Error(w, "Ошибка создания диалога", http.StatusInternalServerError)
 		return
@@ -143,16 +210,34 @@ func StartConversation(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]int{"conversation_id": convID})
 }
 
-func GetMessages(w http.ResponseWriter, r *http.Request) {
-	_, _, err := parseJWTFromRequest(r)
-	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
+func (s *Server) GetMessages(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
 	convIDStr := r.URL.Query().Get("conversation_id")
 	convID, _ := strconv.Atoi(convIDStr)
-	repo := repository.MessageRepository{DB: database.DB}
+	if err := s.Authz.CanReadConversation(userID, convID); err != nil {
+		writeAuthzError(w, err)
+		return
+	}
+	repo := s.Repos.Messages
+
+	if page, ok := parsePage(r); ok {
+		result, err := repo.GetMessagesPage(convID, page)
+		if err == nil {
+			err = attachReactions(s.Repos, userID, result.Messages)
+		}
+		if err != nil {
+			http.Error(w, "Ошибка БД", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
 	msgs, err := repo.GetMessages(convID)
+	if err == nil {
+		err = attachReactions(s.Repos, userID, msgs)
+	}
 	if err != nil {
 		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
 		return
@@ -164,90 +249,101 @@ func GetMessages(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(msgs)
 }
 
-func HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+// parsePage читает before_id/after_id/around_id/limit. Без них ok == false,
+// и обработчик отдаёт всю историю массивом, как раньше.
+func parsePage(r *http.Request) (repository.Page, bool) {
+	q := r.URL.Query()
+	var p repository.Page
+	p.BeforeID, _ = strconv.Atoi(q.Get("before_id"))
+	p.AfterID, _ = strconv.Atoi(q.Get("after_id"))
+	p.AroundID, _ = strconv.Atoi(q.Get("around_id"))
+	p.Limit, _ = strconv.Atoi(q.Get("limit"))
+	ok := q.Has("before_id") || q.Has("after_id") || q.Has("around_id") || q.Has("limit")
+	return p, ok
+}
+
+func (s *Server) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	tokenStr := r.URL.Query().Get("token")
 	if tokenStr == "" {
 		http.Error(w, "Токен обязателен", http.StatusUnauthorized)
 		return
 	}
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "default_secret"
-	}
-	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
-	if err != nil || !token.Valid {
+	claims, err := middleware.Authenticate(s.Tokens, s.Repos.Sessions, tokenStr)
+	if err != nil {
 		http.Error(w, "Неверный токен", http.StatusUnauthorized)
 		return
 	}
-	claims := token.Claims.(jwt.MapClaims)
-	userID := int(claims["user_id"].(float64))
-	username := claims["username"].(string)
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return
 	}
 
-	client := ws.NewClientWithConn(ws.GlobalHub, conn, userID, username)
-	ws.GlobalHub.Register(client)
+	// device_id позволяет держать несколько устройств одного пользователя онлайн;
+	// по умолчанию устройство — это сессия входа
+	deviceID := r.URL.Query().Get("device_id")
+	if deviceID == "" {
+		deviceID = claims.SessionID
+	}
+
+	client := ws.NewClientWithConn(s.Hub, conn, claims.UserID, claims.Username, deviceID, claims.SessionID)
+	if !s.Hub.Register(client) {
+		return
+	}
 
 	go client.WritePump()
 	go client.ReadPump()
 }
 
-func GetProfile(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
+func (s *Server) GetProfile(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	repo := s.Repos.Users
+	u, err := repo.GetProfile(userID)
 	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
+		http.Error(w, "Пользователь не найден", http.StatusNotFound)
 		return
 	}
-	var u models.User
-	database.DB.QueryRow(
-		`SELECT id, username, COALESCE(display_name,''), COALESCE(user_tag,''), COALESCE(bio,''), COALESCE(avatar_url,'') FROM users WHERE id=$1`,
-		userID,
-	).Scan(&u.ID, &u.Username, &u.DisplayName, &u.UserTag, &u.Bio, &u.AvatarURL)
-	u.Password = ""
+	if u.DeletionRequestedAt != nil {
+		t := u.DeletionRequestedAt.Add(AccountDeletionGrace)
+		u.DeleteAfter = &t
+	}
+	// своё присутствие клиенту не нужно
+	u.LastSeenAt = nil
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(u)
 }
 
-func GetUserProfileByID(w http.ResponseWriter, r *http.Request) {
-	_, _, err := parseJWTFromRequest(r)
-	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
+func (s *Server) GetUserProfileByID(w http.ResponseWriter, r *http.Request) {
+	viewerID := middleware.GetUserID(r)
 	idStr := r.URL.Query().Get("id")
 	id, _ := strconv.Atoi(idStr)
-	var u models.User
-	database.DB.QueryRow(
-		`SELECT id, username, COALESCE(display_name,''), COALESCE(user_tag,''), COALESCE(bio,''), COALESCE(avatar_url,'') FROM users WHERE id=$1`,
-		id,
-	).Scan(&u.ID, &u.Username, &u.DisplayName, &u.UserTag, &u.Bio, &u.AvatarURL)
-	u.Password = ""
+	repo := s.Repos.Users
+	u, err := repo.GetProfile(id)
+	if err != nil {
+		u = &models.User{}
+	}
+	// email и удаление аккаунта видит только владелец
+	u.Email, u.EmailVerified, u.DeletionRequestedAt = "", false, nil
+	// При блокировке присутствие скрываем
+	if u.ID != 0 && s.Hub.IsBlockedBetween(viewerID, u.ID) {
+		u.LastSeenAt = nil
+	} else {
+		u.Online = u.ID != 0 && s.Hub.IsOnline(u.ID)
+	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(u)
 }
 
-func UpdateProfile(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
-	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
+func (s *Server) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
 	var body struct {
 		DisplayName string `json:"display_name"`
 		Bio         string `json:"bio"`
 		AvatarURL   string `json:"avatar_url"`
 	}
 	json.NewDecoder(r.Body).Decode(&body)
-	_, err = database.DB.Exec(
-		`UPDATE users SET display_name=$1, bio=$2, avatar_url=$3 WHERE id=$4`,
-		body.DisplayName, body.Bio, body.AvatarURL, userID,
-	)
-	if err != nil {
+	repo := s.Repos.Users
+	if err := repo.UpdateProfile(userID, body.DisplayName, body.Bio, body.AvatarURL); err != nil {
 		http.Error(w, "Ошибка обновления", http.StatusInternalServerError)
 		return
 	}
@@ -255,47 +351,11 @@ func UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "Профиль обновлён"})
 }
 
-func GetCloudinaryConfig(w http.ResponseWriter, r *http.Request) {
-	_, _, err := parseJWTFromRequest(r)
-	if err != nil {
-		http.Error(w, "Не авторизован", http.StatusUnauthorized)
-		return
-	}
+func (s *Server) GetCloudinaryConfig(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
-		"cloud_name":    os.Getenv("CLOUDINARY_CLOUD_NAME"),
-		"api_key":       os.Getenv("CLOUDINARY_API_KEY"),
-		"upload_preset": "elowy_avatars",
-	})
-}
-
-func generateJWT(userID int, username string) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "default_secret"
-	}
-	claims := jwt.MapClaims{
-		"user_id":  userID,
-		"username": username,
-		"exp":      time.Now().Add(24 * time.Hour).Unix(),
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
-}
-
-func parseJWTFromRequest(r *http.Request) (int, string, error) {
-	authHeader := r.Header.Get("Authorization")
-	tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "default_secret"
-	}
-	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
+		"cloud_name":    s.Config.Cloudinary.CloudName,
+		"api_key":       s.Config.Cloudinary.APIKey,
+		"upload_preset": s.Config.Cloudinary.UploadPreset,
 	})
-	if err != nil || !token.Valid {
-		return 0, "", err
-	}
-	claims := token.Claims.(jwt.MapClaims)
-	return int(claims["user_id"].(float64)), claims["username"].(string), nil
 }
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"your_project/internal/middleware"
+	"your_project/internal/models"
+	"your_project/internal/pkg/throttle"
+)
+
+var (
+	// По имени пользователя: немного бесплатных попыток, затем удваивающаяся
+	// задержка и блокировка на 15 минут после 10 неудач подряд
+	usernamePolicy = throttle.Policy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutAfter:    10,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+	// По IP пороги выше: за одним адресом может быть целый офис
+	ipPolicy = throttle.Policy{
+		FreeAttempts:    10,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutAfter:    50,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+	// Регистрация считает каждую попытку, а не только неудачные
+	registerPolicy = throttle.Policy{
+		FreeAttempts: 5,
+		BaseDelay:    10 * time.Second,
+		MaxDelay:     10 * time.Minute,
+		Window:       time.Hour,
+	}
+	// Письма (сброс пароля, подтверждение) — тоже каждая отправка
+	mailPolicy = throttle.Policy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Minute,
+		MaxDelay:     30 * time.Minute,
+		Window:       time.Hour,
+	}
+)
+
+func loginKeys(username, ip string) []throttle.Key {
+	return []throttle.Key{
+		{Name: "login:user:" + strings.ToLower(username), Policy: usernamePolicy},
+		{Name: "login:ip:" + ip, Policy: ipPolicy},
+	}
+}
+
+func twoFactorKeys(userID int, ip string) []throttle.Key {
+	return []throttle.Key{
+		{Name: "2fa:user:" + strconv.Itoa(userID), Policy: usernamePolicy},
+		{Name: "login:ip:" + ip, Policy: ipPolicy},
+	}
+}
+
+func registerKey(ip string) throttle.Key {
+	return throttle.Key{Name: "register:ip:" + ip, Policy: registerPolicy}
+}
+
+func mailKey(prefix, value string) throttle.Key {
+	return throttle.Key{Name: prefix + strings.ToLower(value), Policy: mailPolicy}
+}
+
+// tooManyAttempts отвечает 429 с Retry-After
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration, locked bool) {
+	secs := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	msg := fmt.Sprintf("Слишком много попыток, повторите через %d с", secs)
+	if locked {
+		msg = fmt.Sprintf("Вход временно заблокирован, повторите через %d мин", (secs+59)/60)
+	}
+	http.Error(w, msg, http.StatusTooManyRequests)
+}
+
+// recordLoginFailure пишет неудачную попытку в журнал владельца аккаунта,
+// а если она привела к блокировке — ещё и запись LoginLocked, одну на окно
+// блокировки. userID == 0 — такого пользователя нет, журнал показывать
+// некому, и попытка не пишется.
+func (s *Server) recordLoginFailure(r *http.Request, userID int, username, reason string, locked bool) {
+	if userID == 0 {
+		return
+	}
+	reasons := []string{reason}
+	if locked {
+		reasons = append(reasons, models.LoginLocked)
+	}
+	repo := s.Repos.LoginAttempts
+	for _, reason := range reasons {
+		a := models.LoginAttempt{
+			UserID:    &userID,
+			Username:  username,
+			IP:        s.clientIP(r),
+			UserAgent: r.UserAgent(),
+			Reason:    reason,
+		}
+		if err := repo.Record(a); err != nil {
+			log.Printf("Ошибка записи попытки входа: %v", err)
+		}
+	}
+}
+
+// PruneLoginAttempts раз в час удаляет записи журнала входов старше
+// Config.LoginAttemptRetention
+func (s *Server) PruneLoginAttempts() {
+	repo := s.Repos.LoginAttempts
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		if n, err := repo.Prune(time.Now().Add(-s.Config.LoginAttemptRetention)); err != nil {
+			log.Println("Ошибка очистки журнала входов:", err)
+		} else if n > 0 {
+			log.Printf("Удалено старых попыток входа: %d", n)
+		}
+	}
+}
+
+// GET /api/auth/login-attempts?limit=50 — неудачные попытки входа в аккаунт
+func (s *Server) GetLoginAttempts(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	repo := s.Repos.LoginAttempts
+	attempts, err := repo.ListFailed(userID, limit)
+	if err != nil {
+		http.Error(w, "Ошибка получения журнала входов", http.StatusInternalServerError)
+		return
+	}
+	if attempts == nil {
+		attempts = []models.LoginAttempt{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(attempts)
+}
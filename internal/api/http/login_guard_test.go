@@ -0,0 +1,163 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"your_project/internal/models"
+	"your_project/internal/pkg/throttle"
+)
+
+// login — неудачный вход под username; xff, если не пуст, уходит в X-Forwarded-For
+func (e *testEnv) login(username, xff string) int {
+	e.t.Helper()
+	body, _ := json.Marshal(map[string]string{"username": username, "password": "wrong"})
+	req, err := http.NewRequest("POST", e.ts.URL+"/api/login", bytes.NewReader(body))
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	if xff != "" {
+		req.Header.Set("X-Forwarded-For", xff)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// Перебор по разным именам упирается в счётчик IP, и новый X-Forwarded-For
+// на каждую попытку его не обходит: клиент не за доверенным прокси
+func TestLoginGuardIgnoresRotatedForwardedFor(t *testing.T) {
+	e := newTestEnv(t)
+	for i := 1; i <= ipPolicy.FreeAttempts+1; i++ {
+		xff := fmt.Sprintf("198.51.100.%d", i)
+		if status := e.login(fmt.Sprintf("victim%d", i), xff); status != http.StatusUnauthorized {
+			t.Fatalf("попытка %d: %d, ждали 401", i, status)
+		}
+	}
+	if status := e.login("victim-next", "203.0.113.200"); status != http.StatusTooManyRequests {
+		t.Fatalf("после %d неудач с одного адреса: %d, ждали 429", ipPolicy.FreeAttempts+1, status)
+	}
+}
+
+// За доверенным прокси счётчик ведётся по адресу клиента из X-Forwarded-For:
+// один перебирающий не блокирует остальных за тем же прокси
+func TestLoginGuardBehindTrustedProxy(t *testing.T) {
+	e := newTestEnv(t)
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	e.s.Config.TrustedProxies = []*net.IPNet{loopback}
+
+	for i := 1; i <= ipPolicy.FreeAttempts+1; i++ {
+		e.login(fmt.Sprintf("victim%d", i), "198.51.100.1")
+	}
+	if status := e.login("victim-next", "198.51.100.1"); status != http.StatusTooManyRequests {
+		t.Fatalf("перебирающий клиент: %d, ждали 429", status)
+	}
+	if status := e.login("someone", "198.51.100.2"); status != http.StatusUnauthorized {
+		t.Fatalf("другой клиент за тем же прокси: %d, ждали 401", status)
+	}
+}
+
+// agedStore отдаёт счётчики так, будто последняя неудача была 10 минут
+// назад: задержки (не больше 5 минут) уже прошли, а блокировка ещё действует
+type agedStore struct {
+	*throttle.MemoryStore
+}
+
+func (s agedStore) Get(key string) (throttle.Attempt, error) {
+	a, err := s.MemoryStore.Get(key)
+	if a.Count > 0 {
+		a.Last = a.Last.Add(-10 * time.Minute)
+	}
+	return a, err
+}
+
+// Журнал входов пишется только для существующих аккаунтов, а блокировка
+// отмечается одной записью, сколько бы запросов ни отклонили во время неё
+func TestLoginAttemptsAudit(t *testing.T) {
+	e := newTestEnv(t)
+	e.s.Guard.Close()
+	e.s.Guard = throttle.NewGuard(agedStore{throttle.NewMemoryStore()})
+	token, _ := e.user("alice")
+
+	for i := 1; i <= usernamePolicy.LockoutAfter; i++ {
+		if status := e.login("alice", ""); status != http.StatusUnauthorized {
+			t.Fatalf("попытка %d: %d, ждали 401", i, status)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		if status := e.login("alice", ""); status != http.StatusTooManyRequests {
+			t.Fatalf("во время блокировки: %d, ждали 429", status)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		e.login(fmt.Sprintf("nobody%d", i), "")
+	}
+
+	var attempts []models.LoginAttempt
+	e.do("GET", "/api/auth/login-attempts?limit=200", token, nil, http.StatusOK, &attempts)
+	reasons := map[string]int{}
+	for _, a := range attempts {
+		reasons[a.Reason]++
+	}
+	if reasons[models.LoginBadPassword] != usernamePolicy.LockoutAfter || reasons[models.LoginLocked] != 1 || len(attempts) != usernamePolicy.LockoutAfter+1 {
+		t.Fatalf("журнал alice = %v", reasons)
+	}
+	// других записей, в том числе по несуществующим именам, нет
+	n, err := e.s.Repos.LoginAttempts.Prune(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(attempts)) {
+		t.Fatalf("в журнале %d записей, из них alice %d", n, len(attempts))
+	}
+}
+
+func TestCheckLoginPassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name     string
+		user     models.User
+		password string
+		want     bool
+	}{
+		{"верный пароль", models.User{ID: 1, Password: string(hash)}, "secret123", true},
+		{"неверный пароль", models.User{ID: 1, Password: string(hash)}, "wrong", false},
+		{"нет пользователя", models.User{}, "dummy password", false},
+		{"удалённый аккаунт", models.User{ID: 1}, "dummy password", false},
+	} {
+		if got := checkLoginPassword(&tc.user, tc.password); got != tc.want {
+			t.Errorf("%s: %v, ждали %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// Вход под несуществующим именем проходит через bcrypt так же, как под
+// существующим: иначе время ответа выдаёт, какие имена заняты
+func TestCheckLoginPasswordRunsBcryptForUnknownUser(t *testing.T) {
+	measure := func(user *models.User) time.Duration {
+		start := time.Now()
+		checkLoginPassword(user, "wrong")
+		return time.Since(start)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.DefaultCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	known := measure(&models.User{ID: 1, Password: string(hash)})
+	if unknown := measure(&models.User{}); unknown < known/4 {
+		t.Fatalf("неизвестное имя проверено за %v, существующее за %v", unknown, known)
+	}
+}
@@ -0,0 +1,166 @@
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"your_project/internal/api/ws"
+	"your_project/internal/authz"
+	"your_project/internal/middleware"
+	"your_project/internal/models"
+	"your_project/internal/repository"
+)
+
+// POST /api/messages/edit — group_id == 0 означает личный диалог
+func (s *Server) EditMessage(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	var body struct {
+		MessageID int    `json:"message_id"`
+		GroupID   int    `json:"group_id"`
+		Content   string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.MessageID == 0 {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(body.Content) == "" {
+		http.Error(w, "Текст сообщения пуст", http.StatusBadRequest)
+		return
+	}
+	if err := s.Hub.EditMessage(userID, body.GroupID, body.MessageID, body.Content); err != nil {
+		writeMessageChangeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Сообщение изменено"})
+}
+
+// POST /api/messages/delete
+func (s *Server) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	var body struct {
+		MessageID int `json:"message_id"`
+		GroupID   int `json:"group_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.MessageID == 0 {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	if err := s.Hub.DeleteMessage(userID, body.GroupID, body.MessageID); err != nil {
+		writeMessageChangeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Сообщение удалено"})
+}
+
+// POST /api/messages/forward — переслать сообщения одного чата в другой.
+// Источник: source_conversation_id или source_group_id, назначение:
+// conversation_id или group_id. Отвечает ID созданных копий.
+func (s *Server) ForwardMessages(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	var body ws.ForwardRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	ids, err := s.Hub.ForwardMessages(userID, body)
+	switch err {
+	case nil:
+	case ws.ErrForwardChat, ws.ErrForwardCount:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		writeMessageChangeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]int{"message_ids": ids})
+}
+
+// POST /api/messages/read — отметить прочитанным всё до message_id включительно
+func (s *Server) MarkMessagesRead(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	var body struct {
+		ConversationID int `json:"conversation_id"`
+		GroupID        int `json:"group_id"`
+		MessageID      int `json:"message_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.MessageID == 0 {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	err := s.Hub.MarkReceipt(userID, body.ConversationID, body.GroupID, body.MessageID, true)
+	if err == repository.ErrNotChatMember {
+		err = authz.ErrNotMember
+	}
+	if err != nil {
+		writeAuthzError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Прочитано"})
+}
+
+// GET /api/messages/edits?message_id=X[&group_id=Y]
+func (s *Server) GetMessageEdits(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	messageID, _ := strconv.Atoi(r.URL.Query().Get("message_id"))
+	groupID, _ := strconv.Atoi(r.URL.Query().Get("group_id"))
+
+	chatType := models.ChatDirect
+	if groupID != 0 {
+		chatType = models.ChatGroup
+	}
+
+	repo := s.Repos.Messages
+	chatID, err := repo.GetMessageChat(chatType, messageID)
+	if err != nil {
+		writeMessageChangeError(w, err)
+		return
+	}
+	if groupID != 0 {
+		err = s.Authz.CanReadGroup(userID, chatID)
+	} else {
+		err = s.Authz.CanReadConversation(userID, chatID)
+	}
+	if err != nil {
+		writeAuthzError(w, err)
+		return
+	}
+
+	edits, err := repo.GetEditHistory(chatType, messageID)
+	if err == repository.ErrMessageDeleted {
+		// история удалённого стёрта вместе с ним
+		http.Error(w, "Сообщение удалено", http.StatusGone)
+		return
+	}
+	if err != nil {
+		writeMessageChangeError(w, err)
+		return
+	}
+	if edits == nil {
+		edits = []models.MessageEdit{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(edits)
+}
+
+func writeMessageChangeError(w http.ResponseWriter, err error) {
+	switch err {
+	case authz.ErrNotMember, authz.ErrNotAdmin, authz.ErrBlocked:
+		writeAuthzError(w, err)
+	case repository.ErrMessageNotFound:
+		http.Error(w, "Сообщение не найдено", http.StatusNotFound)
+	case repository.ErrNotMessageSender:
+		http.Error(w, "Нет прав", http.StatusForbidden)
+	case repository.ErrMessageDeleted:
+		http.Error(w, "Сообщение удалено", http.StatusConflict)
+	default:
+		log.Println("Ошибка изменения сообщения:", err)
+		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,70 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"your_project/internal/middleware"
+	"your_project/internal/models"
+)
+
+// POST /api/messages/pin — group_id == 0 означает личный диалог
+func (s *Server) PinMessage(w http.ResponseWriter, r *http.Request) {
+	s.setPinned(w, r, true)
+}
+
+// POST /api/messages/unpin
+func (s *Server) UnpinMessage(w http.ResponseWriter, r *http.Request) {
+	s.setPinned(w, r, false)
+}
+
+func (s *Server) setPinned(w http.ResponseWriter, r *http.Request, pin bool) {
+	userID := middleware.GetUserID(r)
+	var body struct {
+		MessageID int `json:"message_id"`
+		GroupID   int `json:"group_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.MessageID == 0 {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	if err := s.Hub.PinMessage(userID, body.GroupID, body.MessageID, pin); err != nil {
+		writeMessageChangeError(w, err)
+		return
+	}
+	message := "Сообщение закреплено"
+	if !pin {
+		message = "Сообщение откреплено"
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// GET /api/messages/pins?conversation_id=X или ?group_id=Y — закреплённые
+// сообщения чата, последние закреплённые первыми
+func (s *Server) GetPinnedMessages(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	convID, _ := strconv.Atoi(r.URL.Query().Get("conversation_id"))
+	groupID, _ := strconv.Atoi(r.URL.Query().Get("group_id"))
+
+	if err := s.Authz.CanReadChat(userID, convID, groupID); err != nil {
+		writeAuthzError(w, err)
+		return
+	}
+	chatType, chatID := models.ChatDirect, convID
+	if groupID != 0 {
+		chatType, chatID = models.ChatGroup, groupID
+	}
+
+	pins, err := s.Repos.Pins.List(chatType, chatID)
+	if err != nil {
+		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
+		return
+	}
+	if pins == nil {
+		pins = []models.PinnedMessage{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pins)
+}
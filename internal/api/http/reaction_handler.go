@@ -0,0 +1,81 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"your_project/internal/middleware"
+	"your_project/internal/models"
+	"your_project/internal/repository"
+)
+
+// GET /api/messages/reactions?message_id=X[&group_id=Y] — кто и чем отреагировал
+func (s *Server) GetMessageReactions(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	messageID, _ := strconv.Atoi(r.URL.Query().Get("message_id"))
+	groupID, _ := strconv.Atoi(r.URL.Query().Get("group_id"))
+
+	chatType := models.ChatDirect
+	if groupID != 0 {
+		chatType = models.ChatGroup
+	}
+
+	chatID, err := s.Repos.Messages.GetMessageChat(chatType, messageID)
+	if err != nil {
+		writeMessageChangeError(w, err)
+		return
+	}
+	if groupID != 0 {
+		err = s.Authz.CanReadGroup(userID, chatID)
+	} else {
+		err = s.Authz.CanReadConversation(userID, chatID)
+	}
+	if err != nil {
+		writeAuthzError(w, err)
+		return
+	}
+
+	reactions, err := s.Repos.Reactions.List(chatType, messageID)
+	if err != nil {
+		http.Error(w, "Ошибка БД", http.StatusInternalServerError)
+		return
+	}
+	if reactions == nil {
+		reactions = []models.Reaction{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reactions)
+}
+
+// attachReactions заполняет сводку реакций личных сообщений для viewerID
+func attachReactions(repos *repository.Repositories, viewerID int, msgs []models.Message) error {
+	ids := make([]int, len(msgs))
+	for i := range msgs {
+		ids[i] = msgs[i].ID
+	}
+	counts, err := repos.Reactions.Counts(models.ChatDirect, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range msgs {
+		msgs[i].Reactions = counts[msgs[i].ID]
+	}
+	return nil
+}
+
+// attachGroupReactions — то же для сообщений группы
+func attachGroupReactions(repos *repository.Repositories, viewerID int, msgs []models.GroupMessage) error {
+	ids := make([]int, len(msgs))
+	for i := range msgs {
+		ids[i] = msgs[i].ID
+	}
+	counts, err := repos.Reactions.Counts(models.ChatGroup, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range msgs {
+		msgs[i].Reactions = counts[msgs[i].ID]
+	}
+	return nil
+}
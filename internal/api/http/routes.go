@@ -2,36 +2,86 @@ package http
 
 import (
 	"github.com/gorilla/mux"
+
+	"your_project/internal/middleware"
 )
 
-func RegisterRoutes(r *mux.Router) {
-	r.HandleFunc("/api/register", RegisterUser).Methods("POST")
-	r.HandleFunc("/api/login", LoginUser).Methods("POST")
-	r.HandleFunc("/api/profile", GetUserProfile).Methods("GET")
-	r.HandleFunc("/api/profile/me", GetProfile).Methods("GET")
-	r.HandleFunc("/api/profile/update", UpdateProfile).Methods("POST")
-	r.HandleFunc("/api/user", GetUserProfileByID).Methods("GET")
-	r.HandleFunc("/api/users", GetUsers).Methods("GET")
-	r.HandleFunc("/api/conversations", GetConversations).Methods("GET")
-	r.HandleFunc("/api/conversations/start", StartConversation).Methods("POST")
-	r.HandleFunc("/api/conversations/delete", DeleteConversation).Methods("DELETE")
-	r.HandleFunc("/api/messages", GetMessages).Methods("GET")
-	r.HandleFunc("/api/cloudinary/config", GetCloudinaryConfig).Methods("GET")
+func (s *Server) registerRoutes(r *mux.Router) {
+	// Без авторизации
+	r.HandleFunc("/api/register", s.RegisterUser).Methods("POST")
+	r.HandleFunc("/api/login", s.LoginUser).Methods("POST")
+	r.HandleFunc("/api/login/2fa", s.LoginTwoFactor).Methods("POST")
+	r.HandleFunc("/api/auth/refresh", s.RefreshToken).Methods("POST")
+	r.HandleFunc("/api/email/verify", s.VerifyEmail).Methods("POST")
+	r.HandleFunc("/api/password/forgot", s.ForgotPassword).Methods("POST")
+	r.HandleFunc("/api/password/reset", s.ResetPassword).Methods("POST")
+	// WebSocket передаёт токен в query и проверяет его сам
+	r.HandleFunc("/ws", s.HandleWebSocket)
+
+	// Всё остальное — только с действующим access-токеном
+	p := r.NewRoute().Subrouter()
+	p.Use(middleware.Auth(s.Tokens, s.Repos.Sessions))
+
+	// Сессии
+	p.HandleFunc("/api/auth/logout", s.Logout).Methods("POST")
+	p.HandleFunc("/api/auth/logout-all", s.LogoutAll).Methods("POST")
+	p.HandleFunc("/api/sessions", s.GetSessions).Methods("GET")
+	p.HandleFunc("/api/sessions/revoke", s.RevokeSession).Methods("POST")
+	p.HandleFunc("/api/auth/login-attempts", s.GetLoginAttempts).Methods("GET")
+
+	// Email
+	p.HandleFunc("/api/email/update", s.UpdateEmail).Methods("POST")
+	p.HandleFunc("/api/email/resend", s.ResendVerification).Methods("POST")
+
+	// Настройки аккаунта
+	p.HandleFunc("/api/settings/change-password", s.ChangePassword).Methods("POST")
+	p.HandleFunc("/api/settings/change-username", s.ChangeUsername).Methods("POST")
+	p.HandleFunc("/api/settings/delete-account", s.DeleteAccount).Methods("DELETE")
+	p.HandleFunc("/api/settings/cancel-deletion", s.CancelAccountDeletion).Methods("POST")
+
+	// Двухфакторная аутентификация
+	p.HandleFunc("/api/2fa/status", s.GetTwoFactorStatus).Methods("GET")
+	p.HandleFunc("/api/2fa/setup", s.SetupTwoFactor).Methods("POST")
+	p.HandleFunc("/api/2fa/enable", s.EnableTwoFactor).Methods("POST")
+	p.HandleFunc("/api/2fa/disable", s.DisableTwoFactor).Methods("POST")
+	p.HandleFunc("/api/2fa/recovery-codes", s.RegenerateRecoveryCodes).Methods("POST")
+
+	p.HandleFunc("/api/profile", s.GetUserProfile).Methods("GET")
+	p.HandleFunc("/api/profile/me", s.GetProfile).Methods("GET")
+	p.HandleFunc("/api/profile/update", s.UpdateProfile).Methods("POST")
+	p.HandleFunc("/api/user", s.GetUserProfileByID).Methods("GET")
+	p.HandleFunc("/api/users", s.GetUsers).Methods("GET")
+	p.HandleFunc("/api/conversations", s.GetConversations).Methods("GET")
+	p.HandleFunc("/api/conversations/start", s.StartConversation).Methods("POST")
+	p.HandleFunc("/api/conversations/delete", s.DeleteConversation).Methods("DELETE")
+	p.HandleFunc("/api/messages", s.GetMessages).Methods("GET")
+	p.HandleFunc("/api/messages/edit", s.EditMessage).Methods("POST")
+	p.HandleFunc("/api/messages/delete", s.DeleteMessage).Methods("POST")
+	p.HandleFunc("/api/messages/forward", s.ForwardMessages).Methods("POST")
+	p.HandleFunc("/api/messages/pin", s.PinMessage).Methods("POST")
+	p.HandleFunc("/api/messages/unpin", s.UnpinMessage).Methods("POST")
+	p.HandleFunc("/api/messages/pins", s.GetPinnedMessages).Methods("GET")
+	p.HandleFunc("/api/messages/edits", s.GetMessageEdits).Methods("GET")
+	p.HandleFunc("/api/messages/reactions", s.GetMessageReactions).Methods("GET")
+	p.HandleFunc("/api/messages/read", s.MarkMessagesRead).Methods("POST")
+	p.HandleFunc("/api/cloudinary/config", s.GetCloudinaryConfig).Methods("GET")
 
 	// Блокировка
-	r.HandleFunc("/api/users/block", BlockUser).Methods("POST")
-	r.HandleFunc("/api/users/unblock", UnblockUser).Methods("POST")
-	r.HandleFunc("/api/users/blocked", GetBlockedUsers).Methods("GET")
+	p.HandleFunc("/api/users/block", s.BlockUser).Methods("POST")
+	p.HandleFunc("/api/users/unblock", s.UnblockUser).Methods("POST")
+	p.HandleFunc("/api/users/blocked", s.GetBlockedUsers).Methods("GET")
 
 	// Группы
-	r.HandleFunc("/api/groups", GetGroups).Methods("GET")
-	r.HandleFunc("/api/groups/create", CreateGroup).Methods("POST")
-	r.HandleFunc("/api/groups/messages", GetGroupMessages).Methods("GET")
-	r.HandleFunc("/api/groups/info", GetGroupInfo).Methods("GET")
-	r.HandleFunc("/api/groups/update", UpdateGroup).Methods("POST")
-	r.HandleFunc("/api/groups/members/add", AddGroupMember).Methods("POST")
-	r.HandleFunc("/api/groups/members/remove", RemoveGroupMember).Methods("POST")
-
-	r.HandleFunc("/api/fcm/token", SaveFcmToken).Methods("POST")
-	r.HandleFunc("/ws", HandleWebSocket)
+	p.HandleFunc("/api/groups", s.GetGroups).Methods("GET")
+	p.HandleFunc("/api/groups/create", s.CreateGroup).Methods("POST")
+	p.HandleFunc("/api/groups/messages", s.GetGroupMessages).Methods("GET")
+	p.HandleFunc("/api/groups/threads", s.GetGroupThread).Methods("GET")
+	p.HandleFunc("/api/groups/info", s.GetGroupInfo).Methods("GET")
+	p.HandleFunc("/api/groups/members", s.GetGroupMembers).Methods("GET")
+	p.HandleFunc("/api/groups/messages/receipts", s.GetGroupMessageReceipts).Methods("GET")
+	p.HandleFunc("/api/groups/update", s.UpdateGroup).Methods("POST")
+	p.HandleFunc("/api/groups/members/add", s.AddGroupMember).Methods("POST")
+	p.HandleFunc("/api/groups/members/remove", s.RemoveGroupMember).Methods("POST")
+
+	p.HandleFunc("/api/fcm/token", s.SaveFcmToken).Methods("POST")
 }
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+
+	ws "your_project/internal/api/ws"
+	"your_project/internal/authz"
+	"your_project/internal/config"
+	"your_project/internal/notify"
+	"your_project/internal/pkg/auth"
+	"your_project/internal/pkg/mailer"
+	"your_project/internal/pkg/shutdown"
+	"your_project/internal/pkg/throttle"
+	"your_project/internal/repository"
+)
+
+// Server владеет всеми зависимостями API и строит из них роутер.
+// Глобального состояния нет: в одном процессе (например, в тестах через
+// httptest) можно поднять несколько серверов с разными зависимостями.
+type Server struct {
+	Config *config.Config
+	Tokens *auth.Tokens
+	Repos  *repository.Repositories
+	// Authz — проверки доступа к чатам над теми же Repos
+	Authz    *authz.Authorizer
+	Hub      *ws.Hub
+	Notifier notify.Notifier
+	Mailer   mailer.Mailer
+	// Guard считает неудачные попытки входа, регистрации и отправки писем
+	Guard *throttle.Guard
+
+	// stop останавливает фоновые задачи StartBackground
+	stop       chan struct{}
+	background sync.WaitGroup
+}
+
+// NewServer собирает сервер над хранилищами и брокером хаба: repository.New
+// для PostgreSQL или memory.New для тестов без БД. Mailer и Guard можно
+// заменить после создания; прежний Guard при этом нужно закрыть.
+func NewServer(cfg *config.Config, repos *repository.Repositories, broker ws.Broker, notifier notify.Notifier) *Server {
+	hub := ws.NewHub(repos, broker, notifier)
+	return &Server{
+		Config:   cfg,
+		Tokens:   auth.NewTokens(cfg.JWTSecret),
+		Repos:    repos,
+		Authz:    hub.Authz,
+		Hub:      hub,
+		Notifier: notifier,
+		Mailer:   mailer.New(cfg.Mail),
+		Guard:    throttle.NewGuard(throttle.NewMemoryStore()),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Router возвращает обработчик со всеми маршрутами API и CORS
+func (s *Server) Router() http.Handler {
+	r := mux.NewRouter()
+	r.Use(cors)
+	s.registerRoutes(r)
+	return r
+}
+
+// StartBackground запускает периодические задачи сервера
+func (s *Server) StartBackground() {
+	s.background.Add(3)
+	go func() {
+		defer s.background.Done()
+		s.Hub.PruneEvents(s.Config.EventRetention, s.stop)
+	}()
+	go func() {
+		defer s.background.Done()
+		s.PurgeDeletedAccounts()
+	}()
+	go func() {
+		defer s.background.Done()
+		s.PruneLoginAttempts()
+	}()
+}
+
+// Shutdown останавливает фоновые задачи и счётчики Guard, закрывает WebSocket-подключения и
+// ждёт начатые обработчики и push-уведомления, но не дольше ctx. Приём
+// новых HTTP-запросов до этого останавливает http.Server.Shutdown.
+func (s *Server) Shutdown(ctx context.Context) error {
+	close(s.stop)
+	s.Guard.Close()
+	errs := []error{
+		s.Hub.Shutdown(ctx),
+		shutdown.Wait(ctx, s.background.Wait),
+		// push ставятся из обработчиков хаба, поэтому ждём их последними
+		s.Notifier.Shutdown(ctx),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
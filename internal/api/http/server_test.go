@@ -0,0 +1,435 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"your_project/internal/api/ws"
+	"your_project/internal/config"
+	"your_project/internal/notify"
+	"your_project/internal/repository/memory"
+)
+
+// testEnv — сервер целиком (HTTP и WebSocket) над хранилищами в памяти
+type testEnv struct {
+	t  *testing.T
+	s  *Server
+	ts *httptest.Server
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	cfg := &config.Config{JWTSecret: "test-secret", EventRetention: time.Hour}
+	s := NewServer(cfg, memory.New(), ws.NewLocalBroker(), notify.Nop{})
+	ts := httptest.NewServer(s.Router())
+	t.Cleanup(func() {
+		ts.Close()
+		s.Guard.Close()
+	})
+	return &testEnv{t: t, s: s, ts: ts}
+}
+
+// request выполняет запрос с JSON-телом body и токеном token ("" — без него)
+func (e *testEnv) request(method, path, token string, body interface{}) *http.Response {
+	e.t.Helper()
+	var rd io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			e.t.Fatal(err)
+		}
+		rd = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, e.ts.URL+path, rd)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	e.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// do выполняет запрос и проверяет код ответа; out, если не nil, получает тело
+func (e *testEnv) do(method, path, token string, body interface{}, wantStatus int, out interface{}) {
+	e.t.Helper()
+	resp := e.request(method, path, token, body)
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		e.t.Fatalf("%s %s: %d %s, ждали %d", method, path, resp.StatusCode, strings.TrimSpace(string(data)), wantStatus)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			e.t.Fatalf("%s %s: %v в %s", method, path, err, data)
+		}
+	}
+}
+
+// user регистрирует и логинит пользователя, возвращает токен и ID
+func (e *testEnv) user(name string) (string, int) {
+	e.t.Helper()
+	e.do("POST", "/api/register", "", map[string]string{
+		"username": name, "password": "secret123", "display_name": name, "user_tag": name,
+	}, http.StatusCreated, nil)
+	var pair struct {
+		Token string `json:"token"`
+	}
+	e.do("POST", "/api/login", "", map[string]string{"username": name, "password": "secret123"}, http.StatusOK, &pair)
+	u, err := e.s.Repos.Users.GetUserByUsername(name)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return pair.Token, u.ID
+}
+
+func (e *testEnv) conversation(token string, otherID int) int {
+	e.t.Helper()
+	var out struct {
+		ConversationID int `json:"conversation_id"`
+	}
+	e.do("POST", "/api/conversations/start", token, map[string]int{"other_user_id": otherID}, http.StatusOK, &out)
+	return out.ConversationID
+}
+
+func (e *testEnv) group(token string, memberIDs ...int) int {
+	e.t.Helper()
+	var out struct {
+		GroupID int `json:"group_id"`
+	}
+	e.do("POST", "/api/groups/create", token, map[string]interface{}{"name": "группа", "member_ids": memberIDs}, http.StatusOK, &out)
+	return out.GroupID
+}
+
+// dial подключает WebSocket и ждёт, пока хаб зарегистрирует клиента
+func (e *testEnv) dial(token string) *websocket.Conn {
+	e.t.Helper()
+	url := "ws" + strings.TrimPrefix(e.ts.URL, "http") + "/ws?token=" + token
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	e.t.Cleanup(func() { conn.Close() })
+	time.Sleep(30 * time.Millisecond)
+	return conn
+}
+
+func send(t *testing.T, conn *websocket.Conn, v interface{}) {
+	t.Helper()
+	if err := conn.WriteJSON(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readType пропускает входящие сообщения до первого с type == typ
+func readType(t *testing.T, conn *websocket.Conn, typ string) map[string]interface{} {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("ждали %s: %v", typ, err)
+		}
+		var m map[string]interface{}
+		if json.Unmarshal(data, &m) == nil && m["type"] == typ {
+			return m
+		}
+	}
+}
+
+func TestAuthFlow(t *testing.T) {
+	e := newTestEnv(t)
+	token, id := e.user("alice")
+
+	e.do("POST", "/api/login", "", map[string]string{"username": "alice", "password": "wrong"}, http.StatusUnauthorized, nil)
+	e.do("POST", "/api/register", "", map[string]string{
+		"username": "alice", "password": "secret123", "display_name": "a", "user_tag": "alice2",
+	}, http.StatusConflict, nil)
+
+	var me struct {
+		ID       int    `json:"id"`
+		Username string `json:"username"`
+	}
+	e.do("GET", "/api/profile/me", token, nil, http.StatusOK, &me)
+	if me.ID != id || me.Username != "alice" {
+		t.Fatalf("profile/me = %+v", me)
+	}
+	e.do("GET", "/api/profile/me", "", nil, http.StatusUnauthorized, nil)
+	e.do("GET", "/api/profile/me", "garbage", nil, http.StatusUnauthorized, nil)
+}
+
+func TestDirectMessages(t *testing.T) {
+	e := newTestEnv(t)
+	aliceToken, _ := e.user("alice")
+	bobToken, bobID := e.user("bob")
+	eveToken, _ := e.user("eve")
+	conv := e.conversation(aliceToken, bobID)
+
+	alice, bob := e.dial(aliceToken), e.dial(bobToken)
+	send(t, alice, map[string]interface{}{"type": "message", "conversation_id": conv, "content": "привет"})
+	got := readType(t, bob, "message")
+	if got["content"] != "привет" {
+		t.Fatalf("bob получил %v", got)
+	}
+	readType(t, alice, "message")
+
+	var msgs []struct {
+		ID      int    `json:"id"`
+		Content string `json:"content"`
+	}
+	path := fmt.Sprintf("/api/messages?conversation_id=%d", conv)
+	e.do("GET", path, bobToken, nil, http.StatusOK, &msgs)
+	if len(msgs) != 1 || msgs[0].Content != "привет" {
+		t.Fatalf("история = %+v", msgs)
+	}
+	e.do("GET", path, eveToken, nil, http.StatusForbidden, nil)
+
+	var page struct {
+		Messages   []json.RawMessage `json:"messages"`
+		NextCursor int               `json:"next_cursor"`
+	}
+	e.do("GET", path+"&limit=10", aliceToken, nil, http.StatusOK, &page)
+	if len(page.Messages) != 1 || page.NextCursor != 0 {
+		t.Fatalf("страница = %+v", page)
+	}
+}
+
+func TestEditHistoryOfDeletedMessage(t *testing.T) {
+	e := newTestEnv(t)
+	aliceToken, _ := e.user("alice")
+	_, bobID := e.user("bob")
+	conv := e.conversation(aliceToken, bobID)
+
+	alice := e.dial(aliceToken)
+	send(t, alice, map[string]interface{}{"type": "message", "conversation_id": conv, "content": "v1"})
+	id := int(readType(t, alice, "message")["message_id"].(float64))
+
+	e.do("POST", "/api/messages/edit", aliceToken, map[string]interface{}{"message_id": id, "content": "v2"}, http.StatusOK, nil)
+	var edits []struct {
+		OldContent string `json:"old_content"`
+	}
+	path := fmt.Sprintf("/api/messages/edits?message_id=%d", id)
+	e.do("GET", path, aliceToken, nil, http.StatusOK, &edits)
+	if len(edits) != 1 || edits[0].OldContent != "v1" {
+		t.Fatalf("история правок = %+v", edits)
+	}
+
+	e.do("POST", "/api/messages/delete", aliceToken, map[string]int{"message_id": id}, http.StatusOK, nil)
+	e.do("GET", path, aliceToken, nil, http.StatusGone, nil)
+	e.do("POST", "/api/messages/edit", aliceToken, map[string]interface{}{"message_id": id, "content": "v3"}, http.StatusConflict, nil)
+	e.do("GET", "/api/messages/edits?message_id=999999", aliceToken, nil, http.StatusNotFound, nil)
+}
+
+func TestGroupMessages(t *testing.T) {
+	e := newTestEnv(t)
+	adminToken, _ := e.user("admin")
+	memberToken, memberID := e.user("member")
+	strangerToken, _ := e.user("stranger")
+	group := e.group(adminToken, memberID)
+
+	admin, member := e.dial(adminToken), e.dial(memberToken)
+	send(t, admin, map[string]interface{}{"type": "group_message", "group_id": group, "content": "всем"})
+	if got := readType(t, member, "group_message"); got["content"] != "всем" {
+		t.Fatalf("участник получил %v", got)
+	}
+
+	path := fmt.Sprintf("/api/groups/messages?group_id=%d", group)
+	var msgs []json.RawMessage
+	e.do("GET", path, memberToken, nil, http.StatusOK, &msgs)
+	if len(msgs) != 1 {
+		t.Fatalf("история группы = %d сообщений", len(msgs))
+	}
+	e.do("GET", path, strangerToken, nil, http.StatusForbidden, nil)
+
+	var groups []struct {
+		ID int `json:"id"`
+	}
+	e.do("GET", "/api/groups", memberToken, nil, http.StatusOK, &groups)
+	if len(groups) != 1 || groups[0].ID != group {
+		t.Fatalf("группы участника = %+v", groups)
+	}
+}
+
+func TestSyncAfterPrune(t *testing.T) {
+	e := newTestEnv(t)
+	aliceToken, _ := e.user("alice")
+	bobToken, bobID := e.user("bob")
+	conv := e.conversation(aliceToken, bobID)
+
+	alice := e.dial(aliceToken)
+	send(t, alice, map[string]interface{}{"type": "message", "conversation_id": conv, "content": "x"})
+	readType(t, alice, "message")
+
+	bob := e.dial(bobToken)
+	send(t, bob, map[string]interface{}{"type": "sync", "last_seq": 0})
+	readType(t, bob, "message")
+	done := readType(t, bob, "sync_done")
+	last := int64(done["last_seq"].(float64))
+	if last == 0 || done["has_more"] == true {
+		t.Fatalf("sync_done = %v", done)
+	}
+
+	// лента очищена целиком: клиент, отставший от неё, получает sync_reset,
+	// а догнавший — sync_done без has_more
+	if _, err := e.s.Repos.Events.Prune(time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	send(t, bob, map[string]interface{}{"type": "sync", "last_seq": last - 1})
+	if got := readType(t, bob, "sync_reset"); int64(got["last_seq"].(float64)) != last {
+		t.Fatalf("sync_reset = %v", got)
+	}
+	send(t, bob, map[string]interface{}{"type": "sync", "last_seq": last})
+	if got := readType(t, bob, "sync_done"); got["has_more"] == true {
+		t.Fatalf("sync_done после Prune = %v", got)
+	}
+}
+
+func TestThreadReplies(t *testing.T) {
+	e := newTestEnv(t)
+	adminToken, _ := e.user("admin")
+	memberToken, memberID := e.user("member")
+	group := e.group(adminToken, memberID)
+	admin, member := e.dial(adminToken), e.dial(memberToken)
+
+	post := func(content string, rootID, replyToID int) map[string]interface{} {
+		t.Helper()
+		send(t, admin, map[string]interface{}{
+			"type": "group_message", "group_id": group, "content": content,
+			"thread_root_id": rootID, "reply_to_id": replyToID,
+		})
+		if rootID == 0 {
+			return readType(t, admin, "group_message")
+		}
+		return readType(t, admin, "thread_message")
+	}
+	root := int(post("корень", 0, 0)["message_id"].(float64))
+	outside := int(post("основная лента", 0, 0)["message_id"].(float64))
+	reply := int(post("ответ", root, root)["message_id"].(float64))
+	readType(t, member, "thread_update")
+
+	// цитировать внутри ветки сообщение основной ленты нельзя
+	send(t, admin, map[string]interface{}{
+		"type": "group_message", "group_id": group, "content": "x",
+		"thread_root_id": root, "reply_to_id": outside,
+	})
+	if got := readType(t, admin, "error"); got["code"] != "invalid_reply" {
+		t.Fatalf("ответ вне ветки: %v", got)
+	}
+
+	// правка и удаление ответа приходят с корнем, чтобы клиент нашёл ветку
+	e.do("POST", "/api/messages/edit", adminToken, map[string]interface{}{"message_id": reply, "group_id": group, "content": "правка"}, http.StatusOK, nil)
+	if got := readType(t, member, "message_edit"); got["thread_root_id"] != float64(root) {
+		t.Fatalf("message_edit ответа в ветке = %v", got)
+	}
+	e.do("POST", "/api/messages/delete", adminToken, map[string]int{"message_id": reply, "group_id": group}, http.StatusOK, nil)
+	if got := readType(t, member, "message_delete"); got["thread_root_id"] != float64(root) {
+		t.Fatalf("message_delete ответа в ветке = %v", got)
+	}
+	// ответов не осталось: нулевые счётчики в рассылке опускаются
+	update := readType(t, member, "thread_update")
+	if int(update["message_id"].(float64)) != root || update["thread_reply_count"] != nil || update["thread_last_reply_at"] != nil {
+		t.Fatalf("thread_update после удаления ответа = %v", update)
+	}
+}
+
+// pushRecorder запоминает push-уведомления вместо отправки
+type pushRecorder struct {
+	pushes chan map[string]string
+}
+
+func (p pushRecorder) Notify(userID int, data map[string]string) {
+	data["user_id"] = fmt.Sprint(userID)
+	p.pushes <- data
+}
+
+func (pushRecorder) Shutdown(ctx context.Context) error { return nil }
+
+func TestThreadReplyPush(t *testing.T) {
+	e := newTestEnv(t)
+	rec := pushRecorder{pushes: make(chan map[string]string, 10)}
+	e.s.Hub.Notifier = rec
+	adminToken, _ := e.user("admin")
+	memberToken, memberID := e.user("member")
+	group := e.group(adminToken, memberID)
+	admin, member := e.dial(adminToken), e.dial(memberToken)
+
+	// корень пишет участник и уходит из сети: ответ придёт ему push-ем
+	send(t, member, map[string]interface{}{"type": "group_message", "group_id": group, "content": "корень"})
+	root := int(readType(t, admin, "group_message")["message_id"].(float64))
+	for len(rec.pushes) > 0 {
+		<-rec.pushes
+	}
+	member.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	send(t, admin, map[string]interface{}{
+		"type": "group_message", "group_id": group, "content": "ответ", "thread_root_id": root,
+	})
+	readType(t, admin, "thread_message")
+	select {
+	case push := <-rec.pushes:
+		if push["type"] != "thread_message" || push["user_id"] != fmt.Sprint(memberID) ||
+			push["group_name"] != "группа" || push["thread_root_id"] != fmt.Sprint(root) {
+			t.Fatalf("push ответа в ветке = %v", push)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("push ответа в ветке не отправлен")
+	}
+}
+
+func TestForwardMessages(t *testing.T) {
+	e := newTestEnv(t)
+	aliceToken, _ := e.user("alice")
+	bobToken, bobID := e.user("bob")
+	conv := e.conversation(aliceToken, bobID)
+	group := e.group(aliceToken, bobID)
+	alice, bob := e.dial(aliceToken), e.dial(bobToken)
+
+	var sources []int
+	for _, content := range []string{"один", "два"} {
+		send(t, alice, map[string]interface{}{"type": "message", "conversation_id": conv, "content": content})
+		sources = append(sources, int(readType(t, alice, "message")["message_id"].(float64)))
+		readType(t, bob, "message")
+	}
+
+	var out struct {
+		MessageIDs []int `json:"message_ids"`
+	}
+	e.do("POST", "/api/messages/forward", aliceToken, map[string]interface{}{
+		"message_ids": []int{sources[1], sources[0]}, "source_conversation_id": conv, "group_id": group,
+	}, http.StatusOK, &out)
+	if len(out.MessageIDs) != 2 {
+		t.Fatalf("message_ids = %v", out.MessageIDs)
+	}
+	// копии приходят в порядке оригиналов и уже сохранёнными
+	for i, want := range []string{"один", "два"} {
+		got := readType(t, bob, "group_message")
+		if got["content"] != want || int(got["message_id"].(float64)) != out.MessageIDs[i] || got["forwarded_from"] == nil {
+			t.Fatalf("копия %d = %v", i, got)
+		}
+	}
+
+	e.do("POST", "/api/messages/forward", aliceToken, map[string]interface{}{
+		"message_ids": []int{sources[0], -1}, "source_conversation_id": conv, "group_id": group,
+	}, http.StatusNotFound, nil)
+	var msgs []json.RawMessage
+	e.do("GET", fmt.Sprintf("/api/groups/messages?group_id=%d", group), bobToken, nil, http.StatusOK, &msgs)
+	if len(msgs) != 2 {
+		t.Fatalf("в группе %d сообщений, ждали 2", len(msgs))
+	}
+}
@@ -0,0 +1,204 @@
+package http
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"your_project/internal/middleware"
+	"your_project/internal/models"
+	"your_project/internal/pkg/auth"
+	"your_project/internal/repository"
+)
+
+// startSession создаёт сессию входа и выдаёт для неё пару токенов
+func (s *Server) startSession(r *http.Request, userID int, username, deviceName string) (models.TokenPair, error) {
+	var pair models.TokenPair
+	sessionID, err := auth.NewSessionID()
+	if err != nil {
+		return pair, err
+	}
+	refresh, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		return pair, err
+	}
+	if deviceName == "" {
+		deviceName = r.UserAgent()
+	}
+	repo := s.Repos.Sessions
+	err = repo.Create(models.Session{
+		ID:         sessionID,
+		UserID:     userID,
+		DeviceName: deviceName,
+		IP:         s.clientIP(r),
+		UserAgent:  r.UserAgent(),
+		ExpiresAt:  time.Now().Add(auth.RefreshTokenTTL),
+	}, refreshHash)
+	if err != nil {
+		return pair, err
+	}
+	token, err := s.Tokens.IssueAccessToken(userID, username, sessionID)
+	if err != nil {
+		return pair, err
+	}
+	return models.TokenPair{
+		Token:        token,
+		RefreshToken: refresh,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+		SessionID:    sessionID,
+	}, nil
+}
+
+// clientIP — адрес клиента для сессий и счётчиков попыток входа.
+// X-Forwarded-For пишет кто угодно, поэтому он читается, только если
+// запрос пришёл от доверенного прокси (TRUSTED_PROXIES): цепочка идёт
+// справа налево, доверенные прокси пропускаются, и клиентом считается
+// первый адрес не из их числа. Иначе — RemoteAddr.
+func (s *Server) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !s.Config.TrustedProxy(ip) {
+		return ip
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !s.Config.TrustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// POST /api/auth/refresh — обмен refresh-токена на новую пару
+func (s *Server) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	refresh, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
+		return
+	}
+	repo := s.Repos.Sessions
+	session, username, err := repo.Rotate(auth.HashToken(req.RefreshToken), refreshHash, time.Now().Add(auth.RefreshTokenTTL))
+	switch err {
+	case nil:
+	case repository.ErrRefreshReused:
+		log.Printf("Повторное использование refresh-токена, сессия %s отозвана", session.ID)
+		s.Hub.CloseSession(session.UserID, session.ID)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case repository.ErrSessionNotFound:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	default:
+		http.Error(w, "Ошибка обновления токена", http.StatusInternalServerError)
+		return
+	}
+	token, err := s.Tokens.IssueAccessToken(session.UserID, username, session.ID)
+	if err != nil {
+		http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TokenPair{
+		Token:        token,
+		RefreshToken: refresh,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+		SessionID:    session.ID,
+	})
+}
+
+// POST /api/auth/logout — выход из текущей сессии
+func (s *Server) Logout(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	repo := s.Repos.Sessions
+	if _, err := repo.Revoke(claims.UserID, claims.SessionID); err != nil {
+		http.Error(w, "Ошибка выхода", http.StatusInternalServerError)
+		return
+	}
+	s.Hub.CloseSession(claims.UserID, claims.SessionID)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Сессия завершена"})
+}
+
+// POST /api/auth/logout-all — выход на всех устройствах, включая текущее;
+// завершить одну чужую сессию — POST /api/sessions/revoke
+func (s *Server) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	if err := s.revokeOtherSessions(claims.UserID, ""); err != nil {
+		http.Error(w, "Ошибка выхода", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "Все сессии завершены"})
+}
+
+// revokeOtherSessions отзывает все сессии пользователя, кроме exceptID
+// (пустой — все), и закрывает их сокеты
+func (s *Server) revokeOtherSessions(userID int, exceptID string) error {
+	repo := s.Repos.Sessions
+	ids, err := repo.RevokeAll(userID, exceptID)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		s.Hub.CloseSession(userID, id)
+	}
+	return nil
+}
+
+// GET /api/sessions — активные сессии пользователя
+func (s *Server) GetSessions(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	repo := s.Repos.Sessions
+	sessions, err := repo.ListActive(claims.UserID)
+	if err != nil {
+		http.Error(w, "Ошибка получения сессий", http.StatusInternalServerError)
+		return
+	}
+	if sessions == nil {
+		sessions = []models.Session{}
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == claims.SessionID
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// POST /api/sessions/revoke — завершить сессию на другом устройстве
+func (s *Server) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
+	var req struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.SessionID == "" {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	repo := s.Repos.Sessions
+	revoked, err := repo.Revoke(claims.UserID, req.SessionID)
+	if err != nil {
+		http.Error(w, "Ошибка отзыва сессии", http.StatusInternalServerError)
+		return
+	}
+	if !revoked {
+		http.Error(w, repository.ErrSessionNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	s.Hub.CloseSession(claims.UserID, req.SessionID)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Сессия завершена"})
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"your_project/internal/config"
+)
+
+func TestClientIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	s := &Server{Config: &config.Config{TrustedProxies: []*net.IPNet{proxies}}}
+
+	for _, tc := range []struct {
+		name, remote, xff, want string
+	}{
+		{"без прокси", "203.0.113.7:5000", "", "203.0.113.7"},
+		{"XFF от клиента не читается", "203.0.113.7:5000", "198.51.100.1", "203.0.113.7"},
+		{"доверенный прокси", "10.0.0.2:5000", "198.51.100.1", "198.51.100.1"},
+		{"подделка слева отбрасывается", "10.0.0.2:5000", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"цепочка доверенных прокси", "10.0.0.2:5000", "198.51.100.1, 10.0.0.3", "198.51.100.1"},
+		{"доверенный прокси без XFF", "10.0.0.2:5000", "", "10.0.0.2"},
+		{"мусор в XFF", "10.0.0.2:5000", "not-an-ip", "10.0.0.2"},
+		{"все адреса — прокси", "10.0.0.2:5000", "10.0.0.4, 10.0.0.3", "10.0.0.4"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tc.remote
+		if tc.xff != "" {
+			r.Header.Set("X-Forwarded-For", tc.xff)
+		}
+		if got := s.clientIP(r); got != tc.want {
+			t.Errorf("%s: %q, ждали %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLogoutAllRevokesCurrentSession(t *testing.T) {
+	e := newTestEnv(t)
+	current, _ := e.user("alice")
+	var other struct {
+		Token string `json:"token"`
+	}
+	e.do("POST", "/api/login", "", map[string]string{"username": "alice", "password": "secret123"}, http.StatusOK, &other)
+	conn := e.dial(current)
+
+	var out struct {
+		Message string `json:"message"`
+	}
+	e.do("POST", "/api/auth/logout-all", current, nil, http.StatusOK, &out)
+	if out.Message != "Все сессии завершены" {
+		t.Fatalf("ответ %q", out.Message)
+	}
+	for _, token := range []string{current, other.Token} {
+		e.do("GET", "/api/profile/me", token, nil, http.StatusUnauthorized, nil)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			// 4001 — closeSessionRevoked пакета ws
+			if !websocket.IsCloseError(err, 4001) {
+				t.Fatalf("сокет текущей сессии закрыт не отзывом: %v", err)
+			}
+			break
+		}
+	}
+}
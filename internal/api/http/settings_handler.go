@@ -1,41 +1,70 @@
 package http
 
 import (
+	"database/sql"
 	"encoding/json"
+	"log"
 	"net/http"
+	"regexp"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
-	"your_project/internal/pkg/database"
+	"your_project/internal/middleware"
+	"your_project/internal/models"
+	"your_project/internal/repository"
 )
 
-// POST /api/settings/change-password
-func ChangePassword(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
+// AccountDeletionGrace — сколько можно передумать после запроса на удаление
+const AccountDeletionGrace = 7 * 24 * time.Hour
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.]{3,50}$`)
+
+// validUsername: латиница, цифры, _ и точка. Префикс deleted_ занят под
+// удалённые аккаунты.
+func validUsername(username string) bool {
+	return usernamePattern.MatchString(username) && !strings.HasPrefix(strings.ToLower(username), "deleted_")
+}
+
+// checkPassword загружает пользователя и сверяет пароль; при ошибке ответ уже записан
+func (s *Server) checkPassword(w http.ResponseWriter, userID int, password string) (*models.User, bool) {
+	users := s.Repos.Users
+	user, err := users.GetUserByID(userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `{"error":"Пользователь не найден"}`, http.StatusNotFound)
+		return nil, false
+	}
 	if err != nil {
-		http.Error(w, `{"error":"Не авторизован"}`, http.StatusUnauthorized)
-		return
+		http.Error(w, `{"error":"Ошибка"}`, http.StatusInternalServerError)
+		return nil, false
 	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		http.Error(w, `{"error":"Неверный пароль"}`, http.StatusUnauthorized)
+		return nil, false
+	}
+	return user, true
+}
+
+// POST /api/settings/change-password
+// Остальные сессии пользователя завершаются, текущая остаётся.
+func (s *Server) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r)
 
 	var req struct {
 		OldPassword string `json:"old_password"`
 		NewPassword string `json:"new_password"`
 	}
-	json.NewDecoder(r.Body).Decode(&req)
-
-	if len(req.NewPassword) < 6 {
-		http.Error(w, `{"error":"Пароль должен быть не менее 6 символов"}`, http.StatusBadRequest)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Неверный формат данных"}`, http.StatusBadRequest)
 		return
 	}
 
-	var hashedPassword string
-	err = database.DB.QueryRow(`SELECT password_hash FROM users WHERE id=$1`, userID).Scan(&hashedPassword)
-	if err != nil {
-		http.Error(w, `{"error":"Пользователь не найден"}`, http.StatusNotFound)
+	if len(req.NewPassword) < 6 {
+		http.Error(w, `{"error":"Пароль должен быть не менее 6 символов"}`, http.StatusBadRequest)
 		return
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(req.OldPassword)); err != nil {
-		http.Error(w, `{"error":"Неверный текущий пароль"}`, http.StatusUnauthorized)
+	if _, ok := s.checkPassword(w, claims.UserID, req.OldPassword); !ok {
 		return
 	}
 
@@ -45,39 +74,141 @@ func ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	database.DB.Exec(`UPDATE users SET password_hash=$1 WHERE id=$2`, string(newHash), userID)
+	users := s.Repos.Users
+	if err := users.SetPassword(claims.UserID, string(newHash)); err != nil {
+		http.Error(w, `{"error":"Ошибка"}`, http.StatusInternalServerError)
+		return
+	}
+	if err := s.revokeOtherSessions(claims.UserID, claims.SessionID); err != nil {
+		log.Printf("Ошибка отзыва сессий user %d после смены пароля: %v", claims.UserID, err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(`{"success":true}`))
 }
 
-// DELETE /api/settings/delete-account
-func DeleteAccount(w http.ResponseWriter, r *http.Request) {
-	userID, _, err := parseJWTFromRequest(r)
-	if err != nil {
-		http.Error(w, `{"error":"Не авторизован"}`, http.StatusUnauthorized)
+// POST /api/settings/change-username
+func (s *Server) ChangeUsername(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Неверный формат данных"}`, http.StatusBadRequest)
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if !validUsername(req.Username) {
+		http.Error(w, `{"error":"Имя: 3–50 символов, латиница, цифры, _ и точка"}`, http.StatusBadRequest)
+		return
+	}
+
+	// имя — логин, поэтому без пароля его не меняем
+	if _, ok := s.checkPassword(w, userID, req.Password); !ok {
 		return
 	}
 
+	users := s.Repos.Users
+	if err := users.SetUsername(userID, req.Username); err != nil {
+		if err == repository.ErrUsernameTaken {
+			http.Error(w, `{"error":"Имя пользователя занято"}`, http.StatusConflict)
+			return
+		}
+		http.Error(w, `{"error":"Ошибка"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "username": req.Username})
+}
+
+// DELETE /api/settings/delete-account
+// Аккаунт не удаляется сразу: в течение AccountDeletionGrace запрос можно
+// отменить через /api/settings/cancel-deletion, затем его обезличивает
+// PurgeDeletedAccounts.
+func (s *Server) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
 	var req struct {
 		Password string `json:"password"`
 	}
-	json.NewDecoder(r.Body).Decode(&req)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Неверный формат данных"}`, http.StatusBadRequest)
+		return
+	}
 
-	var hashedPassword string
-	database.DB.QueryRow(`SELECT password_hash FROM users WHERE id=$1`, userID).Scan(&hashedPassword)
+	if _, ok := s.checkPassword(w, userID, req.Password); !ok {
+		return
+	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(req.Password)); err != nil {
-		http.Error(w, `{"error":"Неверный пароль"}`, http.StatusUnauthorized)
+	users := s.Repos.Users
+	requestedAt, err := users.RequestDeletion(userID)
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка"}`, http.StatusInternalServerError)
 		return
 	}
 
-	// Каскадно удаляем всё
-	database.DB.Exec(`DELETE FROM group_members WHERE user_id=$1`, userID)
-	database.DB.Exec(`DELETE FROM messages WHERE sender_id=$1`, userID)
-	database.DB.Exec(`DELETE FROM group_messages WHERE sender_id=$1`, userID)
-	database.DB.Exec(`DELETE FROM users WHERE id=$1`, userID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"delete_after": requestedAt.Add(AccountDeletionGrace),
+	})
+}
+
+// POST /api/settings/cancel-deletion
+func (s *Server) CancelAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+
+	users := s.Repos.Users
+	cancelled, err := users.CancelDeletion(userID)
+	if err != nil {
+		http.Error(w, `{"error":"Ошибка"}`, http.StatusInternalServerError)
+		return
+	}
+	if !cancelled {
+		http.Error(w, `{"error":"Удаление не запрошено"}`, http.StatusNotFound)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(`{"success":true}`))
 }
+
+// PurgeDeletedAccounts раз в час обезличивает аккаунты, у которых истёк
+// срок отмены удаления
+func (s *Server) PurgeDeletedAccounts() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		s.purgeDueAccounts(time.Now())
+	}
+}
+
+// purgeDueAccounts обезличивает аккаунты, удаление которых запрошено раньше
+// now-AccountDeletionGrace, и завершает их сессии
+func (s *Server) purgeDueAccounts(now time.Time) {
+	users := s.Repos.Users
+	ids, err := users.DueForDeletion(now.Add(-AccountDeletionGrace))
+	if err != nil {
+		log.Println("Ошибка поиска аккаунтов на удаление:", err)
+		return
+	}
+	for _, id := range ids {
+		if err := s.revokeOtherSessions(id, ""); err != nil {
+			log.Printf("Ошибка отзыва сессий удаляемого user %d: %v", id, err)
+			continue
+		}
+		if err := users.SoftDelete(id); err != nil {
+			log.Printf("Ошибка удаления user %d: %v", id, err)
+			continue
+		}
+		log.Printf("Аккаунт %d удалён", id)
+	}
+}
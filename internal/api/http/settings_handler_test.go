@@ -0,0 +1,93 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestChangePassword(t *testing.T) {
+	e := newTestEnv(t)
+	token, _ := e.user("alice")
+	var other struct {
+		Token string `json:"token"`
+	}
+	e.do("POST", "/api/login", "", map[string]string{"username": "alice", "password": "secret123"}, http.StatusOK, &other)
+
+	e.do("POST", "/api/settings/change-password", token, map[string]string{
+		"old_password": "wrong", "new_password": "newsecret",
+	}, http.StatusUnauthorized, nil)
+	e.do("POST", "/api/settings/change-password", token, map[string]string{
+		"old_password": "secret123", "new_password": "short",
+	}, http.StatusBadRequest, nil)
+	e.do("POST", "/api/settings/change-password", token, map[string]string{
+		"old_password": "secret123", "new_password": "newsecret",
+	}, http.StatusOK, nil)
+
+	e.do("POST", "/api/login", "", map[string]string{"username": "alice", "password": "secret123"}, http.StatusUnauthorized, nil)
+	e.do("POST", "/api/login", "", map[string]string{"username": "alice", "password": "newsecret"}, http.StatusOK, nil)
+	// текущая сессия остаётся, остальные завершены
+	e.do("GET", "/api/profile/me", token, nil, http.StatusOK, nil)
+	e.do("GET", "/api/profile/me", other.Token, nil, http.StatusUnauthorized, nil)
+}
+
+func TestChangeUsername(t *testing.T) {
+	e := newTestEnv(t)
+	token, _ := e.user("alice")
+	e.user("bob")
+
+	e.do("POST", "/api/settings/change-username", token, map[string]string{"username": "bob", "password": "secret123"}, http.StatusConflict, nil)
+	e.do("POST", "/api/settings/change-username", token, map[string]string{"username": "BOB", "password": "secret123"}, http.StatusConflict, nil)
+	e.do("POST", "/api/settings/change-username", token, map[string]string{"username": "carol", "password": "wrong"}, http.StatusUnauthorized, nil)
+	e.do("POST", "/api/settings/change-username", token, map[string]string{"username": "deleted_1", "password": "secret123"}, http.StatusBadRequest, nil)
+
+	var out struct {
+		Username string `json:"username"`
+	}
+	e.do("POST", "/api/settings/change-username", token, map[string]string{"username": "carol", "password": "secret123"}, http.StatusOK, &out)
+	if out.Username != "carol" {
+		t.Fatalf("ответ = %+v", out)
+	}
+	e.do("POST", "/api/login", "", map[string]string{"username": "carol", "password": "secret123"}, http.StatusOK, nil)
+	e.do("POST", "/api/login", "", map[string]string{"username": "alice", "password": "secret123"}, http.StatusUnauthorized, nil)
+}
+
+func TestDeleteAccount(t *testing.T) {
+	e := newTestEnv(t)
+	token, id := e.user("alice")
+
+	e.do("DELETE", "/api/settings/delete-account", token, map[string]string{"password": "wrong"}, http.StatusUnauthorized, nil)
+	e.do("POST", "/api/settings/cancel-deletion", token, nil, http.StatusNotFound, nil)
+
+	var out struct {
+		DeleteAfter time.Time `json:"delete_after"`
+	}
+	e.do("DELETE", "/api/settings/delete-account", token, map[string]string{"password": "secret123"}, http.StatusOK, &out)
+	if wait := time.Until(out.DeleteAfter); wait < AccountDeletionGrace-time.Minute || wait > AccountDeletionGrace {
+		t.Fatalf("delete_after через %v, ждали %v", wait, AccountDeletionGrace)
+	}
+
+	// отменённый запрос очистка не трогает
+	e.do("POST", "/api/settings/cancel-deletion", token, nil, http.StatusOK, nil)
+	e.do("POST", "/api/settings/cancel-deletion", token, nil, http.StatusNotFound, nil)
+	e.s.purgeDueAccounts(time.Now().Add(AccountDeletionGrace + time.Minute))
+	e.do("GET", "/api/profile/me", token, nil, http.StatusOK, nil)
+
+	// пока срок не вышел, аккаунт жив
+	e.do("DELETE", "/api/settings/delete-account", token, map[string]string{"password": "secret123"}, http.StatusOK, nil)
+	e.s.purgeDueAccounts(time.Now())
+	e.do("GET", "/api/profile/me", token, nil, http.StatusOK, nil)
+
+	e.s.purgeDueAccounts(time.Now().Add(AccountDeletionGrace + time.Minute))
+	e.do("GET", "/api/profile/me", token, nil, http.StatusUnauthorized, nil)
+	e.do("POST", "/api/login", "", map[string]string{"username": "alice", "password": "secret123"}, http.StatusUnauthorized, nil)
+	u, err := e.s.Repos.Users.GetUserByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Username == "alice" || u.Password != "" {
+		t.Fatalf("аккаунт после очистки = %+v", u)
+	}
+	// имя освободилось
+	e.user("alice")
+}
@@ -0,0 +1,49 @@
+package http
+
+import (
+	"testing"
+)
+
+func TestCallRoomAccess(t *testing.T) {
+	e := newTestEnv(t)
+	aliceToken, aliceID := e.user("alice")
+	bobToken, bobID := e.user("bob")
+	malloryToken, _ := e.user("mallory")
+	group := e.group(aliceToken, bobID)
+
+	alice, bob, mallory := e.dial(aliceToken), e.dial(bobToken), e.dial(malloryToken)
+
+	wantForbidden := func(typ string) {
+		t.Helper()
+		got := readType(t, mallory, "error")
+		if got["request_type"] != typ || got["code"] != "forbidden" {
+			t.Fatalf("чужой %s: %v", typ, got)
+		}
+	}
+
+	// Личный звонок: комната закреплена за alice и bob
+	send(t, alice, map[string]interface{}{"type": "call_offer", "to": bobID, "room_id": "direct", "sdp": "offer"})
+	readType(t, bob, "call_offer")
+	send(t, mallory, map[string]interface{}{"type": "call_answer", "to": aliceID, "room_id": "direct", "sdp": "answer"})
+	wantForbidden("call_answer")
+	send(t, mallory, map[string]interface{}{"type": "call_offer", "to": aliceID, "room_id": "direct", "sdp": "offer"})
+	wantForbidden("call_offer")
+	send(t, bob, map[string]interface{}{"type": "call_answer", "to": aliceID, "room_id": "direct", "sdp": "answer"})
+	if got := readType(t, alice, "call_answer"); got["from"] != float64(bobID) {
+		t.Fatalf("ответ на звонок = %v", got)
+	}
+
+	// Групповой звонок: ICE по комнате рассылают только приглашённые
+	send(t, alice, map[string]interface{}{"type": "call_offer", "group_id": group, "room_id": "group", "sdp": "offer"})
+	readType(t, bob, "call_offer")
+	send(t, bob, map[string]interface{}{"type": "call_answer", "to": aliceID, "room_id": "group", "sdp": "answer"})
+	readType(t, alice, "call_answer")
+	send(t, mallory, map[string]interface{}{"type": "call_answer", "room_id": "group", "sdp": "answer"})
+	wantForbidden("call_answer")
+	send(t, mallory, map[string]interface{}{"type": "ice_candidate", "room_id": "group", "candidate": "evil"})
+	wantForbidden("ice_candidate")
+	send(t, alice, map[string]interface{}{"type": "ice_candidate", "room_id": "group", "candidate": "c1"})
+	if got := readType(t, bob, "ice_candidate"); got["from"] != float64(aliceID) || got["candidate"] != "c1" {
+		t.Fatalf("ICE в комнате = %v", got)
+	}
+}
@@ -0,0 +1,311 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"your_project/internal/middleware"
+	"your_project/internal/models"
+	"your_project/internal/pkg/auth"
+)
+
+const recoveryCodeCount = 10
+
+// twoFactorChallenge отвечает на верный пароль, если у пользователя включена 2FA.
+// false — 2FA выключена и вход можно завершать сразу.
+func (s *Server) twoFactorChallenge(w http.ResponseWriter, userID int) bool {
+	repo := s.Repos.TwoFactor
+	st, err := repo.Get(userID)
+	if err != nil {
+		http.Error(w, "Ошибка проверки 2FA", http.StatusInternalServerError)
+		return true
+	}
+	if !st.Enabled {
+		return false
+	}
+	token, err := s.Tokens.IssueChallengeToken(userID)
+	if err != nil {
+		http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TwoFactorChallenge{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresIn:         int(auth.ChallengeTokenTTL.Seconds()),
+	})
+	return true
+}
+
+// checkTOTP проверяет код по секрету пользователя и гасит его шаг,
+// чтобы перехваченный код нельзя было использовать повторно
+func (s *Server) checkTOTP(userID int, secret, code string) (bool, error) {
+	step, ok := auth.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return s.Repos.TwoFactor.UseStep(userID, step)
+}
+
+// POST /api/login/2fa — второй шаг входа
+func (s *Server) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req models.TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	userID, err := s.Tokens.ParseChallengeToken(req.ChallengeToken)
+	if err != nil {
+		http.Error(w, "Сессия входа истекла, войдите заново", http.StatusUnauthorized)
+		return
+	}
+	repo := s.Repos.TwoFactor
+	st, err := repo.Get(userID)
+	if err != nil || !st.Enabled {
+		http.Error(w, "Сессия входа истекла, войдите заново", http.StatusUnauthorized)
+		return
+	}
+	users := s.Repos.Users
+	user, err := users.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Пользователь не найден", http.StatusUnauthorized)
+		return
+	}
+	keys := twoFactorKeys(userID, s.clientIP(r))
+	if wait, locked := s.Guard.Check(keys...); wait > 0 {
+		tooManyAttempts(w, wait, locked)
+		return
+	}
+
+	var ok bool
+	switch {
+	case req.Code != "":
+		ok, err = s.checkTOTP(userID, st.Secret, req.Code)
+	case req.RecoveryCode != "":
+		ok, err = repo.UseRecoveryCode(userID, auth.HashToken(auth.NormalizeRecoveryCode(req.RecoveryCode)))
+	default:
+		http.Error(w, "Нужен код подтверждения", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Ошибка проверки кода", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		locked := s.Guard.Fail(keys...)
+		s.recordLoginFailure(r, userID, user.Username, models.LoginBad2FACode, locked)
+		http.Error(w, "Неверный код", http.StatusUnauthorized)
+		return
+	}
+	s.Guard.Reset(keys[0])
+
+	tokens, err := s.startSession(r, user.ID, user.Username, req.DeviceName)
+	if err != nil {
+		http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
+		return
+	}
+	user.Password = ""
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.LoginResponse{TokenPair: tokens, User: *user})
+}
+
+// GET /api/2fa/status
+func (s *Server) GetTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	repo := s.Repos.TwoFactor
+	st, err := repo.Get(userID)
+	if err != nil {
+		http.Error(w, "Ошибка получения настроек 2FA", http.StatusInternalServerError)
+		return
+	}
+	status := models.TwoFactorStatus{Enabled: st.Enabled}
+	if st.Enabled {
+		status.RecoveryCodesLeft, _ = repo.RemainingRecoveryCodes(userID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// POST /api/2fa/setup — новый секрет для приложения-аутентификатора.
+// 2FA включается только после подтверждения кода через /api/2fa/enable.
+func (s *Server) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	username := middleware.GetUsername(r)
+	repo := s.Repos.TwoFactor
+	st, err := repo.Get(userID)
+	if err != nil {
+		http.Error(w, "Ошибка получения настроек 2FA", http.StatusInternalServerError)
+		return
+	}
+	if st.Enabled {
+		http.Error(w, "2FA уже включена", http.StatusConflict)
+		return
+	}
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		http.Error(w, "Ошибка генерации секрета", http.StatusInternalServerError)
+		return
+	}
+	if err := repo.SetPendingSecret(userID, secret); err != nil {
+		http.Error(w, "Ошибка сохранения секрета", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.TwoFactorSetup{
+		Secret:     secret,
+		OTPAuthURI: auth.TOTPURI(secret, username),
+	})
+}
+
+// POST /api/2fa/enable {code} — подтверждение секрета первым кодом;
+// в ответе коды восстановления, которые больше не показываются
+func (s *Server) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+		return
+	}
+	repo := s.Repos.TwoFactor
+	st, err := repo.Get(userID)
+	if err != nil {
+		http.Error(w, "Ошибка получения настроек 2FA", http.StatusInternalServerError)
+		return
+	}
+	if st.Enabled {
+		http.Error(w, "2FA уже включена", http.StatusConflict)
+		return
+	}
+	if st.Secret == "" {
+		http.Error(w, "Сначала вызовите /api/2fa/setup", http.StatusBadRequest)
+		return
+	}
+	ok, err := s.checkTOTP(userID, st.Secret, req.Code)
+	if err != nil {
+		http.Error(w, "Ошибка проверки кода", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "Неверный код", http.StatusUnauthorized)
+		return
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Ошибка генерации кодов", http.StatusInternalServerError)
+		return
+	}
+	if err := repo.Enable(userID, hashes); err != nil {
+		http.Error(w, "Ошибка включения 2FA", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RecoveryCodes{RecoveryCodes: codes})
+}
+
+// POST /api/2fa/disable {password, code}
+func (s *Server) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" || req.Code == "" {
+		http.Error(w, "Нужны пароль и код", http.StatusBadRequest)
+		return
+	}
+	if !s.confirmPasswordAndCode(w, userID, req.Password, req.Code) {
+		return
+	}
+	repo := s.Repos.TwoFactor
+	if err := repo.Disable(userID); err != nil {
+		http.Error(w, "Ошибка отключения 2FA", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "2FA отключена"})
+}
+
+// POST /api/2fa/recovery-codes {password, code} — выпустить новые коды
+// восстановления взамен старых
+func (s *Server) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" || req.Code == "" {
+		http.Error(w, "Нужны пароль и код", http.StatusBadRequest)
+		return
+	}
+	if !s.confirmPasswordAndCode(w, userID, req.Password, req.Code) {
+		return
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Ошибка генерации кодов", http.StatusInternalServerError)
+		return
+	}
+	repo := s.Repos.TwoFactor
+	if err := repo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		http.Error(w, "Ошибка сохранения кодов", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RecoveryCodes{RecoveryCodes: codes})
+}
+
+// confirmPasswordAndCode проверяет пароль и текущий TOTP-код перед изменением
+// настроек 2FA; при ошибке ответ уже записан
+func (s *Server) confirmPasswordAndCode(w http.ResponseWriter, userID int, password, code string) bool {
+	users := s.Repos.Users
+	user, err := users.GetUserByID(userID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Пользователь не найден", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Ошибка получения пользователя", http.StatusInternalServerError)
+		return false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		http.Error(w, "Неверный пароль", http.StatusUnauthorized)
+		return false
+	}
+	repo := s.Repos.TwoFactor
+	st, err := repo.Get(userID)
+	if err != nil {
+		http.Error(w, "Ошибка получения настроек 2FA", http.StatusInternalServerError)
+		return false
+	}
+	if !st.Enabled {
+		http.Error(w, "2FA не включена", http.StatusBadRequest)
+		return false
+	}
+	ok, err := s.checkTOTP(userID, st.Secret, code)
+	if err != nil {
+		http.Error(w, "Ошибка проверки кода", http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		http.Error(w, "Неверный код", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	codes, err = auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes = make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = auth.HashToken(auth.NormalizeRecoveryCode(c))
+	}
+	return codes, hashes, nil
+}
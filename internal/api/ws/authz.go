@@ -0,0 +1,19 @@
+package ws
+
+import (
+	"log"
+	"your_project/internal/authz"
+)
+
+// sendAuthzError переводит отказ Authorizer в событие error для клиента
+func (c *Client) sendAuthzError(requestType string, err error) {
+	switch code := authz.Code(err); code {
+	case authz.CodeBlocked:
+		c.sendError(requestType, code, blockedErrorText)
+	case authz.CodeForbidden:
+		c.sendError(requestType, code, err.Error())
+	default:
+		log.Println("Ошибка проверки доступа:", err)
+		c.sendError(requestType, code, "Не удалось проверить доступ")
+	}
+}
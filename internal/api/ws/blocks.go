@@ -0,0 +1,26 @@
+package ws
+
+import "log"
+
+const blockedErrorText = "Пользователь заблокирован"
+
+// IsBlockedBetween — есть ли блокировка между двумя пользователями в любую сторону.
+// При ошибке БД считаем, что есть: лучше не доставить, чем доставить заблокированному.
+func (h *Hub) IsBlockedBetween(userA, userB int) bool {
+	blocked, err := h.Repos.Blocks.IsBlockedBetween(userA, userB)
+	if err != nil {
+		log.Println("Ошибка проверки блокировки:", err)
+		return true
+	}
+	return blocked
+}
+
+// blockersOf — кто заблокировал userID; им не шлём звонки и push от него
+func (h *Hub) blockersOf(userID int) map[int]bool {
+	blockers, err := h.Repos.Blocks.BlockersOf(userID)
+	if err != nil {
+		log.Println("Ошибка получения блокировок:", err)
+		return map[int]bool{}
+	}
+	return blockers
+}
@@ -0,0 +1,170 @@
+package ws
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Envelope — единица доставки между узлами: данные для всех устройств
+// пользователя либо команда закрыть сокет сессии
+type Envelope struct {
+	UserID       int             `json:"user_id"`
+	Data         json.RawMessage `json:"data,omitempty"`
+	CloseSession string          `json:"close_session,omitempty"`
+}
+
+// Broker маршрутизирует доставку, присутствие и комнаты звонков между узлами.
+// Hub публикует через брокер, а брокер вызывает обработчик Subscribe на каждом
+// узле, где могут быть подключения получателя.
+type Broker interface {
+	Publish(env Envelope) error
+	Subscribe(handler func(Envelope)) error
+	// Watch/Unwatch — на узле появилось/закрылось подключение пользователя:
+	// конверты для него нужно получать/больше не нужно. Вызовы парные и
+	// считаются, поэтому их порядок между разными подключениями не важен.
+	Watch(userID int) error
+	Unwatch(userID int) error
+
+	// SetPresence отмечает устройство онлайн (с TTL) или оффлайн
+	SetPresence(userID int, deviceID string, online bool) error
+	IsOnline(userID int) bool
+
+	// CreateRoom закрепляет комнату за звонящим и приглашёнными; false —
+	// комната с таким ID уже есть, и её состав не меняется
+	CreateRoom(roomID string, invitees []int) (bool, error)
+	IsInvited(roomID string, userID int) (bool, error)
+	JoinRoom(roomID string, userID int) error
+	// LeaveRoom возвращает число оставшихся участников; из опустевшей комнаты
+	// уходят и приглашения
+	LeaveRoom(roomID string, userID int) (int, error)
+	RoomMembers(roomID string) ([]int, error)
+
+	Close() error
+}
+
+// presenceTTL — сколько устройство считается онлайн без продления присутствия
+const presenceTTL = 2 * time.Minute
+
+// LocalBroker — брокер для одного процесса, без внешних зависимостей
+type LocalBroker struct {
+	mu       sync.RWMutex
+	handler  func(Envelope)
+	presence map[int]map[string]bool
+	rooms    map[string]map[int]bool
+	invited  map[string]map[int]bool
+}
+
+func NewLocalBroker() *LocalBroker {
+	return &LocalBroker{
+		presence: make(map[int]map[string]bool),
+		rooms:    make(map[string]map[int]bool),
+		invited:  make(map[string]map[int]bool),
+	}
+}
+
+func (b *LocalBroker) Publish(env Envelope) error {
+	b.mu.RLock()
+	handler := b.handler
+	b.mu.RUnlock()
+	if handler != nil {
+		handler(env)
+	}
+	return nil
+}
+
+func (b *LocalBroker) Subscribe(handler func(Envelope)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handler = handler
+	return nil
+}
+
+// Watch и Unwatch не нужны: в одном процессе все конверты и так свои
+func (b *LocalBroker) Watch(userID int) error   { return nil }
+func (b *LocalBroker) Unwatch(userID int) error { return nil }
+
+func (b *LocalBroker) SetPresence(userID int, deviceID string, online bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	devices, ok := b.presence[userID]
+	if !online {
+		if ok {
+			delete(devices, deviceID)
+			if len(devices) == 0 {
+				delete(b.presence, userID)
+			}
+		}
+		return nil
+	}
+	if !ok {
+		devices = make(map[string]bool)
+		b.presence[userID] = devices
+	}
+	devices[deviceID] = true
+	return nil
+}
+
+func (b *LocalBroker) IsOnline(userID int) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.presence[userID]) > 0
+}
+
+func (b *LocalBroker) CreateRoom(roomID string, invitees []int) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.invited[roomID]; ok {
+		return false, nil
+	}
+	invited := make(map[int]bool, len(invitees))
+	for _, uid := range invitees {
+		invited[uid] = true
+	}
+	b.invited[roomID] = invited
+	return true, nil
+}
+
+func (b *LocalBroker) IsInvited(roomID string, userID int) (bool, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.invited[roomID][userID], nil
+}
+
+func (b *LocalBroker) JoinRoom(roomID string, userID int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	room, ok := b.rooms[roomID]
+	if !ok {
+		room = make(map[int]bool)
+		b.rooms[roomID] = room
+	}
+	room[userID] = true
+	return nil
+}
+
+func (b *LocalBroker) LeaveRoom(roomID string, userID int) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	room := b.rooms[roomID]
+	delete(room, userID)
+	if len(room) == 0 {
+		delete(b.rooms, roomID)
+		delete(b.invited, roomID)
+	}
+	return len(room), nil
+}
+
+func (b *LocalBroker) RoomMembers(roomID string) ([]int, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	var members []int
+	for uid := range b.rooms[roomID] {
+		members = append(members, uid)
+	}
+	return members, nil
+}
+
+func (b *LocalBroker) Close() error {
+	return nil
+}
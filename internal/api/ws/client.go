@@ -1,11 +1,13 @@
 package ws
 
 import (
-	"database/sql"
 	"encoding/json"
 	"log"
+	"strings"
 	"time"
+	"your_project/internal/authz"
 	"your_project/internal/models"
+	"your_project/internal/repository"
 
 	"github.com/gorilla/websocket"
 )
@@ -13,20 +15,27 @@ import (
 type Client struct {
 	UserID   int
 	Username string
-	Conn     *websocket.Conn
-	Send     chan []byte
-	Hub      *Hub
-	DB       *sql.DB
+	DeviceID string
+	// SessionID — сессия входа, по которой открыт сокет; отзыв сессии закрывает сокет
+	SessionID string
+	Conn      *websocket.Conn
+	Send      chan []byte
+	Hub       *Hub
+	// когда последний раз пересылали typing_start по чату; только из ReadPump
+	typingSent map[string]time.Time
 }
 
-func NewClientWithConn(hub *Hub, conn *websocket.Conn, userID int, username string) *Client {
+func NewClientWithConn(hub *Hub, conn *websocket.Conn, userID int, username, deviceID, sessionID string) *Client {
 	return &Client{
-		UserID:   userID,
-		Username: username,
-		Conn:     conn,
-		Send:     make(chan []byte, 256),
-		Hub:      hub,
-		DB:       hub.DB,
+		UserID:    userID,
+		Username:  username,
+		DeviceID:  deviceID,
+		SessionID: sessionID,
+		Conn:      conn,
+		Send:      make(chan []byte, 256),
+		Hub:       hub,
+
+		typingSent: make(map[string]time.Time),
 	}
 }
 
@@ -34,6 +43,7 @@ func (c *Client) ReadPump() {
 	defer func() {
 		c.Hub.Unregister(c)
 		c.Conn.Close()
+		c.Hub.pumps.Done()
 	}()
 	c.Conn.SetReadLimit(1024 * 1024)
 	c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -58,106 +68,199 @@ func (c *Client) ReadPump() {
 			c.handlePersonalMessage(msg)
 		case "group_message":
 			c.handleGroupMessage(msg)
+		case "message_edit":
+			c.handleMessageEdit(msg)
+		case "message_delete":
+			c.handleMessageDelete(msg)
+		case "message_delivered", "message_read":
+			c.handleReceipt(msg)
+		case "reaction_add", "reaction_remove":
+			c.handleReaction(msg)
+		case "sync":
+			c.handleSync(msg)
+		case "typing_start", "typing_stop":
+			c.handleTyping(msg)
 		case "call_offer", "call_answer", "call_reject", "call_end", "ice_candidate":
 			var signal SignalMessage
 			json.Unmarshal(message, &signal)
 			signal.From = c.UserID
 			signal.CallerName = c.Username
+			if err := c.authorizeSignal(signal); err != nil {
+				c.sendAuthzError(signal.Type, err)
+				continue
+			}
 			HandleSignaling(c.Hub, c, signal)
 		}
 	}
 }
 
 func (c *Client) handlePersonalMessage(msg models.WSMessage) {
-	var senderUsername string
-	c.DB.QueryRow(`SELECT username FROM users WHERE id=$1`, c.UserID).Scan(&senderUsername)
-	_, err := c.DB.Exec(
-		`INSERT INTO messages (conversation_id, sender_id, content, media_url, media_type) VALUES ($1, $2, $3, $4, $5)`,
-		msg.ConversationID, c.UserID, msg.Content, msg.MediaURL, msg.MediaType,
-	)
-	if err != nil {
-		log.Println("Ошибка сохранения сообщения:", err)
+	if err := c.Hub.Authz.CanWriteConversation(c.UserID, msg.ConversationID); err != nil {
+		c.sendAuthzError(msg.Type, err)
 		return
 	}
-	response := models.WSMessage{
-		Type: "message", ConversationID: msg.ConversationID,
-		Content: msg.Content, MediaURL: msg.MediaURL, MediaType: msg.MediaType,
-		SenderID: c.UserID, SenderUsername: senderUsername,
+	replyTo, ok := c.replyPreview(msg.Type, models.ChatDirect, msg.ConversationID, msg.ReplyToID)
+	if !ok {
+		return
 	}
-	data, _ := json.Marshal(response)
-	rows, err := c.DB.Query(`SELECT user_id FROM conversation_members WHERE conversation_id=$1`, msg.ConversationID)
+	senderUsername := c.currentUsername()
+	saved, err := c.Hub.Repos.Messages.SaveMessage(models.Message{
+		ConversationID: msg.ConversationID, SenderID: c.UserID,
+		Content: msg.Content, MediaURL: msg.MediaURL, MediaType: msg.MediaType,
+		ReplyToID: msg.ReplyToID,
+	})
 	if err != nil {
+		log.Println("Ошибка сохранения сообщения:", err)
 		return
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var uid int
-		rows.Scan(&uid)
-		c.Hub.SendToUser(uid, data)
-		// FCM если пользователь оффлайн
-		if uid != c.UserID {
-			c.Hub.mu.RLock()
-			_, online := c.Hub.Clients[uid]
-			c.Hub.mu.RUnlock()
-			if !online {
-				content := msg.Content
-				if content == "" {
-					content = "📎 Медиафайл"
-				}
-				SendFcmNotification(uid, map[string]string{
-					"type":    "message",
-					"sender":  senderUsername,
-					"content": content,
-					"id":      "1",
-				})
-			}
-		}
+	response := models.WSMessage{
+		Type: "message", MessageID: saved.ID, ConversationID: msg.ConversationID,
+		Content: msg.Content, MediaURL: msg.MediaURL, MediaType: msg.MediaType,
+		SenderID: c.UserID, SenderUsername: senderUsername, CreatedAt: &saved.CreatedAt,
+		ReplyToID: msg.ReplyToID, ReplyTo: replyTo,
 	}
+	c.Hub.deliverMessage(response)
 }
 
 func (c *Client) handleGroupMessage(msg models.WSMessage) {
-	var senderUsername string
-	c.DB.QueryRow(`SELECT username FROM users WHERE id=$1`, c.UserID).Scan(&senderUsername)
-	_, err := c.DB.Exec(
-		`INSERT INTO group_messages (group_id, sender_id, content, media_url, media_type) VALUES ($1, $2, $3, $4, $5)`,
-		msg.GroupID, c.UserID, msg.Content, msg.MediaURL, msg.MediaType,
-	)
+	if err := c.Hub.Authz.CanWriteGroup(c.UserID, msg.GroupID); err != nil {
+		c.sendAuthzError(msg.Type, err)
+		return
+	}
+	if msg.ThreadRootID != 0 {
+		c.handleThreadReply(msg)
+		return
+	}
+	replyTo, ok := c.replyPreview(msg.Type, models.ChatGroup, msg.GroupID, msg.ReplyToID)
+	if !ok {
+		return
+	}
+	senderUsername := c.currentUsername()
+	saved, err := c.Hub.Repos.Messages.SaveGroupMessage(models.GroupMessage{
+		GroupID: msg.GroupID, SenderID: c.UserID,
+		Content: msg.Content, MediaURL: msg.MediaURL, MediaType: msg.MediaType,
+		ReplyToID: msg.ReplyToID,
+	})
 	if err != nil {
 		log.Println("Ошибка сохранения группового сообщения:", err)
 		return
 	}
 	response := models.WSMessage{
-		Type: "group_message", GroupID: msg.GroupID,
+		Type: "group_message", MessageID: saved.ID, GroupID: msg.GroupID,
 		Content: msg.Content, MediaURL: msg.MediaURL, MediaType: msg.MediaType,
-		SenderID: c.UserID, SenderUsername: senderUsername,
-	}
-	data, _ := json.Marshal(response)
-	c.Hub.SendToGroupMembers(msg.GroupID, -1, data)
-	// FCM оффлайн участникам группы
-	rows2, err2 := c.DB.Query(`SELECT user_id FROM group_members WHERE group_id=$1 AND user_id!=$2`, msg.GroupID, c.UserID)
-	if err2 == nil {
-		defer rows2.Close()
-		for rows2.Next() {
-			var uid int
-			rows2.Scan(&uid)
-			c.Hub.mu.RLock()
-			_, online := c.Hub.Clients[uid]
-			c.Hub.mu.RUnlock()
-			if !online {
-				content := msg.Content
-				if content == "" {
-					content = "📎 Медиафайл"
-				}
-				SendFcmNotification(uid, map[string]string{
-					"type":       "group_message",
-					"sender":     senderUsername,
-					"content":    content,
-					"group_name": "Группа",
-					"id":         "2",
-				})
-			}
-		}
+		SenderID: c.UserID, SenderUsername: senderUsername, CreatedAt: &saved.CreatedAt,
+		ReplyToID: msg.ReplyToID, ReplyTo: replyTo,
 	}
+	c.Hub.deliverGroupMessage(response)
+}
+
+// replyPreview проверяет, что ответ ссылается на сообщение того же чата,
+// и возвращает его цитату для рассылки. false — запрос отклонён и клиенту
+// уже отправлена ошибка.
+func (c *Client) replyPreview(requestType, chatType string, chatID, replyToID int) (*models.ReplyPreview, bool) {
+	if replyToID == 0 {
+		return nil, true
+	}
+	preview, err := c.Hub.Repos.Messages.ReplyPreview(chatType, chatID, replyToID)
+	switch err {
+	case nil:
+		return preview, true
+	case repository.ErrMessageNotFound:
+		c.sendError(requestType, "invalid_reply", "Сообщение для ответа не найдено в этом чате")
+	default:
+		log.Println("Ошибка получения цитаты:", err)
+		c.sendError(requestType, "internal", "Не удалось отправить ответ")
+	}
+	return nil, false
+}
+
+// currentUsername берёт имя из хранилища: его могли сменить после входа,
+// а в токене и Client осталось прежнее
+func (c *Client) currentUsername() string {
+	user, err := c.Hub.Repos.Users.GetUserByID(c.UserID)
+	if err != nil {
+		return c.Username
+	}
+	return user.Username
+}
+
+func (c *Client) handleMessageEdit(msg models.WSMessage) {
+	if strings.TrimSpace(msg.Content) == "" {
+		c.sendError(msg.Type, "empty_content", "Текст сообщения пуст")
+		return
+	}
+	if err := c.Hub.EditMessage(c.UserID, msg.GroupID, msg.MessageID, msg.Content); err != nil {
+		c.sendMessageChangeError(msg.Type, err)
+	}
+}
+
+func (c *Client) handleMessageDelete(msg models.WSMessage) {
+	if err := c.Hub.DeleteMessage(c.UserID, msg.GroupID, msg.MessageID); err != nil {
+		c.sendMessageChangeError(msg.Type, err)
+	}
+}
+
+func (c *Client) handleReaction(msg models.WSMessage) {
+	err := c.Hub.React(c.UserID, msg.GroupID, msg.MessageID, msg.Emoji, msg.Type == "reaction_add")
+	switch err {
+	case nil:
+	case ErrInvalidReaction:
+		c.sendError(msg.Type, "invalid_reaction", err.Error())
+	default:
+		c.sendMessageChangeError(msg.Type, err)
+	}
+}
+
+func (c *Client) handleReceipt(msg models.WSMessage) {
+	err := c.Hub.MarkReceipt(c.UserID, msg.ConversationID, msg.GroupID, msg.MessageID, msg.Type == "message_read")
+	switch err {
+	case nil:
+	case authz.ErrNotMember, repository.ErrNotChatMember:
+		c.sendError(msg.Type, "forbidden", err.Error())
+	default:
+		log.Println("Ошибка сохранения отметки:", err)
+	}
+}
+
+func (c *Client) sendMessageChangeError(requestType string, err error) {
+	switch err {
+	case authz.ErrNotMember, authz.ErrNotAdmin, authz.ErrBlocked:
+		c.sendAuthzError(requestType, err)
+	case repository.ErrMessageNotFound:
+		c.sendError(requestType, "not_found", err.Error())
+	case repository.ErrNotMessageSender:
+		c.sendError(requestType, "forbidden", err.Error())
+	case repository.ErrMessageDeleted:
+		c.sendError(requestType, "deleted", err.Error())
+	default:
+		log.Println("Ошибка изменения сообщения:", err)
+		c.sendError(requestType, "internal", "Не удалось изменить сообщение")
+	}
+}
+
+// sendError сообщает только этому подключению, что запрос отклонён
+func (c *Client) sendError(requestType, code, message string) {
+	data, _ := json.Marshal(models.WSError{
+		Type: "error", RequestType: requestType, Code: code, Message: message,
+	})
+	select {
+	case c.Send <- data:
+	default:
+	}
+}
+
+// Коды закрытия из частного диапазона 4000–4999
+const (
+	closeSessionRevoked = 4001
+	closeSlowClient     = 4002
+)
+
+// closeWith отправляет close-кадр с кодом и причиной и закрывает соединение.
+// WriteControl можно вызывать параллельно с WritePump.
+func (c *Client) closeWith(code int, reason string) {
+	deadline := time.Now().Add(time.Second)
+	c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	c.Conn.Close()
 }
 
 func (c *Client) WritePump() {
@@ -180,6 +283,7 @@ func (c *Client) WritePump() {
 			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
+			c.Hub.touchPresence(c)
 		}
 	}
 }
@@ -0,0 +1,51 @@
+package ws
+
+import (
+	"encoding/json"
+
+	"your_project/internal/models"
+)
+
+// deliverMessage записывает новое личное сообщение в ленты участников
+// диалога и шлёт FCM тем, у кого ни одно устройство не в сети
+func (h *Hub) deliverMessage(event models.WSMessage) {
+	data, _ := json.Marshal(event)
+	members := h.conversationMemberIDs(event.ConversationID, -1)
+	h.EmitTo(members, event.Type, data)
+	for _, uid := range members {
+		if uid != event.SenderID && !h.IsOnline(uid) {
+			h.Notifier.Notify(uid, map[string]string{
+				"type":    "message",
+				"sender":  event.SenderUsername,
+				"content": pushContent(event),
+				"id":      "1",
+			})
+		}
+	}
+}
+
+// deliverGroupMessage — то же для группы; FCM не получают заблокировавшие
+// отправителя
+func (h *Hub) deliverGroupMessage(event models.WSMessage) {
+	data, _ := json.Marshal(event)
+	h.EmitToGroupMembers(event.GroupID, -1, event.Type, data)
+	blockers := h.blockersOf(event.SenderID)
+	for _, uid := range h.groupMemberIDs(event.GroupID, event.SenderID) {
+		if !blockers[uid] && !h.IsOnline(uid) {
+			h.Notifier.Notify(uid, map[string]string{
+				"type":       "group_message",
+				"sender":     event.SenderUsername,
+				"content":    pushContent(event),
+				"group_name": "Группа",
+				"id":         "2",
+			})
+		}
+	}
+}
+
+func pushContent(event models.WSMessage) string {
+	if event.Content == "" {
+		return "📎 Медиафайл"
+	}
+	return event.Content
+}
@@ -0,0 +1,86 @@
+package ws
+
+import (
+	"encoding/json"
+	"your_project/internal/models"
+)
+
+// EditMessage правит сообщение от имени userID и рассылает правку всем
+// участникам чата. groupID == 0 означает личный диалог.
+func (h *Hub) EditMessage(userID, groupID, messageID int, content string) error {
+	if err := h.authorizeMessageChange(userID, groupID, messageID); err != nil {
+		return err
+	}
+	edited, err := h.Repos.Messages.EditMessage(chatTypeOf(groupID), messageID, userID, content)
+	if err != nil {
+		return err
+	}
+	// ThreadRootID у ответа в ветке — чтобы клиент применил правку в ветке
+	event := models.WSMessage{
+		Type: "message_edit", MessageID: messageID, ThreadRootID: edited.ThreadRootID,
+		Content: content, SenderID: userID, EditedAt: &edited.EditedAt,
+	}
+	h.broadcastToChat(groupID, edited.ChatID, event)
+	return nil
+}
+
+// DeleteMessage помечает сообщение удалённым и рассылает это участникам чата;
+// для ответа в ветке рассылается и thread_update с пересчитанными счётчиками
+func (h *Hub) DeleteMessage(userID, groupID, messageID int) error {
+	if err := h.authorizeMessageChange(userID, groupID, messageID); err != nil {
+		return err
+	}
+	chatID, thread, err := h.Repos.Messages.DeleteMessage(chatTypeOf(groupID), messageID, userID)
+	if err != nil {
+		return err
+	}
+	event := models.WSMessage{
+		Type: "message_delete", MessageID: messageID,
+		SenderID: userID, Deleted: true,
+	}
+	if thread != nil {
+		event.ThreadRootID = thread.RootID
+	}
+	h.broadcastToChat(groupID, chatID, event)
+	// удалён ответ в ветке: группа получает новые счётчики корня, как при ответе
+	if thread != nil {
+		h.broadcastToChat(groupID, chatID, models.WSMessage{
+			Type: "thread_update", MessageID: thread.RootID, ThreadRootID: thread.RootID,
+			ThreadReplyCount: thread.ReplyCount, ThreadLastReplyAt: thread.LastReplyAt,
+		})
+	}
+	return nil
+}
+
+// authorizeMessageChange: менять свои сообщения можно, пока состоишь в чате
+// (блокировка собеседника не мешает исправить или отозвать своё)
+func (h *Hub) authorizeMessageChange(userID, groupID, messageID int) error {
+	chatID, err := h.Repos.Messages.GetMessageChat(chatTypeOf(groupID), messageID)
+	if err != nil {
+		return err
+	}
+	if groupID != 0 {
+		return h.Authz.CanReadGroup(userID, chatID)
+	}
+	return h.Authz.CanReadConversation(userID, chatID)
+}
+
+func chatTypeOf(groupID int) string {
+	if groupID != 0 {
+		return models.ChatGroup
+	}
+	return models.ChatDirect
+}
+
+// broadcastToChat дополняет событие ID чата и записывает его в ленты всех участников
+func (h *Hub) broadcastToChat(groupID, chatID int, event models.WSMessage) {
+	if groupID != 0 {
+		event.GroupID = chatID
+		data, _ := json.Marshal(event)
+		h.EmitToGroupMembers(chatID, -1, event.Type, data)
+		return
+	}
+	event.ConversationID = chatID
+	data, _ := json.Marshal(event)
+	h.EmitToConversationMembers(chatID, -1, event.Type, data)
+}
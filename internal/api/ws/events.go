@@ -0,0 +1,85 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+	"your_project/internal/models"
+)
+
+// Emit записывает событие в ленту пользователя и доставляет его с полем seq,
+// чтобы после обрыва соединения клиент мог догнать пропущенное через sync.
+// Эфемерные события (набор текста, сигналы звонков) идут через SendToUser.
+func (h *Hub) Emit(userID int, eventType string, payload []byte) {
+	h.EmitTo([]int{userID}, eventType, payload)
+}
+
+// EmitTo — Emit для нескольких получателей: ленты всех пишутся одним запросом
+func (h *Hub) EmitTo(userIDs []int, eventType string, payload []byte) {
+	if len(userIDs) == 0 {
+		return
+	}
+	seqs, err := h.Repos.Events.Append(userIDs, eventType, payload)
+	if err != nil {
+		log.Printf("Ошибка записи события %s для %d получателей: %v", eventType, len(userIDs), err)
+	}
+	for _, uid := range userIDs {
+		seq, ok := seqs[uid]
+		if !ok {
+			// онлайн-устройства всё равно должны получить событие
+			h.SendToUser(uid, payload)
+			continue
+		}
+		h.SendToUser(uid, withSeq(payload, seq))
+	}
+}
+
+func (h *Hub) EmitToGroupMembers(groupID int, excludeUserID int, eventType string, payload []byte) {
+	h.EmitTo(h.groupMemberIDs(groupID, excludeUserID), eventType, payload)
+}
+
+func (h *Hub) EmitToConversationMembers(conversationID int, excludeUserID int, eventType string, payload []byte) {
+	h.EmitTo(h.conversationMemberIDs(conversationID, excludeUserID), eventType, payload)
+}
+
+// EmitMembership сообщает участникам группы и самому userID о его входе или выходе
+func (h *Hub) EmitMembership(groupID, userID int, action string) {
+	data, _ := json.Marshal(models.MembershipEvent{
+		Type: "group_membership", GroupID: groupID, UserID: userID, Action: action,
+	})
+	h.EmitToGroupMembers(groupID, userID, "group_membership", data)
+	h.Emit(userID, "group_membership", data)
+}
+
+// PruneEvents периодически удаляет события старше retention; блокирует вызывающего
+func (h *Hub) PruneEvents(retention time.Duration, stop <-chan struct{}) {
+	repo := h.Repos.Events
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if n, err := repo.Prune(time.Now().Add(-retention)); err != nil {
+			log.Println("Ошибка очистки ленты событий:", err)
+		} else if n > 0 {
+			log.Printf("Удалено старых событий: %d", n)
+		}
+	}
+}
+
+// withSeq добавляет seq в JSON-объект события
+func withSeq(payload []byte, seq int64) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil {
+		return payload
+	}
+	fields["seq"], _ = json.Marshal(seq)
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+	return data
+}
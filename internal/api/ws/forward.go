@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"errors"
+
+	"your_project/internal/models"
+	"your_project/internal/repository"
+)
+
+// MaxForwardMessages — сколько сообщений можно переслать одним запросом
+const MaxForwardMessages = 100
+
+var (
+	ErrForwardChat  = errors.New("нужно указать ровно один исходный чат и ровно один чат назначения")
+	ErrForwardCount = errors.New("можно переслать от 1 до 100 сообщений")
+)
+
+// ForwardRequest — что и куда переслать. Из чатов источника и назначения
+// задаётся ровно по одному: диалог или группа.
+type ForwardRequest struct {
+	MessageIDs           []int `json:"message_ids"`
+	SourceConversationID int   `json:"source_conversation_id"`
+	SourceGroupID        int   `json:"source_group_id"`
+	ConversationID       int   `json:"conversation_id"`
+	GroupID              int   `json:"group_id"`
+}
+
+// ForwardMessages копирует сообщения в чат назначения от имени userID:
+// текст и медиа сохраняются, а копия помнит первоисточник. Нужны право
+// читать исходный чат и писать в целевой. Копии сохраняются разом — при
+// ошибке не появляется ни одной — и после этого рассылаются как обычные
+// новые сообщения в порядке оригиналов; возвращаются их ID.
+func (h *Hub) ForwardMessages(userID int, req ForwardRequest) ([]int, error) {
+	if (req.SourceConversationID == 0) == (req.SourceGroupID == 0) ||
+		(req.ConversationID == 0) == (req.GroupID == 0) {
+		return nil, ErrForwardChat
+	}
+	if len(req.MessageIDs) == 0 || len(req.MessageIDs) > MaxForwardMessages {
+		return nil, ErrForwardCount
+	}
+
+	sourceType, sourceID := chatTypeOf(req.SourceGroupID), req.SourceConversationID
+	var err error
+	if req.SourceGroupID != 0 {
+		sourceID = req.SourceGroupID
+		err = h.Authz.CanReadGroup(userID, sourceID)
+	} else {
+		err = h.Authz.CanReadConversation(userID, sourceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if req.GroupID != 0 {
+		err = h.Authz.CanWriteGroup(userID, req.GroupID)
+	} else {
+		err = h.Authz.CanWriteConversation(userID, req.ConversationID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sources, err := h.Repos.Messages.ForwardSources(sourceType, sourceID, req.MessageIDs)
+	if err != nil {
+		return nil, err
+	}
+	// от удалённого осталась только заглушка — пересылать нечего
+	for _, src := range sources {
+		if src.Deleted {
+			return nil, repository.ErrMessageDeleted
+		}
+	}
+	sender, err := h.Repos.Users.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	targetType, targetID := chatTypeOf(req.GroupID), req.ConversationID
+	if req.GroupID != 0 {
+		targetID = req.GroupID
+	}
+	copies, err := h.Repos.Messages.SaveForwarded(targetType, targetID, userID, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	// рассылка — только после сохранения всех копий
+	ids := make([]int, len(copies))
+	for i, saved := range copies {
+		src := sources[i]
+		from := src.From
+		event := models.WSMessage{
+			MessageID: saved.ID, CreatedAt: &saved.CreatedAt,
+			Content: src.Content, MediaURL: src.MediaURL, MediaType: src.MediaType,
+			SenderID: userID, SenderUsername: sender.Username, ForwardedFrom: &from,
+		}
+		if req.GroupID != 0 {
+			event.Type, event.GroupID = "group_message", req.GroupID
+			h.deliverGroupMessage(event)
+		} else {
+			event.Type, event.ConversationID = "message", req.ConversationID
+			h.deliverMessage(event)
+		}
+		ids[i] = saved.ID
+	}
+	return ids, nil
+}
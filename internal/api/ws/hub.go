@@ -1,67 +1,229 @@
 package ws
 
 import (
-	"database/sql"
+	"context"
+	"log"
 	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"your_project/internal/authz"
+	"your_project/internal/notify"
+	"your_project/internal/pkg/shutdown"
+	"your_project/internal/repository"
 )
 
 type Hub struct {
-	Clients map[int]*Client
+	// userID -> deviceID -> подключение; у пользователя может быть несколько устройств
+	Clients map[int]map[string]*Client
 	mu      sync.RWMutex
-	DB      *sql.DB
-}
+	Repos   *repository.Repositories
+	// Authz — проверки доступа к чатам над Repos, общие с HTTP
+	Authz *authz.Authorizer
+	// Broker доставляет сообщения подключениям на других узлах
+	Broker Broker
+	// Notifier шлёт push тем, у кого нет ни одного устройства в сети
+	Notifier notify.Notifier
 
-var GlobalHub *Hub
+	// closing — узел останавливается и новых подключений не принимает
+	closing bool
+	// pumps — работающие ReadPump; Shutdown ждёт, пока они доделают начатое
+	pumps sync.WaitGroup
+}
 
-func InitHub(db *sql.DB) {
-	GlobalHub = &Hub{
-		Clients: make(map[int]*Client),
-		DB:      db,
+func NewHub(repos *repository.Repositories, broker Broker, notifier notify.Notifier) *Hub {
+	h := &Hub{
+		Clients:  make(map[int]map[string]*Client),
+		Repos:    repos,
+		Authz:    authz.New(repos),
+		Broker:   broker,
+		Notifier: notifier,
 	}
+	if err := broker.Subscribe(h.deliverLocal); err != nil {
+		log.Fatal("Не удалось подписаться на брокер:", err)
+	}
+	return h
 }
 
-func NewHub(db *sql.DB) *Hub {
-	return &Hub{
-		Clients: make(map[int]*Client),
-		DB:      db,
+// Register добавляет подключение в хаб. Во время остановки узла подключение
+// сразу закрывается, и Register возвращает false — ReadPump запускать не нужно.
+func (h *Hub) Register(client *Client) bool {
+	wasOnline := h.IsOnline(client.UserID)
+
+	h.mu.Lock()
+	if h.closing {
+		h.mu.Unlock()
+		client.closeWith(websocket.CloseServiceRestart, closeRestartReason)
+		return false
+	}
+	h.pumps.Add(1)
+	devices, ok := h.Clients[client.UserID]
+	if !ok {
+		devices = make(map[string]*Client)
+		h.Clients[client.UserID] = devices
 	}
+	// Повторное подключение того же устройства вытесняет старый сокет
+	// и занимает его место — подписка на конверты у места уже есть
+	old, replaced := devices[client.DeviceID]
+	if replaced && old != client {
+		old.Conn.Close()
+	}
+	devices[client.DeviceID] = client
+	h.mu.Unlock()
+
+	if !replaced {
+		if err := h.Broker.Watch(client.UserID); err != nil {
+			log.Println("Ошибка подписки на доставку:", err)
+		}
+	}
+
+	h.touchPresence(client)
+	if !wasOnline {
+		h.userCameOnline(client.UserID)
+	}
+	return true
 }
 
-func (h *Hub) Register(client *Client) {
+func (h *Hub) Unregister(client *Client) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-	h.Clients[client.UserID] = client
+	removed := false
+	if devices, ok := h.Clients[client.UserID]; ok {
+		// Удаляем только это подключение: устройство могло уже переподключиться
+		if devices[client.DeviceID] == client {
+			delete(devices, client.DeviceID)
+			removed = true
+		}
+		if len(devices) == 0 {
+			delete(h.Clients, client.UserID)
+		}
+	}
+	h.mu.Unlock()
+
+	if removed {
+		if err := h.Broker.Unwatch(client.UserID); err != nil {
+			log.Println("Ошибка отписки от доставки:", err)
+		}
+		if err := h.Broker.SetPresence(client.UserID, client.DeviceID, false); err != nil {
+			log.Println("Ошибка снятия присутствия:", err)
+		}
+		if !h.IsOnline(client.UserID) {
+			h.userWentOffline(client.UserID)
+		}
+	}
 }
 
-func (h *Hub) Unregister(client *Client) {
+const closeRestartReason = "server restarting, reconnect"
+
+// Shutdown закрывает все подключения узла кодом 1012 (Service Restart),
+// чтобы клиенты переподключились, и ждёт, пока ReadPump доделают начатые
+// сообщения (сохранение, рассылку, push), но не дольше ctx
+func (h *Hub) Shutdown(ctx context.Context) error {
 	h.mu.Lock()
-	defer h.mu.Unlock()
-	delete(h.Clients, client.UserID)
+	h.closing = true
+	var clients []*Client
+	for _, devices := range h.Clients {
+		for _, client := range devices {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.Unlock()
+
+	// closeWith ждёт отправки close-кадра до секунды — закрываем параллельно
+	var closing sync.WaitGroup
+	for _, client := range clients {
+		closing.Add(1)
+		go func(c *Client) {
+			defer closing.Done()
+			c.closeWith(websocket.CloseServiceRestart, closeRestartReason)
+		}(client)
+	}
+	closing.Wait()
+	return shutdown.Wait(ctx, h.pumps.Wait)
+}
+
+// touchPresence продлевает онлайн-статус устройства в брокере
+func (h *Hub) touchPresence(client *Client) {
+	if err := h.Broker.SetPresence(client.UserID, client.DeviceID, true); err != nil {
+		log.Println("Ошибка обновления присутствия:", err)
+	}
+}
+
+// IsOnline сообщает, подключено ли хотя бы одно устройство пользователя на любом узле
+func (h *Hub) IsOnline(userID int) bool {
+	h.mu.RLock()
+	local := len(h.Clients[userID]) > 0
+	h.mu.RUnlock()
+	return local || h.Broker.IsOnline(userID)
 }
 
 func (h *Hub) SendToUser(userID int, data []byte) {
+	if err := h.Broker.Publish(Envelope{UserID: userID, Data: data}); err != nil {
+		log.Printf("Ошибка публикации для пользователя %d: %v", userID, err)
+	}
+}
+
+// CloseSession закрывает сокеты отозванной сессии на всех узлах
+func (h *Hub) CloseSession(userID int, sessionID string) {
+	if err := h.Broker.Publish(Envelope{UserID: userID, CloseSession: sessionID}); err != nil {
+		log.Printf("Ошибка закрытия сессии %s: %v", sessionID, err)
+	}
+}
+
+// deliverLocal отдаёт конверт подключениям этого узла. Закрытие сокета
+// ждёт close-кадра до секунды, поэтому подключения собираются под mu,
+// а закрываются уже после него и параллельно.
+func (h *Hub) deliverLocal(env Envelope) {
+	var revoked, slow []*Client
 	h.mu.RLock()
-	defer h.mu.RUnlock()
-	if client, ok := h.Clients[userID]; ok {
+	for _, client := range h.Clients[env.UserID] {
+		if env.CloseSession != "" {
+			if client.SessionID == env.CloseSession {
+				revoked = append(revoked, client)
+			}
+			continue
+		}
 		select {
-		case client.Send <- data:
+		case client.Send <- []byte(env.Data):
 		default:
+			// буфер полон — клиент не успевает; пусть переподключится
+			// и доберёт пропущенное через sync
+			slow = append(slow, client)
 		}
 	}
+	h.mu.RUnlock()
+
+	for _, client := range revoked {
+		go client.closeWith(closeSessionRevoked, "session revoked")
+	}
+	for _, client := range slow {
+		go client.closeWith(closeSlowClient, "too slow, reconnect and sync")
+	}
 }
 
 func (h *Hub) SendToGroupMembers(groupID int, excludeUserID int, data []byte) {
-	rows, err := h.DB.Query(
-		`SELECT user_id FROM group_members WHERE group_id = $1 AND user_id != $2`,
-		groupID, excludeUserID,
-	)
-	if err != nil {
-		return
+	for _, uid := range h.groupMemberIDs(groupID, excludeUserID) {
+		h.SendToUser(uid, data)
 	}
-	defer rows.Close()
-	for rows.Next() {
-		var uid int
-		rows.Scan(&uid)
+}
+
+func (h *Hub) SendToConversationMembers(conversationID int, excludeUserID int, data []byte) {
+	for _, uid := range h.conversationMemberIDs(conversationID, excludeUserID) {
 		h.SendToUser(uid, data)
 	}
 }
+
+func (h *Hub) groupMemberIDs(groupID int, excludeUserID int) []int {
+	ids, err := h.Repos.Groups.MemberIDs(groupID, excludeUserID)
+	if err != nil {
+		log.Println("Ошибка получения участников:", err)
+	}
+	return ids
+}
+
+func (h *Hub) conversationMemberIDs(conversationID int, excludeUserID int) []int {
+	ids, err := h.Repos.Conversations.MemberIDs(conversationID, excludeUserID)
+	if err != nil {
+		log.Println("Ошибка получения участников:", err)
+	}
+	return ids
+}
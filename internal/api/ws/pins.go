@@ -0,0 +1,42 @@
+package ws
+
+import "your_project/internal/models"
+
+// PinMessage закрепляет (pin) или открепляет сообщение и рассылает
+// участникам чата message_pinned / message_unpinned. В группе это может
+// только админ, в личном диалоге — любой собеседник, которому можно писать.
+// groupID == 0 — личный диалог.
+func (h *Hub) PinMessage(userID, groupID, messageID int, pin bool) error {
+	chatType := chatTypeOf(groupID)
+	chatID, err := h.Repos.Messages.GetMessageChat(chatType, messageID)
+	if err != nil {
+		return err
+	}
+	if groupID != 0 {
+		err = h.Authz.RequireGroupAdmin(userID, chatID)
+	} else {
+		err = h.Authz.CanWriteConversation(userID, chatID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !pin {
+		unpinned, err := h.Repos.Pins.Unpin(chatType, messageID)
+		if err != nil || !unpinned {
+			return err
+		}
+		h.broadcastToChat(groupID, chatID, models.WSMessage{
+			Type: "message_unpinned", MessageID: messageID, UserID: userID,
+		})
+		return nil
+	}
+	pinned, err := h.Repos.Pins.Pin(chatType, chatID, messageID, userID)
+	if err != nil {
+		return err
+	}
+	h.broadcastToChat(groupID, chatID, models.WSMessage{
+		Type: "message_pinned", MessageID: messageID, UserID: userID, Pin: &pinned,
+	})
+	return nil
+}
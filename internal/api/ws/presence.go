@@ -0,0 +1,44 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+	"your_project/internal/models"
+)
+
+// contactIDs — собеседники пользователя по личным диалогам, кроме тех,
+// с кем есть блокировка: им присутствие не показываем
+func (h *Hub) contactIDs(userID int) []int {
+	ids, err := h.Repos.Conversations.ContactIDs(userID)
+	if err != nil {
+		log.Println("Ошибка получения собеседников:", err)
+	}
+	return ids
+}
+
+// userCameOnline вызывается, когда подключилось первое устройство пользователя
+func (h *Hub) userCameOnline(userID int) {
+	h.broadcastPresence(models.PresenceEvent{Type: "presence", UserID: userID, Online: true})
+}
+
+// userWentOffline фиксирует last_seen_at, когда отключилось последнее устройство
+func (h *Hub) userWentOffline(userID int) {
+	lastSeen, err := h.Repos.Users.TouchLastSeen(userID)
+	if err != nil {
+		log.Println("Ошибка обновления last_seen_at:", err)
+		lastSeen = time.Now()
+	}
+	h.broadcastPresence(models.PresenceEvent{
+		Type: "presence", UserID: userID, Online: false, LastSeenAt: &lastSeen,
+	})
+}
+
+// broadcastPresence не пишет в ленту событий: после переподключения клиент
+// получает актуальное присутствие из GetConversations
+func (h *Hub) broadcastPresence(event models.PresenceEvent) {
+	data, _ := json.Marshal(event)
+	for _, uid := range h.contactIDs(event.UserID) {
+		h.SendToUser(uid, data)
+	}
+}
@@ -0,0 +1,88 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode"
+
+	"your_project/internal/models"
+)
+
+// maxReactionLen — предел длины реакции в байтах, как emoji VARCHAR(32)
+const maxReactionLen = 32
+
+var ErrInvalidReaction = errors.New("недопустимая реакция")
+
+// validReaction — непустая короткая строка без пробелов и управляющих
+// символов; какие именно эмодзи показывать, решает клиент
+func validReaction(emoji string) bool {
+	if emoji == "" || len(emoji) > maxReactionLen {
+		return false
+	}
+	return strings.IndexFunc(emoji, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}) < 0
+}
+
+// React ставит (add) или снимает реакцию userID на сообщение и рассылает
+// участникам чата reaction_add / reaction_remove. groupID == 0 — личный диалог.
+// Новая реакция заменяет прежнюю: тогда сначала уходит reaction_remove старой.
+func (h *Hub) React(userID, groupID, messageID int, emoji string, add bool) error {
+	if add && !validReaction(emoji) {
+		return ErrInvalidReaction
+	}
+	chatType := chatTypeOf(groupID)
+	chatID, err := h.Repos.Messages.GetMessageChat(chatType, messageID)
+	if err != nil {
+		return err
+	}
+
+	if !add {
+		// снять свою реакцию можно, пока состоишь в чате, как и отозвать сообщение
+		if err := h.authorizeMessageChange(userID, groupID, messageID); err != nil {
+			return err
+		}
+		removed, err := h.Repos.Reactions.Remove(chatType, messageID, userID)
+		if err != nil || removed == "" {
+			return err
+		}
+		h.sendReaction(groupID, chatID, "reaction_remove", messageID, userID, removed)
+		return nil
+	}
+
+	// поставить реакцию — то же, что написать в чат
+	if groupID != 0 {
+		err = h.Authz.CanWriteGroup(userID, chatID)
+	} else {
+		err = h.Authz.CanWriteConversation(userID, chatID)
+	}
+	if err != nil {
+		return err
+	}
+	previous, err := h.Repos.Reactions.Set(chatType, messageID, userID, emoji)
+	if err != nil || previous == emoji {
+		return err
+	}
+	if previous != "" {
+		h.sendReaction(groupID, chatID, "reaction_remove", messageID, userID, previous)
+	}
+	h.sendReaction(groupID, chatID, "reaction_add", messageID, userID, emoji)
+	return nil
+}
+
+// sendReaction рассылает реакцию онлайн-участникам чата, включая автора
+// (его другие устройства). Сводку после переподключения клиент получит
+// вместе с историей.
+func (h *Hub) sendReaction(groupID, chatID int, eventType string, messageID, userID int, emoji string) {
+	event := models.WSMessage{Type: eventType, MessageID: messageID, UserID: userID, Emoji: emoji}
+	if groupID != 0 {
+		event.GroupID = chatID
+		data, _ := json.Marshal(event)
+		h.SendToGroupMembers(chatID, -1, data)
+		return
+	}
+	event.ConversationID = chatID
+	data, _ := json.Marshal(event)
+	h.SendToConversationMembers(chatID, -1, data)
+}
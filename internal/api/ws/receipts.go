@@ -0,0 +1,28 @@
+package ws
+
+import "your_project/internal/models"
+
+// MarkReceipt сдвигает отметку доставки или прочтения userID до upToID и,
+// если она изменилась, рассылает событие участникам чата — в том числе
+// другим устройствам самого пользователя, чтобы синхронизировать счётчики.
+func (h *Hub) MarkReceipt(userID, conversationID, groupID, upToID int, read bool) error {
+	if err := h.Authz.CanReadChat(userID, conversationID, groupID); err != nil {
+		return err
+	}
+	chatID := conversationID
+	if groupID != 0 {
+		chatID = groupID
+	}
+	mark, changed, err := h.Repos.Receipts.Mark(chatTypeOf(groupID), chatID, userID, upToID, read)
+	if err != nil || !changed {
+		return err
+	}
+	eventType := "message_delivered"
+	if read {
+		eventType = "message_read"
+	}
+	h.broadcastToChat(groupID, chatID, models.WSMessage{
+		Type: eventType, MessageID: mark, UserID: userID,
+	})
+	return nil
+}
@@ -0,0 +1,192 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisDeliverPrefix  = "elowy:deliver:"
+	redisPresencePrefix = "elowy:presence:"
+	redisCallPrefix     = "elowy:call:"
+	// владелец комнаты ставится через SETNX, чтобы занять ID мог только один
+	redisCallOwnerPrefix   = "elowy:callowner:"
+	redisCallInvitedPrefix = "elowy:callinv:"
+	// комнаты звонков не живут дольше этого, даже если call_end потерялся
+	redisCallTTL = 6 * time.Hour
+)
+
+// RedisBroker связывает узлы через Redis pub/sub. У каждого пользователя свой
+// канал доставки, и узел подписан только на каналы тех, чьи подключения
+// держит, — чужой трафик до него не доходит. Присутствие хранится в sorted
+// set на пользователя (score — время истечения устройства), поэтому упавший
+// узел не оставляет «вечно онлайн» пользователей.
+type RedisBroker struct {
+	rdb    *redis.Client
+	pubsub *redis.PubSub
+
+	// watching — сколько подключений каждого пользователя держит узел;
+	// подписка живёт, пока счётчик больше нуля
+	mu       sync.Mutex
+	watching map[int]int
+}
+
+func NewRedisBroker(rdb *redis.Client) *RedisBroker {
+	return &RedisBroker{rdb: rdb, watching: make(map[int]int)}
+}
+
+func deliverChannel(userID int) string {
+	return redisDeliverPrefix + strconv.Itoa(userID)
+}
+
+func (b *RedisBroker) Publish(env Envelope) error {
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return b.rdb.Publish(context.Background(), deliverChannel(env.UserID), payload).Err()
+}
+
+// Subscribe запускает приём; каналы пользователей добавляет Watch
+func (b *RedisBroker) Subscribe(handler func(Envelope)) error {
+	b.pubsub = b.rdb.Subscribe(context.Background())
+	go func() {
+		for msg := range b.pubsub.Channel() {
+			var env Envelope
+			if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil {
+				log.Println("Redis broker: неверный конверт:", err)
+				continue
+			}
+			handler(env)
+		}
+	}()
+	return nil
+}
+
+func (b *RedisBroker) Watch(userID int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.watching[userID]++
+	switch b.watching[userID] {
+	case 0:
+		delete(b.watching, userID)
+	case 1:
+		return b.pubsub.Subscribe(context.Background(), deliverChannel(userID))
+	}
+	return nil
+}
+
+// Unwatch может прийти раньше парного Watch (Register и Unregister разных
+// подключений не упорядочены), тогда счётчик временно уходит в минус
+func (b *RedisBroker) Unwatch(userID int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.watching[userID]--
+	if b.watching[userID] != 0 {
+		return nil
+	}
+	delete(b.watching, userID)
+	return b.pubsub.Unsubscribe(context.Background(), deliverChannel(userID))
+}
+
+func (b *RedisBroker) SetPresence(userID int, deviceID string, online bool) error {
+	ctx := context.Background()
+	key := redisPresencePrefix + strconv.Itoa(userID)
+	if !online {
+		return b.rdb.ZRem(ctx, key, deviceID).Err()
+	}
+	expires := time.Now().Add(presenceTTL)
+	pipe := b.rdb.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(expires.Unix()), Member: deviceID})
+	pipe.Expire(ctx, key, presenceTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (b *RedisBroker) IsOnline(userID int) bool {
+	ctx := context.Background()
+	key := redisPresencePrefix + strconv.Itoa(userID)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	n, err := b.rdb.ZCount(ctx, key, "("+now, "+inf").Result()
+	return err == nil && n > 0
+}
+
+func (b *RedisBroker) CreateRoom(roomID string, invitees []int) (bool, error) {
+	ctx := context.Background()
+	created, err := b.rdb.SetNX(ctx, redisCallOwnerPrefix+roomID, 1, redisCallTTL).Result()
+	if err != nil || !created {
+		return false, err
+	}
+	members := make([]interface{}, len(invitees))
+	for i, uid := range invitees {
+		members[i] = uid
+	}
+	key := redisCallInvitedPrefix + roomID
+	pipe := b.rdb.TxPipeline()
+	pipe.SAdd(ctx, key, members...)
+	pipe.Expire(ctx, key, redisCallTTL)
+	_, err = pipe.Exec(ctx)
+	return err == nil, err
+}
+
+func (b *RedisBroker) IsInvited(roomID string, userID int) (bool, error) {
+	return b.rdb.SIsMember(context.Background(), redisCallInvitedPrefix+roomID, userID).Result()
+}
+
+func (b *RedisBroker) JoinRoom(roomID string, userID int) error {
+	ctx := context.Background()
+	key := redisCallPrefix + roomID
+	pipe := b.rdb.TxPipeline()
+	pipe.SAdd(ctx, key, userID)
+	pipe.Expire(ctx, key, redisCallTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (b *RedisBroker) LeaveRoom(roomID string, userID int) (int, error) {
+	ctx := context.Background()
+	key := redisCallPrefix + roomID
+	pipe := b.rdb.TxPipeline()
+	pipe.SRem(ctx, key, userID)
+	card := pipe.SCard(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	// пустой set Redis удаляет сам, а приглашения и владельца — мы
+	if card.Val() == 0 {
+		if err := b.rdb.Del(ctx, redisCallOwnerPrefix+roomID, redisCallInvitedPrefix+roomID).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return int(card.Val()), nil
+}
+
+func (b *RedisBroker) RoomMembers(roomID string) ([]int, error) {
+	raw, err := b.rdb.SMembers(context.Background(), redisCallPrefix+roomID).Result()
+	if err != nil {
+		return nil, err
+	}
+	members := make([]int, 0, len(raw))
+	for _, s := range raw {
+		uid, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("room %s: bad member %q", roomID, s)
+		}
+		members = append(members, uid)
+	}
+	return members, nil
+}
+
+func (b *RedisBroker) Close() error {
+	if b.pubsub != nil {
+		b.pubsub.Close()
+	}
+	return b.rdb.Close()
+}
@@ -3,7 +3,8 @@ package ws
 import (
 	"encoding/json"
 	"log"
-	"sync"
+
+	"your_project/internal/authz"
 )
 
 type SignalMessage struct {
@@ -17,63 +18,108 @@ type SignalMessage struct {
 	CallerName string      `json:"caller_name"`
 }
 
-type CallRoom struct {
-	RoomID       string
-	Participants map[int]*Client
-	IsGroup      bool
-	mu           sync.Mutex
-}
+// Участники комнат звонков хранятся в брокере, чтобы комната была видна
+// со всех узлов; сигналы участникам идут через SendToUser.
 
-var (
-	callRooms   = make(map[string]*CallRoom)
-	callRoomsMu sync.Mutex
-)
+func (h *Hub) joinCallRoom(roomID string, userID int) {
+	if roomID == "" {
+		return
+	}
+	if err := h.Broker.JoinRoom(roomID, userID); err != nil {
+		log.Printf("Ошибка входа в комнату %s: %v", roomID, err)
+	}
+}
 
-func GetOrCreateRoom(roomID string, isGroup bool) *CallRoom {
-	callRoomsMu.Lock()
-	defer callRoomsMu.Unlock()
-	if room, ok := callRooms[roomID]; ok {
-		return room
+func (h *Hub) leaveCallRoom(roomID string, userID int) {
+	if roomID == "" {
+		return
 	}
-	room := &CallRoom{
-		RoomID:       roomID,
-		Participants: make(map[int]*Client),
-		IsGroup:      isGroup,
+	if _, err := h.Broker.LeaveRoom(roomID, userID); err != nil {
+		log.Printf("Ошибка выхода из комнаты %s: %v", roomID, err)
 	}
-	callRooms[roomID] = room
-	return room
 }
 
-func DeleteRoom(roomID string) {
-	callRoomsMu.Lock()
-	defer callRoomsMu.Unlock()
-	delete(callRooms, roomID)
+func (h *Hub) broadcastToCallRoom(roomID string, data []byte, excludeUserID int) {
+	if roomID == "" {
+		return
+	}
+	members, err := h.Broker.RoomMembers(roomID)
+	if err != nil {
+		log.Printf("Не удалось получить участников комнаты %s: %v", roomID, err)
+		return
+	}
+	for _, uid := range members {
+		if uid != excludeUserID {
+			h.SendToUser(uid, data)
+		}
+	}
 }
 
-func (r *CallRoom) AddParticipant(c *Client) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.Participants[c.UserID] = c
+// groupCallees — кому звонит участник группы: все, кроме него самого и тех,
+// кто его заблокировал
+func (h *Hub) groupCallees(groupID, callerID int) []int {
+	blockers := h.blockersOf(callerID)
+	var callees []int
+	for _, uid := range h.groupMemberIDs(groupID, callerID) {
+		if !blockers[uid] {
+			callees = append(callees, uid)
+		}
+	}
+	return callees
 }
 
-func (r *CallRoom) RemoveParticipant(userID int) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	delete(r.Participants, userID)
+// authorizeSignal: напрямую звонить можно, если нет блокировки, а в группе —
+// только её участникам
+func (c *Client) authorizeSignal(signal SignalMessage) error {
+	if signal.To != 0 {
+		if err := c.Hub.Authz.CanContact(c.UserID, signal.To); err != nil {
+			return err
+		}
+	}
+	if signal.GroupID != 0 {
+		if err := c.Hub.Authz.CanWriteGroup(c.UserID, signal.GroupID); err != nil {
+			return err
+		}
+	}
+	return c.authorizeCallRoom(signal)
 }
 
-func (r *CallRoom) Broadcast(data []byte, excludeUserID int) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	for uid, c := range r.Participants {
-		if uid != excludeUserID {
-			select {
-			case c.Send <- data:
-			default:
-				log.Printf("Не удалось отправить сигнал участнику %d", uid)
-			}
+// authorizeCallRoom: первый call_offer закрепляет комнату за звонящим и теми,
+// кому он звонит; войти в неё, повторно позвонить в неё или разослать по ней
+// ICE могут только они
+func (c *Client) authorizeCallRoom(signal SignalMessage) error {
+	if signal.RoomID == "" {
+		return nil
+	}
+	switch signal.Type {
+	case "call_offer":
+		invitees := []int{c.UserID}
+		if signal.To != 0 {
+			invitees = append(invitees, signal.To)
 		}
+		if signal.GroupID != 0 {
+			invitees = append(invitees, c.Hub.groupCallees(signal.GroupID, c.UserID)...)
+		}
+		created, err := c.Hub.Broker.CreateRoom(signal.RoomID, invitees)
+		if err != nil || created {
+			return err
+		}
+	case "call_answer":
+	case "ice_candidate":
+		if signal.To != 0 {
+			return nil
+		}
+	default:
+		return nil
+	}
+	invited, err := c.Hub.Broker.IsInvited(signal.RoomID, c.UserID)
+	if err != nil {
+		return err
 	}
+	if !invited {
+		return authz.ErrNotMember
+	}
+	return nil
 }
 
 func HandleSignaling(hub *Hub, client *Client, signal SignalMessage) {
@@ -85,16 +131,16 @@ func HandleSignaling(hub *Hub, client *Client, signal SignalMessage) {
 		if signal.To != 0 {
 			hub.SendToUser(signal.To, data)
 		}
-		// Групповой звонок
+		// Групповой звонок: не звоним тем, кто заблокировал звонящего
 		if signal.GroupID != 0 {
-			room := GetOrCreateRoom(signal.RoomID, true)
-			room.AddParticipant(client)
-			hub.SendToGroupMembers(signal.GroupID, client.UserID, data)
+			hub.joinCallRoom(signal.RoomID, client.UserID)
+			for _, uid := range hub.groupCallees(signal.GroupID, client.UserID) {
+				hub.SendToUser(uid, data)
+			}
 		}
 
 	case "call_answer":
-		room := GetOrCreateRoom(signal.RoomID, signal.GroupID != 0)
-		room.AddParticipant(client)
+		hub.joinCallRoom(signal.RoomID, client.UserID)
 		// signal.To = ID звонящего (кому отправить ответ)
 		// signal.From уже перезаписан на ID отвечающего в client.go
 		if signal.To != 0 {
@@ -108,18 +154,13 @@ func HandleSignaling(hub *Hub, client *Client, signal SignalMessage) {
 		if signal.GroupID != 0 {
 			hub.SendToGroupMembers(signal.GroupID, client.UserID, data)
 		}
-		room := GetOrCreateRoom(signal.RoomID, false)
-		room.RemoveParticipant(client.UserID)
-		if len(room.Participants) == 0 {
-			DeleteRoom(signal.RoomID)
-		}
+		hub.leaveCallRoom(signal.RoomID, client.UserID)
 
 	case "ice_candidate":
 		if signal.To != 0 {
 			hub.SendToUser(signal.To, data)
 		} else {
-			room := GetOrCreateRoom(signal.RoomID, false)
-			room.Broadcast(data, client.UserID)
+			hub.broadcastToCallRoom(signal.RoomID, data, client.UserID)
 		}
 	}
 }
@@ -0,0 +1,72 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+	"your_project/internal/models"
+)
+
+const (
+	syncBatchSize   = 500
+	syncSendTimeout = 10 * time.Second
+)
+
+// handleSync переигрывает события с seq > last_seq только этому подключению.
+// Если событий больше syncBatchSize, ответ завершается has_more и клиент
+// повторяет sync с новым last_seq.
+func (c *Client) handleSync(msg models.WSMessage) {
+	repo := c.Hub.Repos.Events
+	current, oldest, err := repo.Bounds(c.UserID)
+	if err != nil {
+		log.Println("Ошибка sync:", err)
+		c.sendError(msg.Type, "internal", "Не удалось синхронизировать")
+		return
+	}
+	// Пустая лента: Prune удалил всё до current включительно
+	if oldest == 0 {
+		oldest = current + 1
+	}
+	// Лента уже обрезана дальше, чем видел клиент, или клиент «из будущего»
+	if msg.LastSeq > current || msg.LastSeq+1 < oldest {
+		c.sendSyncStatus(models.SyncStatus{Type: "sync_reset", LastSeq: current})
+		return
+	}
+
+	events, err := repo.Since(c.UserID, msg.LastSeq, syncBatchSize)
+	if err != nil {
+		log.Println("Ошибка sync:", err)
+		c.sendError(msg.Type, "internal", "Не удалось синхронизировать")
+		return
+	}
+	// Prune успел обрезать ленту между Bounds и Since: догонять нечем, а
+	// has_more без событий зациклил бы клиента
+	if len(events) == 0 && msg.LastSeq < current {
+		c.sendSyncStatus(models.SyncStatus{Type: "sync_reset", LastSeq: current})
+		return
+	}
+	last := msg.LastSeq
+	for _, e := range events {
+		if !c.sendBlocking(withSeq(e.Payload, e.Seq)) {
+			return
+		}
+		last = e.Seq
+	}
+	c.sendSyncStatus(models.SyncStatus{Type: "sync_done", LastSeq: last, HasMore: last < current})
+}
+
+func (c *Client) sendSyncStatus(status models.SyncStatus) {
+	data, _ := json.Marshal(status)
+	c.sendBlocking(data)
+}
+
+// sendBlocking ждёт места в очереди: при переигрывании событий их нельзя
+// молча отбрасывать, как при обычной рассылке
+func (c *Client) sendBlocking(data []byte) bool {
+	select {
+	case c.Send <- data:
+		return true
+	case <-time.After(syncSendTimeout):
+		return false
+	}
+}
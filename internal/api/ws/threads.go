@@ -0,0 +1,91 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"your_project/internal/models"
+	"your_project/internal/repository"
+)
+
+// handleThreadReply сохраняет group_message с thread_root_id как ответ в
+// ветке. Сам ответ получают только участники ветки (автор корня и те, кто
+// в ней писал), а вся группа — лишь новые счётчики корня в thread_update,
+// чтобы ответы не засоряли основную ленту.
+func (c *Client) handleThreadReply(msg models.WSMessage) {
+	replyTo, ok := c.replyPreview(msg.Type, models.ChatGroup, msg.GroupID, msg.ReplyToID)
+	if !ok {
+		return
+	}
+	senderUsername := c.currentUsername()
+	saved, replyCount, err := c.Hub.Repos.Messages.SaveThreadReply(models.GroupMessage{
+		GroupID: msg.GroupID, SenderID: c.UserID, ThreadRootID: msg.ThreadRootID,
+		Content: msg.Content, MediaURL: msg.MediaURL, MediaType: msg.MediaType,
+		ReplyToID: msg.ReplyToID,
+	})
+	if err == repository.ErrThreadNotFound {
+		c.sendError(msg.Type, "not_found", err.Error())
+		return
+	}
+	// replyPreview проверил только группу, а отвечать можно лишь внутри ветки
+	if err == repository.ErrMessageNotFound {
+		c.sendError(msg.Type, "invalid_reply", "Сообщение для ответа не найдено в этой ветке")
+		return
+	}
+	if err != nil {
+		log.Println("Ошибка сохранения ответа в ветке:", err)
+		return
+	}
+
+	response := models.WSMessage{
+		Type: "thread_message", MessageID: saved.ID, GroupID: msg.GroupID, ThreadRootID: msg.ThreadRootID,
+		Content: msg.Content, MediaURL: msg.MediaURL, MediaType: msg.MediaType,
+		SenderID: c.UserID, SenderUsername: senderUsername, CreatedAt: &saved.CreatedAt,
+		ReplyToID: msg.ReplyToID, ReplyTo: replyTo,
+	}
+	data, _ := json.Marshal(response)
+	participants, err := c.Hub.Repos.Messages.ThreadParticipantIDs(msg.ThreadRootID, -1)
+	if err != nil {
+		log.Println("Ошибка получения участников ветки:", err)
+	}
+	c.Hub.EmitTo(participants, response.Type, data)
+
+	update, _ := json.Marshal(models.WSMessage{
+		Type: "thread_update", GroupID: msg.GroupID, MessageID: msg.ThreadRootID,
+		ThreadRootID: msg.ThreadRootID, ThreadReplyCount: replyCount, ThreadLastReplyAt: &saved.CreatedAt,
+	})
+	c.Hub.EmitToGroupMembers(msg.GroupID, -1, "thread_update", update)
+
+	// FCM оффлайн участникам ветки, кроме заблокировавших отправителя;
+	// имя группы нужно только для них, поэтому читаем его по первому
+	blockers := c.Hub.blockersOf(c.UserID)
+	groupName := ""
+	for _, uid := range participants {
+		if uid == c.UserID || blockers[uid] || c.Hub.IsOnline(uid) {
+			continue
+		}
+		if groupName == "" {
+			groupName = c.Hub.groupName(msg.GroupID)
+		}
+		c.Hub.Notifier.Notify(uid, map[string]string{
+			"type":           "thread_message",
+			"sender":         senderUsername,
+			"content":        pushContent(response),
+			"group_name":     groupName,
+			"thread_root_id": strconv.Itoa(msg.ThreadRootID),
+			"id":             "3",
+		})
+	}
+}
+
+// groupName — название группы для push; если прочитать не удалось,
+// подпись та же, что у обычных групповых уведомлений
+func (h *Hub) groupName(groupID int) string {
+	info, err := h.Repos.Groups.Get(groupID)
+	if err != nil {
+		log.Println("Ошибка получения группы:", err)
+		return "Группа"
+	}
+	return info.Name
+}
@@ -0,0 +1,47 @@
+package ws
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+	"your_project/internal/models"
+)
+
+// typingThrottle — не чаще одного typing_start в этот интервал на чат
+const typingThrottle = 3 * time.Second
+
+// handleTyping пересылает typing_start/typing_stop остальным участникам чата.
+// Событие эфемерное и в ленту sync не попадает.
+func (c *Client) handleTyping(msg models.WSMessage) {
+	// Отказ молча игнорируем: индикатор набора не стоит ошибки на клиенте
+	if c.Hub.Authz.CanWriteChat(c.UserID, msg.ConversationID, msg.GroupID) != nil {
+		return
+	}
+	var key string
+	var recipients []int
+	if msg.GroupID != 0 {
+		key = "g" + strconv.Itoa(msg.GroupID)
+		recipients = c.Hub.groupMemberIDs(msg.GroupID, c.UserID)
+	} else {
+		key = "c" + strconv.Itoa(msg.ConversationID)
+		recipients = c.Hub.conversationMemberIDs(msg.ConversationID, c.UserID)
+	}
+
+	now := time.Now()
+	if msg.Type == "typing_start" {
+		if last, ok := c.typingSent[key]; ok && now.Sub(last) < typingThrottle {
+			return
+		}
+		c.typingSent[key] = now
+	} else {
+		delete(c.typingSent, key)
+	}
+
+	data, _ := json.Marshal(models.WSMessage{
+		Type: msg.Type, ConversationID: msg.ConversationID, GroupID: msg.GroupID,
+		SenderID: c.UserID, SenderUsername: c.Username,
+	})
+	for _, uid := range recipients {
+		c.Hub.SendToUser(uid, data)
+	}
+}
@@ -0,0 +1,139 @@
+package authz
+
+import (
+	"errors"
+
+	"your_project/internal/repository"
+)
+
+var (
+	ErrNotMember = errors.New("нет доступа к чату")
+	ErrNotAdmin  = errors.New("недостаточно прав")
+	ErrBlocked   = errors.New("пользователь заблокирован")
+)
+
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// Коды отказов для клиента: поле code события error в WebSocket и
+// заголовок X-Error-Code в HTTP
+const (
+	CodeForbidden = "forbidden"
+	CodeBlocked   = "blocked"
+	CodeInternal  = "internal"
+)
+
+// Code — код отказа для ошибки Authorizer, один для HTTP и WebSocket;
+// CodeInternal — ошибка хранилища, а не отказ
+func Code(err error) string {
+	switch err {
+	case ErrNotMember, ErrNotAdmin:
+		return CodeForbidden
+	case ErrBlocked:
+		return CodeBlocked
+	}
+	return CodeInternal
+}
+
+// Authorizer — единая проверка доступа к диалогам и группам для HTTP и WebSocket.
+// Методы возвращают nil, ErrNotMember/ErrNotAdmin/ErrBlocked или ошибку хранилища.
+type Authorizer struct {
+	Conversations repository.ConversationStore
+	Groups        repository.GroupStore
+	Blocks        repository.BlockStore
+}
+
+func New(repos *repository.Repositories) *Authorizer {
+	return &Authorizer{
+		Conversations: repos.Conversations,
+		Groups:        repos.Groups,
+		Blocks:        repos.Blocks,
+	}
+}
+
+func (a *Authorizer) CanReadConversation(userID, conversationID int) error {
+	member, err := a.Conversations.IsMember(conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if !member {
+		return ErrNotMember
+	}
+	return nil
+}
+
+// CanWriteConversation дополнительно запрещает писать, если между участниками
+// диалога есть блокировка в любую сторону
+func (a *Authorizer) CanWriteConversation(userID, conversationID int) error {
+	if err := a.CanReadConversation(userID, conversationID); err != nil {
+		return err
+	}
+	blocked, err := a.Conversations.HasBlockedMember(conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+	return nil
+}
+
+// GroupRole возвращает роль пользователя в группе или ErrNotMember
+func (a *Authorizer) GroupRole(userID, groupID int) (string, error) {
+	role, err := a.Groups.Role(groupID, userID)
+	if err == repository.ErrNotChatMember {
+		return "", ErrNotMember
+	}
+	return role, err
+}
+
+func (a *Authorizer) CanReadGroup(userID, groupID int) error {
+	_, err := a.GroupRole(userID, groupID)
+	return err
+}
+
+func (a *Authorizer) CanWriteGroup(userID, groupID int) error {
+	_, err := a.GroupRole(userID, groupID)
+	return err
+}
+
+func (a *Authorizer) RequireGroupAdmin(userID, groupID int) error {
+	role, err := a.GroupRole(userID, groupID)
+	if err != nil {
+		return err
+	}
+	if role != RoleAdmin {
+		return ErrNotAdmin
+	}
+	return nil
+}
+
+// CanReadChat/CanWriteChat выбирают проверку по паре идентификаторов,
+// как они приходят от клиента: groupID != 0 означает группу
+func (a *Authorizer) CanReadChat(userID, conversationID, groupID int) error {
+	if groupID != 0 {
+		return a.CanReadGroup(userID, groupID)
+	}
+	return a.CanReadConversation(userID, conversationID)
+}
+
+func (a *Authorizer) CanWriteChat(userID, conversationID, groupID int) error {
+	if groupID != 0 {
+		return a.CanWriteGroup(userID, groupID)
+	}
+	return a.CanWriteConversation(userID, conversationID)
+}
+
+// CanContact — можно ли начать диалог или позвонить напрямую
+func (a *Authorizer) CanContact(userID, otherUserID int) error {
+	blocked, err := a.Blocks.IsBlockedBetween(userID, otherUserID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrBlocked
+	}
+	return nil
+}
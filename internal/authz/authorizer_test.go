@@ -0,0 +1,135 @@
+package authz
+
+import (
+	"errors"
+	"testing"
+
+	"your_project/internal/models"
+	"your_project/internal/repository"
+	"your_project/internal/repository/memory"
+)
+
+// fixture: alice и bob в личном диалоге, группа с админом alice и
+// участником bob, eve — посторонняя
+type fixture struct {
+	repos           *repository.Repositories
+	alice, bob, eve int
+	conv, group     int
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	f := &fixture{repos: memory.New()}
+	for _, u := range []struct {
+		id   *int
+		name string
+	}{{&f.alice, "alice"}, {&f.bob, "bob"}, {&f.eve, "eve"}} {
+		id, err := f.repos.Users.CreateUser(models.User{Username: u.name, Password: "hash"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		*u.id = id
+	}
+	var err error
+	if f.conv, err = f.repos.Conversations.GetOrCreate(f.alice, f.bob); err != nil {
+		t.Fatal(err)
+	}
+	if f.group, _, err = f.repos.Groups.Create("группа", "", f.alice, []int{f.bob}); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestAuthorizer(t *testing.T) {
+	f := newFixture(t)
+	a := New(f.repos)
+
+	for _, tc := range []struct {
+		name  string
+		check func() error
+		want  error
+	}{
+		{"участник читает диалог", func() error { return a.CanReadConversation(f.bob, f.conv) }, nil},
+		{"участник пишет в диалог", func() error { return a.CanWriteConversation(f.bob, f.conv) }, nil},
+		{"посторонний читает диалог", func() error { return a.CanReadConversation(f.eve, f.conv) }, ErrNotMember},
+		{"посторонний пишет в диалог", func() error { return a.CanWriteConversation(f.eve, f.conv) }, ErrNotMember},
+		{"несуществующий диалог", func() error { return a.CanReadConversation(f.alice, f.conv+100) }, ErrNotMember},
+
+		{"участник читает группу", func() error { return a.CanReadGroup(f.bob, f.group) }, nil},
+		{"участник пишет в группу", func() error { return a.CanWriteGroup(f.bob, f.group) }, nil},
+		{"не участник читает группу", func() error { return a.CanReadGroup(f.eve, f.group) }, ErrNotMember},
+		{"не участник пишет в группу", func() error { return a.CanWriteGroup(f.eve, f.group) }, ErrNotMember},
+
+		{"админ управляет группой", func() error { return a.RequireGroupAdmin(f.alice, f.group) }, nil},
+		{"не админ управляет группой", func() error { return a.RequireGroupAdmin(f.bob, f.group) }, ErrNotAdmin},
+		{"не участник управляет группой", func() error { return a.RequireGroupAdmin(f.eve, f.group) }, ErrNotMember},
+
+		{"CanReadChat выбирает группу", func() error { return a.CanReadChat(f.eve, f.conv, f.group) }, ErrNotMember},
+		{"CanWriteChat выбирает диалог", func() error { return a.CanWriteChat(f.bob, f.conv, 0) }, nil},
+		{"можно написать без блокировки", func() error { return a.CanContact(f.alice, f.eve) }, nil},
+	} {
+		if got := tc.check(); got != tc.want {
+			t.Errorf("%s: %v, ждали %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestAuthorizerBlocked(t *testing.T) {
+	f := newFixture(t)
+	a := New(f.repos)
+	// блокировка в одну сторону запрещает писать обоим
+	if err := f.repos.Blocks.Block(f.bob, f.alice); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		check func() error
+		want  error
+	}{
+		{"заблокированный пишет", func() error { return a.CanWriteConversation(f.alice, f.conv) }, ErrBlocked},
+		{"заблокировавший пишет", func() error { return a.CanWriteConversation(f.bob, f.conv) }, ErrBlocked},
+		{"заблокированный пишет через CanWriteChat", func() error { return a.CanWriteChat(f.alice, f.conv, 0) }, ErrBlocked},
+		{"заблокированный читает историю", func() error { return a.CanReadConversation(f.alice, f.conv) }, nil},
+		{"заблокированный начинает диалог", func() error { return a.CanContact(f.alice, f.bob) }, ErrBlocked},
+		{"посторонний всё равно не участник", func() error { return a.CanWriteConversation(f.eve, f.conv) }, ErrNotMember},
+		// блокировка личная: в общей группе писать можно
+		{"заблокированный пишет в группу", func() error { return a.CanWriteGroup(f.alice, f.group) }, nil},
+	} {
+		if got := tc.check(); got != tc.want {
+			t.Errorf("%s: %v, ждали %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+// failingConversations — хранилище, которое не отвечает
+type failingConversations struct {
+	repository.ConversationStore
+}
+
+var errStore = errors.New("база недоступна")
+
+func (failingConversations) IsMember(int, int) (bool, error) { return false, errStore }
+
+func TestAuthorizerStoreError(t *testing.T) {
+	a := &Authorizer{Conversations: failingConversations{}}
+	if err := a.CanWriteConversation(1, 1); err != errStore {
+		t.Fatalf("ошибка хранилища подменена: %v", err)
+	}
+}
+
+func TestCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want string
+	}{
+		{ErrNotMember, CodeForbidden},
+		{ErrNotAdmin, CodeForbidden},
+		{ErrBlocked, CodeBlocked},
+		{errStore, CodeInternal},
+	} {
+		if got := Code(tc.err); got != tc.want {
+			t.Errorf("Code(%v) = %q, ждали %q", tc.err, got, tc.want)
+		}
+	}
+}
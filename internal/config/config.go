@@ -0,0 +1,275 @@
+// Package config собирает настройки сервера из окружения (и .env-файла) в
+// одну типизированную структуру и проверяет их при старте
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// DefaultJWTSecret — запасной секрет для локальной разработки;
+// в продакшене сервер с ним не запустится
+const DefaultJWTSecret = "default_secret"
+
+type Config struct {
+	// Env — APP_ENV: development (по умолчанию) или production
+	Env  string
+	Addr string
+	// JWTSecret подписывает access- и challenge-токены
+	JWTSecret string
+	// AppURL — адрес клиента для ссылок в письмах
+	AppURL string
+	// RedisURL — общий брокер хаба и счётчики входа; пусто — один процесс
+	RedisURL string
+	// EventRetention — сколько хранить ленту событий для sync
+	EventRetention time.Duration
+	// LoginAttemptRetention — сколько хранить журнал неудачных входов
+	LoginAttemptRetention time.Duration
+	// ShutdownTimeout — сколько при остановке ждать начатые запросы,
+	// WebSocket-обработчики, push и закрытие пула БД
+	ShutdownTimeout time.Duration
+	// TrustedProxies — TRUSTED_PROXIES, адреса и подсети через запятую:
+	// X-Forwarded-For читается, только если запрос пришёл от них
+	TrustedProxies []*net.IPNet
+
+	Database   Database
+	Mail       Mail
+	FCM        FCM
+	Cloudinary Cloudinary
+}
+
+type Database struct {
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Name     string
+	SSLMode  string
+}
+
+// DSN — строка подключения для lib/pq. Значения в кавычках: иначе пустой
+// пароль или пароль с пробелом ломают разбор строки.
+func (d Database) DSN() string {
+	q := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace
+	return fmt.Sprintf(
+		"host='%s' port=%d user='%s' password='%s' dbname='%s' sslmode='%s'",
+		q(d.Host), d.Port, q(d.User), q(d.Password), q(d.Name), q(d.SSLMode),
+	)
+}
+
+// Mail: SMTPHost — отправка через SMTP, Dir — файлы .eml в каталоге,
+// иначе письма только логируются
+type Mail struct {
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+	Dir          string
+}
+
+type FCM struct {
+	// ServiceAccount — JSON сервисного аккаунта Firebase; пусто — push выключены
+	ServiceAccount string
+}
+
+type Cloudinary struct {
+	CloudName    string
+	APIKey       string
+	UploadPreset string
+}
+
+// Load читает .env (или файл из CONFIG_FILE), затем окружение, и проверяет
+// результат. Переменные окружения важнее значений из файла.
+func Load() (*Config, error) {
+	file := os.Getenv("CONFIG_FILE")
+	if file == "" {
+		file = ".env"
+	}
+	if err := godotenv.Load(file); err != nil && (os.Getenv("CONFIG_FILE") != "" || !os.IsNotExist(err)) {
+		return nil, fmt.Errorf("конфиг %s: %w", file, err)
+	}
+
+	r := reader{}
+	cfg := &Config{
+		Env:                   r.str("APP_ENV", EnvDevelopment),
+		Addr:                  r.str("HTTP_ADDR", ":"+r.str("PORT", "8080")),
+		JWTSecret:             r.str("JWT_SECRET", ""),
+		AppURL:                strings.TrimRight(r.str("APP_URL", ""), "/"),
+		RedisURL:              r.str("REDIS_URL", ""),
+		EventRetention:        r.dur("EVENT_RETENTION", 30*24*time.Hour),
+		LoginAttemptRetention: r.dur("LOGIN_ATTEMPT_RETENTION", 90*24*time.Hour),
+		ShutdownTimeout:       r.dur("SHUTDOWN_TIMEOUT", 15*time.Second),
+		TrustedProxies:        r.nets("TRUSTED_PROXIES"),
+		Database: Database{
+			Host:     r.str("DB_HOST", "localhost"),
+			Port:     r.num("DB_PORT", 5432),
+			User:     r.str("DB_USER", ""),
+			Password: r.str("DB_PASSWORD", ""),
+			Name:     r.str("DB_NAME", ""),
+			SSLMode:  r.str("DB_SSLMODE", "require"),
+		},
+		Mail: Mail{
+			SMTPHost:     r.str("SMTP_HOST", ""),
+			SMTPPort:     r.num("SMTP_PORT", 587),
+			SMTPUsername: r.str("SMTP_USERNAME", ""),
+			SMTPPassword: r.str("SMTP_PASSWORD", ""),
+			From:         r.str("MAIL_FROM", ""),
+			Dir:          r.str("MAIL_DIR", ""),
+		},
+		FCM: FCM{ServiceAccount: r.str("FCM_SERVICE_ACCOUNT", "")},
+		Cloudinary: Cloudinary{
+			CloudName:    r.str("CLOUDINARY_CLOUD_NAME", ""),
+			APIKey:       r.str("CLOUDINARY_API_KEY", ""),
+			UploadPreset: r.str("CLOUDINARY_UPLOAD_PRESET", "elowy_avatars"),
+		},
+	}
+	if cfg.JWTSecret == "" && cfg.Env != EnvProduction {
+		cfg.JWTSecret = DefaultJWTSecret
+	}
+
+	problems := append(r.problems, cfg.validate()...)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("неверная конфигурация:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return cfg, nil
+}
+
+func (c *Config) Production() bool {
+	return c.Env == EnvProduction
+}
+
+// TrustedProxy — входит ли ip в TRUSTED_PROXIES
+func (c *Config) TrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range c.TrustedProxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) validate() []string {
+	var problems []string
+	if c.Env != EnvDevelopment && c.Env != EnvProduction {
+		problems = append(problems, fmt.Sprintf("APP_ENV: ожидается %s или %s, получено %q", EnvDevelopment, EnvProduction, c.Env))
+	}
+	if c.Production() {
+		switch {
+		case c.JWTSecret == "" || c.JWTSecret == DefaultJWTSecret:
+			problems = append(problems, "JWT_SECRET: в production нужен собственный секрет")
+		case len(c.JWTSecret) < 32:
+			problems = append(problems, "JWT_SECRET: в production секрет должен быть не короче 32 символов")
+		}
+		if c.Database.SSLMode == "disable" {
+			problems = append(problems, "DB_SSLMODE: в production соединение с БД должно быть зашифровано")
+		}
+	}
+	if c.Database.User == "" {
+		problems = append(problems, "DB_USER не задан")
+	}
+	if c.Database.Name == "" {
+		problems = append(problems, "DB_NAME не задан")
+	}
+	if c.Database.Port <= 0 || c.Database.Port > 65535 {
+		problems = append(problems, "DB_PORT: неверный порт")
+	}
+	if c.Mail.From != "" {
+		if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+			problems = append(problems, fmt.Sprintf("MAIL_FROM: неверный адрес %q: %v", c.Mail.From, err))
+		}
+	} else if c.Mail.SMTPHost != "" {
+		problems = append(problems, "MAIL_FROM обязателен вместе с SMTP_HOST")
+	}
+	if c.EventRetention <= 0 {
+		problems = append(problems, "EVENT_RETENTION должен быть положительным")
+	}
+	if c.LoginAttemptRetention <= 0 {
+		problems = append(problems, "LOGIN_ATTEMPT_RETENTION должен быть положительным")
+	}
+	if c.ShutdownTimeout <= 0 {
+		problems = append(problems, "SHUTDOWN_TIMEOUT должен быть положительным")
+	}
+	return problems
+}
+
+// reader читает переменные окружения и копит ошибки разбора
+type reader struct {
+	problems []string
+}
+
+func (r *reader) str(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+func (r *reader) num(key string, def int) int {
+	v := r.str(key, "")
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("%s: ожидается число, получено %q", key, v))
+		return def
+	}
+	return n
+}
+
+func (r *reader) dur(key string, def time.Duration) time.Duration {
+	v := r.str(key, "")
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		r.problems = append(r.problems, fmt.Sprintf("%s: ожидается длительность вроде 720h, получено %q", key, v))
+		return def
+	}
+	return d
+}
+
+// nets читает список адресов и подсетей через запятую; адрес без маски —
+// подсеть из одного адреса
+func (r *reader) nets(key string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, v := range strings.Split(r.str(key, ""), ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if ip := net.ParseIP(v); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			r.problems = append(r.problems, fmt.Sprintf("%s: ожидается IP или подсеть вроде 10.0.0.0/8, получено %q", key, v))
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
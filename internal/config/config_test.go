@@ -0,0 +1,35 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "127.0.0.1, 10.0.0.0/8,::1,")
+	r := reader{}
+	c := &Config{TrustedProxies: r.nets("TRUSTED_PROXIES")}
+	if len(r.problems) > 0 {
+		t.Fatal(r.problems)
+	}
+	for ip, want := range map[string]bool{
+		"127.0.0.1":   true,
+		"127.0.0.2":   false,
+		"10.20.30.40": true,
+		"::1":         true,
+		"203.0.113.7": false,
+		"":            false,
+		"garbage":     false,
+	} {
+		if got := c.TrustedProxy(ip); got != want {
+			t.Errorf("TrustedProxy(%q) = %v, ждали %v", ip, got, want)
+		}
+	}
+
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/33")
+	r = reader{}
+	r.nets("TRUSTED_PROXIES")
+	if len(r.problems) != 1 || !strings.HasPrefix(r.problems[0], "TRUSTED_PROXIES") {
+		t.Fatalf("ошибки разбора: %v", r.problems)
+	}
+}
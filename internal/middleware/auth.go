@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"your_project/internal/pkg/auth"
+	"your_project/internal/repository"
+)
+
+type ctxKey int
+
+const claimsKey ctxKey = iota
+
+// Authenticate проверяет access-токен и то, что его сессия не отозвана
+func Authenticate(tokens *auth.Tokens, sessions repository.SessionStore, tokenStr string) (*auth.Claims, error) {
+	claims, err := tokens.ParseAccessToken(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	active, err := sessions.IsActive(claims.SessionID, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, repository.ErrSessionNotFound
+	}
+	return claims, nil
+}
+
+// Auth пропускает запрос дальше только с действующим Bearer-токеном и
+// кладёт его claims в контекст запроса
+func Auth(tokens *auth.Tokens, sessions repository.SessionStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenStr := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			claims, err := Authenticate(tokens, sessions, tokenStr)
+			if err != nil {
+				http.Error(w, "Не авторизован", http.StatusUnauthorized)
+				return
+			}
+			ctx := context.WithValue(r.Context(), claimsKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetClaims возвращает claims, положенные Auth; nil — маршрут без Auth
+func GetClaims(r *http.Request) *auth.Claims {
+	claims, _ := r.Context().Value(claimsKey).(*auth.Claims)
+	return claims
+}
+
+func GetUserID(r *http.Request) int {
+	if c := GetClaims(r); c != nil {
+		return c.UserID
+	}
+	return 0
+}
+
+func GetUsername(r *http.Request) string {
+	if c := GetClaims(r); c != nil {
+		return c.Username
+	}
+	return ""
+}
+
+func GetSessionID(r *http.Request) string {
+	if c := GetClaims(r); c != nil {
+		return c.SessionID
+	}
+	return ""
+}
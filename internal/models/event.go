@@ -0,0 +1,28 @@
+package models
+
+import "encoding/json"
+
+// Event — запись ленты пользователя; Payload — то же JSON-событие, что
+// уходило по сокету в момент публикации
+type Event struct {
+	Seq     int64           `json:"seq"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// MembershipEvent сообщает о входе или выходе участника группы, а также
+// о том, что участник стал админом после ухода последнего
+type MembershipEvent struct {
+	Type    string `json:"type"` // всегда "group_membership"
+	GroupID int    `json:"group_id"`
+	UserID  int    `json:"user_id"`
+	Action  string `json:"action"` // "added", "removed" или "promoted"
+}
+
+// SyncStatus завершает ответ на sync. Reset означает, что нужных событий
+// уже нет в ленте и клиенту нужно перезагрузить чаты через REST.
+type SyncStatus struct {
+	Type    string `json:"type"` // "sync_done" или "sync_reset"
+	LastSeq int64  `json:"last_seq"`
+	HasMore bool   `json:"has_more"`
+}
@@ -0,0 +1,30 @@
+package models
+
+// GroupSummary — строка списка групп пользователя
+type GroupSummary struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	AvatarURL   string `json:"avatar_url"`
+	LastMessage string `json:"last_message"`
+	CreatedBy   int    `json:"created_by"`
+	UnreadCount int    `json:"unread_count"`
+	MemberCount int    `json:"member_count"`
+}
+
+type GroupMember struct {
+	ID          int    `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	Role        string `json:"role"`
+}
+
+type GroupInfo struct {
+	ID        int           `json:"id"`
+	Name      string        `json:"name"`
+	AvatarURL string        `json:"avatar_url"`
+	CreatedBy int           `json:"created_by"`
+	Members   []GroupMember `json:"members"`
+	// PinnedMessage — последнее закреплённое сообщение; nil — закрепов нет
+	PinnedMessage *PinnedMessage `json:"pinned_message"`
+}
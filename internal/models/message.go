@@ -2,15 +2,116 @@ package models
 
 import "time"
 
+// Типы чатов: личный диалог (messages) и группа (group_messages)
+const (
+	ChatDirect = "direct"
+	ChatGroup  = "group"
+)
+
 type Message struct {
-	ID             int       `json:"id"`
-	ConversationID int       `json:"conversation_id"`
-	SenderID       int       `json:"sender_id"`
-	SenderUsername string    `json:"sender_username"`
-	Content        string    `json:"content"`
-	MediaURL       string    `json:"media_url"`
-	MediaType      string    `json:"media_type"`
-	CreatedAt      time.Time `json:"created_at"`
+	ID             int        `json:"id"`
+	ConversationID int        `json:"conversation_id"`
+	SenderID       int        `json:"sender_id"`
+	SenderUsername string     `json:"sender_username"`
+	Content        string     `json:"content"`
+	MediaURL       string     `json:"media_url"`
+	MediaType      string     `json:"media_type"`
+	CreatedAt      time.Time  `json:"created_at"`
+	EditedAt       *time.Time `json:"edited_at"`
+	Deleted        bool       `json:"deleted"`
+	// ReplyToID — сообщение того же диалога, на которое это ответ
+	ReplyToID int           `json:"reply_to_id,omitempty"`
+	ReplyTo   *ReplyPreview `json:"reply_to,omitempty"`
+	// ForwardedFrom — первоисточник, если сообщение переслано
+	ForwardedFrom *ForwardInfo `json:"forwarded_from,omitempty"`
+	// Reactions — реакции по эмодзи с отметкой, есть ли среди них реакция читающего
+	Reactions []ReactionCount `json:"reactions,omitempty"`
+}
+
+type GroupMessage struct {
+	ID             int           `json:"id"`
+	GroupID        int           `json:"group_id"`
+	SenderID       int           `json:"sender_id"`
+	SenderUsername string        `json:"sender_username"`
+	Content        string        `json:"content"`
+	MediaURL       string        `json:"media_url"`
+	MediaType      string        `json:"media_type"`
+	CreatedAt      time.Time     `json:"created_at"`
+	EditedAt       *time.Time    `json:"edited_at"`
+	Deleted        bool          `json:"deleted"`
+	ReplyToID      int           `json:"reply_to_id,omitempty"`
+	ReplyTo        *ReplyPreview `json:"reply_to,omitempty"`
+	ForwardedFrom  *ForwardInfo  `json:"forwarded_from,omitempty"`
+	// ThreadRootID — корень ветки, в которой это ответ; 0 — основная лента
+	ThreadRootID int `json:"thread_root_id,omitempty"`
+	// У корня ветки: число ответов и время последнего
+	ThreadReplyCount  int             `json:"thread_reply_count"`
+	ThreadLastReplyAt *time.Time      `json:"thread_last_reply_at"`
+	Reactions         []ReactionCount `json:"reactions,omitempty"`
+}
+
+// ReplyPreview — цитата сообщения, на которое ответили. Если оригинал
+// удалён, от него остаётся заглушка: Deleted и текст «Сообщение удалено».
+type ReplyPreview struct {
+	ID             int    `json:"id"`
+	SenderID       int    `json:"sender_id"`
+	SenderUsername string `json:"sender_username"`
+	Snippet        string `json:"snippet"`
+	MediaType      string `json:"media_type"`
+	Deleted        bool   `json:"deleted"`
+}
+
+// ForwardInfo — откуда переслано сообщение. При пересылке пересланного
+// остаётся первоисточник. SenderID == 0 — аккаунт автора удалён.
+type ForwardInfo struct {
+	SenderID       int    `json:"sender_id"`
+	SenderUsername string `json:"sender_username"`
+	ChatType       string `json:"chat_type"`
+	ChatID         int    `json:"chat_id"`
+	MessageID      int    `json:"message_id"`
+}
+
+// MessagePage — страница истории. NextCursor передаётся как before_id для
+// более старых сообщений, PrevCursor — как after_id для более новых; 0 — конец.
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor int       `json:"next_cursor"`
+	PrevCursor int       `json:"prev_cursor"`
+}
+
+type GroupMessagePage struct {
+	Messages   []GroupMessage `json:"messages"`
+	NextCursor int            `json:"next_cursor"`
+	PrevCursor int            `json:"prev_cursor"`
+}
+
+// ThreadPage — страница ответов ветки с её корнем; курсоры как у MessagePage
+type ThreadPage struct {
+	Root       GroupMessage   `json:"root"`
+	Messages   []GroupMessage `json:"messages"`
+	NextCursor int            `json:"next_cursor"`
+	PrevCursor int            `json:"prev_cursor"`
+}
+
+// MessageEdit — одна запись истории правок
+type MessageEdit struct {
+	ID         int       `json:"id"`
+	OldContent string    `json:"old_content"`
+	EditedAt   time.Time `json:"edited_at"`
+}
+
+// GroupReceipt — сводка «прочитано N из M» по сообщению группы
+type GroupReceipt struct {
+	MessageID      int           `json:"message_id"`
+	MemberCount    int           `json:"member_count"`
+	DeliveredCount int           `json:"delivered_count"`
+	ReadCount      int           `json:"read_count"`
+	ReadBy         []ReceiptUser `json:"read_by"`
+}
+
+type ReceiptUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
 }
 
 type Conversation struct {
@@ -19,15 +120,55 @@ type Conversation struct {
 	OtherUsername string    `json:"other_username"`
 	LastMessage   string    `json:"last_message"`
 	CreatedAt     time.Time `json:"created_at"`
+	UnreadCount   int       `json:"unread_count"`
+	// Отметки собеседника — чтобы показать галочки у своих сообщений
+	OtherLastDeliveredID int `json:"other_last_delivered_id"`
+	OtherLastReadID      int `json:"other_last_read_id"`
+	// Присутствие собеседника
+	OtherOnline     bool       `json:"other_online"`
+	OtherLastSeenAt *time.Time `json:"other_last_seen_at"`
+	// PinnedMessage — последнее закреплённое сообщение; nil — закрепов нет
+	PinnedMessage *PinnedMessage `json:"pinned_message"`
 }
 
 type WSMessage struct {
-	Type           string `json:"type"`
-	ConversationID int    `json:"conversation_id"`
-	GroupID        int    `json:"group_id"`
-	Content        string `json:"content"`
-	MediaURL       string `json:"media_url"`
-	MediaType      string `json:"media_type"`
-	SenderID       int    `json:"sender_id"`
-	SenderUsername string `json:"sender_username"`
+	Type           string     `json:"type"`
+	MessageID      int        `json:"message_id"`
+	ConversationID int        `json:"conversation_id"`
+	GroupID        int        `json:"group_id"`
+	Content        string     `json:"content"`
+	MediaURL       string     `json:"media_url"`
+	MediaType      string     `json:"media_type"`
+	SenderID       int        `json:"sender_id"`
+	SenderUsername string     `json:"sender_username"`
+	UserID         int        `json:"user_id,omitempty"`  // кто доставил/прочитал — для отметок
+	LastSeq        int64      `json:"last_seq,omitempty"` // для sync: последний обработанный seq
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+	EditedAt       *time.Time `json:"edited_at,omitempty"`
+	Deleted        bool       `json:"deleted,omitempty"`
+	// ReplyToID — в запросе: на какое сообщение ответ; в рассылке к нему
+	// добавляется цитата ReplyTo
+	ReplyToID int           `json:"reply_to_id,omitempty"`
+	ReplyTo   *ReplyPreview `json:"reply_to,omitempty"`
+	// ForwardedFrom — в рассылке пересланной копии
+	ForwardedFrom *ForwardInfo `json:"forwarded_from,omitempty"`
+	// ThreadRootID — в group_message: ответить в ветку этого сообщения;
+	// в message_edit и message_delete — ветка, где лежит ответ;
+	// в thread_update приходят новые счётчики корня (их нет, если после
+	// удаления ответов в ветке не осталось)
+	ThreadRootID      int        `json:"thread_root_id,omitempty"`
+	ThreadReplyCount  int        `json:"thread_reply_count,omitempty"`
+	ThreadLastReplyAt *time.Time `json:"thread_last_reply_at,omitempty"`
+	// Emoji — реакция в reaction_add и reaction_remove
+	Emoji string `json:"emoji,omitempty"`
+	// Pin — закреплённое сообщение в message_pinned
+	Pin *PinnedMessage `json:"pin,omitempty"`
+}
+
+// WSError отправляется клиенту, когда запрос по сокету отклонён
+type WSError struct {
+	Type        string `json:"type"` // всегда "error"
+	RequestType string `json:"request_type"`
+	Code        string `json:"code"`
+	Message     string `json:"message"`
 }
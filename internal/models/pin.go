@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PinnedMessage — закреплённое сообщение чата. PinnedBy == 0 — аккаунт
+// закрепившего удалён.
+type PinnedMessage struct {
+	MessageID        int       `json:"message_id"`
+	SenderID         int       `json:"sender_id"`
+	SenderUsername   string    `json:"sender_username"`
+	Content          string    `json:"content"`
+	MediaURL         string    `json:"media_url"`
+	MediaType        string    `json:"media_type"`
+	CreatedAt        time.Time `json:"created_at"`
+	PinnedBy         int       `json:"pinned_by"`
+	PinnedByUsername string    `json:"pinned_by_username"`
+	PinnedAt         time.Time `json:"pinned_at"`
+}
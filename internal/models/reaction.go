@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ReactionCount — сколько раз поставили эмодзи и есть ли среди них моя реакция
+type ReactionCount struct {
+	Emoji       string `json:"emoji"`
+	Count       int    `json:"count"`
+	ReactedByMe bool   `json:"reacted_by_me"`
+}
+
+// Reaction — кто и какую реакцию поставил
+type Reaction struct {
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username"`
+	Emoji     string    `json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int       `json:"-"`
+	DeviceName string    `json:"device_name"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// TokenPair — ответ входа и обновления токенов
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // секунды жизни access-токена
+	SessionID    string `json:"session_id"`
+}
+
+// TwoFactorChallenge — ответ на верный пароль при включённой 2FA:
+// вход завершается через /api/login/2fa с этим токеном и кодом
+type TwoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+// TwoFactorLoginRequest — второй шаг входа: TOTP-код или код восстановления
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+	DeviceName     string `json:"device_name"`
+}
+
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recovery_codes_left"`
+}
+
+// RecoveryCodes показываются один раз — в БД хранятся только хэши
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Причины неудачного входа в журнале
+const (
+	LoginBadPassword = "bad_password"
+	LoginBad2FACode  = "bad_2fa_code"
+	LoginLocked      = "locked"
+)
+
+type LoginAttempt struct {
+	ID        int       `json:"id"`
+	UserID    *int      `json:"-"`
+	Username  string    `json:"username"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -1,13 +1,32 @@
 package models
 
+import "time"
+
 type User struct {
-	ID          int    `json:"id"`
-	Username    string `json:"username"`
-	Password    string `json:"password"`
-	DisplayName string `json:"display_name"`
-	UserTag     string `json:"user_tag"`
-	Bio         string `json:"bio"`
-	AvatarURL   string `json:"avatar_url"`
+	ID            int        `json:"id"`
+	Username      string     `json:"username"`
+	Password      string     `json:"password"`
+	DisplayName   string     `json:"display_name"`
+	UserTag       string     `json:"user_tag"`
+	Bio           string     `json:"bio"`
+	AvatarURL     string     `json:"avatar_url"`
+	Email         string     `json:"email,omitempty"` // виден только владельцу
+	EmailVerified bool       `json:"email_verified,omitempty"`
+	Online        bool       `json:"online"`
+	LastSeenAt    *time.Time `json:"last_seen_at"`
+	// DeleteAfter — когда аккаунт будет удалён, если удаление запрошено
+	DeleteAfter *time.Time `json:"delete_after,omitempty"`
+	// DeletionRequestedAt — когда запрошено удаление; наружу не отдаётся
+	DeletionRequestedAt *time.Time `json:"-"`
+}
+
+// PresenceEvent рассылается собеседникам, когда пользователь появляется в сети
+// (первое устройство) или уходит из неё (последнее устройство)
+type PresenceEvent struct {
+	Type       string     `json:"type"` // всегда "presence"
+	UserID     int        `json:"user_id"`
+	Online     bool       `json:"online"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
 }
 
 type RegisterRequest struct {
@@ -15,14 +34,16 @@ type RegisterRequest struct {
 	Password    string `json:"password"`
 	DisplayName string `json:"display_name"`
 	UserTag     string `json:"user_tag"`
+	Email       string `json:"email"`
 }
 
 type LoginRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	DeviceName string `json:"device_name"`
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	TokenPair
+	User User `json:"user"`
 }
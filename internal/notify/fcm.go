@@ -1,4 +1,4 @@
-package ws
+package notify
 
 import (
 	"bytes"
@@ -15,8 +15,10 @@ import (
 	"log"
 	"net/http"
 	"net/url"
-	"os"
+	"sync"
 	"time"
+
+	"your_project/internal/pkg/shutdown"
 )
 
 const (
@@ -25,24 +27,44 @@ const (
 	fcmScope     = "https://www.googleapis.com/auth/firebase.messaging"
 )
 
-var cachedFcmToken string
-var fcmTokenExpiry time.Time
-
 type serviceAccountJSON struct {
 	ClientEmail string `json:"client_email"`
 	PrivateKey  string `json:"private_key"`
 }
 
-func SendFcmNotification(toUserID int, data map[string]string) {
-	var fcmToken string
-	err := GlobalHub.DB.QueryRow(
-		`SELECT COALESCE(fcm_token, '') FROM users WHERE id = $1`, toUserID,
-	).Scan(&fcmToken)
+// TokenSource отдаёт FCM-токен устройства пользователя; пустой — push не слать
+type TokenSource interface {
+	FCMToken(userID int) (string, error)
+}
+
+// FCM отправляет push через Firebase Cloud Messaging HTTP v1
+type FCM struct {
+	Tokens TokenSource
+	// ServiceAccount — JSON сервисного аккаунта Firebase
+	ServiceAccount string
+	Client         *http.Client
+
+	mu          sync.Mutex
+	cachedToken string
+	tokenExpiry time.Time
+	// sending — отправки в процессе; их ждёт Shutdown
+	sending sync.WaitGroup
+}
+
+func NewFCM(tokens TokenSource, serviceAccount string) *FCM {
+	return &FCM{Tokens: tokens, ServiceAccount: serviceAccount, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (f *FCM) Notify(toUserID int, data map[string]string) {
+	f.sending.Add(1)
+	defer f.sending.Done()
+
+	fcmToken, err := f.Tokens.FCMToken(toUserID)
 	if err != nil || fcmToken == "" {
 		return
 	}
 
-	accessToken, err := getFcmAccessToken()
+	accessToken, err := f.accessToken()
 	if err != nil {
 		log.Printf("FCM: get access token error: %v", err)
 		return
@@ -57,7 +79,7 @@ func SendFcmNotification(toUserID int, data map[string]string) {
 	if body == "" {
 		body = "📎 Медиафайл"
 	}
-	if data["type"] == "group_message" {
+	if data["type"] == "group_message" || data["type"] == "thread_message" {
 		if data["group_name"] != "" {
 			title = data["group_name"]
 		}
@@ -79,9 +101,9 @@ func SendFcmNotification(toUserID int, data map[string]string) {
 			"android": map[string]interface{}{
 				"priority": "high",
 				"notification": map[string]interface{}{
-					"channel_id":          "messages",
+					"channel_id":            "messages",
 					"notification_priority": "PRIORITY_MAX",
-					"sound":               "default",
+					"sound":                 "default",
 				},
 			},
 		},
@@ -96,8 +118,7 @@ func SendFcmNotification(toUserID int, data map[string]string) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+accessToken)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := f.Client.Do(req)
 	if err != nil {
 		log.Printf("FCM send error: %v", err)
 		return
@@ -106,18 +127,23 @@ func SendFcmNotification(toUserID int, data map[string]string) {
 	log.Printf("FCM v1 sent to user %d, status: %d", toUserID, resp.StatusCode)
 }
 
-func getFcmAccessToken() (string, error) {
-	if cachedFcmToken != "" && time.Now().Before(fcmTokenExpiry) {
-		return cachedFcmToken, nil
+func (f *FCM) Shutdown(ctx context.Context) error {
+	return shutdown.Wait(ctx, f.sending.Wait)
+}
+
+func (f *FCM) accessToken() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cachedToken != "" && time.Now().Before(f.tokenExpiry) {
+		return f.cachedToken, nil
 	}
 
-	saJSON := os.Getenv("FCM_SERVICE_ACCOUNT")
-	if saJSON == "" {
+	if f.ServiceAccount == "" {
 		return "", fmt.Errorf("FCM_SERVICE_ACCOUNT not set")
 	}
 
 	var sa serviceAccountJSON
-	if err := json.Unmarshal([]byte(saJSON), &sa); err != nil {
+	if err := json.Unmarshal([]byte(f.ServiceAccount), &sa); err != nil {
 		return "", fmt.Errorf("parse service account JSON: %v", err)
 	}
 
@@ -146,7 +172,7 @@ func getFcmAccessToken() (string, error) {
 		return "", fmt.Errorf("build JWT: %v", err)
 	}
 
-	resp, err := http.PostForm(fcmTokenURL, url.Values{
+	resp, err := f.Client.PostForm(fcmTokenURL, url.Values{
 		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
 		"assertion":  {jwt},
 	})
@@ -163,8 +189,8 @@ func getFcmAccessToken() (string, error) {
 		return "", fmt.Errorf("no access_token: %v", result)
 	}
 
-	cachedFcmToken = token
-	fcmTokenExpiry = now.Add(55 * time.Minute)
+	f.cachedToken = token
+	f.tokenExpiry = now.Add(55 * time.Minute)
 	return token, nil
 }
 
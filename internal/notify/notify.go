@@ -0,0 +1,18 @@
+package notify
+
+import "context"
+
+// Notifier доставляет push-уведомления пользователям, у которых нет
+// ни одного подключённого устройства
+type Notifier interface {
+	Notify(userID int, data map[string]string)
+	// Shutdown ждёт уже начатые отправки, но не дольше ctx
+	Shutdown(ctx context.Context) error
+}
+
+// Nop — уведомления выключены (нет FCM_SERVICE_ACCOUNT, тесты)
+type Nop struct{}
+
+func (Nop) Notify(userID int, data map[string]string) {}
+
+func (Nop) Shutdown(ctx context.Context) error { return nil }
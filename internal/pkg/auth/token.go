@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// Access-токен живёт недолго: отзыв сессии для HTTP срабатывает сразу
+	// через проверку sessions, а для чужих сервисов — не позже чем через TTL
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+	// ChallengeTokenTTL — сколько ждём TOTP-код после верного пароля
+	ChallengeTokenTTL = 5 * time.Minute
+)
+
+var ErrInvalidToken = errors.New("неверный токен")
+
+// Claims — то, что сервер кладёт в access-токен
+type Claims struct {
+	UserID    int
+	Username  string
+	SessionID string
+}
+
+// Tokens выпускает и проверяет JWT, подписанные секретом из конфига
+type Tokens struct {
+	secret []byte
+}
+
+func NewTokens(secret string) *Tokens {
+	return &Tokens{secret: []byte(secret)}
+}
+
+func (t *Tokens) keyFunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("неожиданный алгоритм %v", token.Header["alg"])
+	}
+	return t.secret, nil
+}
+
+func (t *Tokens) IssueAccessToken(userID int, username, sessionID string) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id":  userID,
+		"username": username,
+		"sid":      sessionID,
+		"exp":      time.Now().Add(AccessTokenTTL).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(t.secret)
+}
+
+// ParseAccessToken проверяет подпись и срок. Токены без sid (выданные до
+// появления сессий) не принимаются — клиенту нужно войти заново.
+func (t *Tokens) ParseAccessToken(tokenStr string) (*Claims, error) {
+	token, err := jwt.Parse(tokenStr, t.keyFunc)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+	mc, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	userID, _ := mc["user_id"].(float64)
+	username, _ := mc["username"].(string)
+	sessionID, _ := mc["sid"].(string)
+	if userID == 0 || sessionID == "" {
+		return nil, ErrInvalidToken
+	}
+	return &Claims{UserID: int(userID), Username: username, SessionID: sessionID}, nil
+}
+
+// IssueChallengeToken выдаёт токен второго шага входа. Он не содержит sid,
+// поэтому ParseAccessToken его не примет.
+func (t *Tokens) IssueChallengeToken(userID int) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"purpose": "2fa",
+		"exp":     time.Now().Add(ChallengeTokenTTL).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(t.secret)
+}
+
+// ParseChallengeToken возвращает ID пользователя из токена второго шага
+func (t *Tokens) ParseChallengeToken(tokenStr string) (int, error) {
+	token, err := jwt.Parse(tokenStr, t.keyFunc)
+	if err != nil || !token.Valid {
+		return 0, ErrInvalidToken
+	}
+	mc, ok := token.Claims.(jwt.MapClaims)
+	if !ok || mc["purpose"] != "2fa" {
+		return 0, ErrInvalidToken
+	}
+	userID, _ := mc["user_id"].(float64)
+	if userID == 0 {
+		return 0, ErrInvalidToken
+	}
+	return int(userID), nil
+}
+
+// NewRefreshToken возвращает случайный refresh-токен и его хэш для хранения в БД
+func NewRefreshToken() (token, hash string, err error) {
+	return NewOpaqueToken()
+}
+
+// NewOpaqueToken — случайный токен для ссылок и его хэш (refresh, письма)
+func NewOpaqueToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken — sha256 от непрозрачного токена; в БД открытые токены не храним
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func NewSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -2,26 +2,15 @@ package database
 
 import (
 	"database/sql"
-	"fmt"
 	"log"
-	"os"
 
 	_ "github.com/lib/pq"
-)
-
-var DB *sql.DB
 
-func Connect() {
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=require",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-	)
+	"your_project/internal/config"
+)
 
-	db, err := sql.Open("postgres", connStr)
+func Connect(cfg config.Database) *sql.DB {
+	db, err := sql.Open("postgres", cfg.DSN())
 	if err != nil {
 		log.Fatal("Ошибка подключения к БД:", err)
 	}
@@ -30,6 +19,6 @@ func Connect() {
 		log.Fatal("БД не отвечает:", err)
 	}
 
-	DB = db
 	log.Println("PostgreSQL подключён")
+	return db
 }
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ConnectRedis принимает как redis://-URL, так и просто host:port
+func ConnectRedis(redisURL string) *redis.Client {
+	var opts *redis.Options
+	if strings.Contains(redisURL, "://") {
+		parsed, err := redis.ParseURL(redisURL)
+		if err != nil {
+			log.Fatal("Неверный REDIS_URL:", err)
+		}
+		opts = parsed
+	} else {
+		opts = &redis.Options{Addr: redisURL}
+	}
+
+	rdb := redis.NewClient(opts)
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		log.Fatal("Redis не отвечает:", err)
+	}
+	log.Println("Redis подключён")
+	return rdb
+}
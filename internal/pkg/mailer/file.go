@@ -0,0 +1,29 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileMailer для разработки: сохраняет письма в Dir как .eml,
+// а без Dir просто пишет их в лог
+type FileMailer struct {
+	Dir string
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	if m.Dir == "" {
+		log.Printf("Письмо для %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+		return nil
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+	to := strings.NewReplacer("@", "_at_", "/", "_").Replace(msg.To)
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), to)
+	return os.WriteFile(filepath.Join(m.Dir, name), format("elowy@localhost", msg), 0o644)
+}
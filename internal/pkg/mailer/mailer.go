@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"log"
+
+	"your_project/internal/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма. В продакшене — SMTPMailer, при разработке
+// письма пишутся в каталог или в лог.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New выбирает реализацию по конфигу: SMTPHost — отправка через SMTP,
+// Dir — файлы .eml в каталоге, иначе письма только логируются
+func New(cfg config.Mail) Mailer {
+	if cfg.SMTPHost != "" {
+		m, err := NewSMTP(cfg)
+		if err == nil {
+			return m
+		}
+		// config.Load такой адрес не пропускает; сюда попадает только
+		// конфиг, собранный в обход него
+		log.Println("SMTP не настроен:", err)
+	}
+	if cfg.Dir != "" {
+		return &FileMailer{Dir: cfg.Dir}
+	}
+	log.Println("SMTP не настроен, письма пишутся в лог")
+	return &FileMailer{}
+}
@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+
+	"your_project/internal/config"
+)
+
+func TestNewSMTPParsesFrom(t *testing.T) {
+	for _, tc := range []struct {
+		from, envelope, header string
+	}{
+		{"no-reply@elowy.app", "no-reply@elowy.app", "From: <no-reply@elowy.app>\r\n"},
+		{"Elowy <no-reply@elowy.app>", "no-reply@elowy.app", "From: \"Elowy\" <no-reply@elowy.app>\r\n"},
+		{"Элоуи <no-reply@elowy.app>", "no-reply@elowy.app", "From: =?utf-8?q?"},
+	} {
+		m, err := NewSMTP(config.Mail{SMTPHost: "smtp.example.com", From: tc.from})
+		if err != nil {
+			t.Fatalf("%q: %v", tc.from, err)
+		}
+		if m.From.Address != tc.envelope {
+			t.Errorf("%q: конверт %q, ждали %q", tc.from, m.From.Address, tc.envelope)
+		}
+		if got := string(format(m.From.String(), Message{To: "a@b.c"})); !strings.HasPrefix(got, tc.header) {
+			t.Errorf("%q: заголовок %q, ждали %q", tc.from, strings.SplitN(got, "\r\n", 2)[0], tc.header)
+		}
+	}
+
+	for _, from := range []string{"", "не адрес", "a@b.c\r\nBcc: x@y.z"} {
+		if _, err := NewSMTP(config.Mail{SMTPHost: "smtp.example.com", From: from}); err == nil {
+			t.Errorf("NewSMTP(%q) без ошибки", from)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Package migrate применяет версионированные SQL-миграции и ведёт их учёт
+// в таблице schema_migrations
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Migration — одна версия схемы: SQL для наката и для отката
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status — состояние миграции в базе
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load читает миграции из fsys; у каждой версии должны быть и up-, и down-файл
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		m := fileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || m == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(m[1])
+		body, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("миграция %d: разные имена %q и %q", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("миграция %03d_%s: нужны оба файла, .up.sql и .down.sql", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// lockID — ключ pg_advisory_lock, чтобы две реплики не накатывали миграции одновременно
+const lockID = 7_310_418
+
+// Migrator накатывает и откатывает миграции в DB
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// withLock выполняет fn на отдельном соединении под advisory-блокировкой
+func (m *Migrator) withLock(fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockID)
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`); err != nil {
+		return err
+	}
+	return fn(ctx, conn)
+}
+
+func applied(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[int]time.Time)
+	for rows.Next() {
+		var v int
+		var at time.Time
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		versions[v] = at
+	}
+	return versions, rows.Err()
+}
+
+// pending — ещё не применённые миграции в порядке наката
+func pending(migrations []Migration, versions map[int]time.Time) []Migration {
+	var todo []Migration
+	for _, mig := range migrations {
+		if _, ok := versions[mig.Version]; !ok {
+			todo = append(todo, mig)
+		}
+	}
+	return todo
+}
+
+// lastApplied — не больше steps применённых миграций в порядке отката,
+// от последней к первой
+func lastApplied(migrations []Migration, versions map[int]time.Time, steps int) []Migration {
+	var todo []Migration
+	for i := len(migrations) - 1; i >= 0 && len(todo) < steps; i-- {
+		if _, ok := versions[migrations[i].Version]; ok {
+			todo = append(todo, migrations[i])
+		}
+	}
+	return todo
+}
+
+func statusOf(migrations []Migration, versions map[int]time.Time) []Status {
+	statuses := make([]Status, 0, len(migrations))
+	for _, mig := range migrations {
+		s := Status{Migration: mig}
+		if at, ok := versions[mig.Version]; ok {
+			s.AppliedAt = &at
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// run накатывает (up) или откатывает миграцию вместе с правкой
+// schema_migrations в одной транзакции
+func run(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := mig.Down
+	if up {
+		query = mig.Up
+	}
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("миграция %03d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mig.Version, mig.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Up накатывает все неприменённые миграции по порядку и возвращает их
+func (m *Migrator) Up() ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range pending(m.Migrations, versions) {
+			if err := run(ctx, conn, mig, true); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down откатывает steps последних применённых миграций и возвращает их
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range lastApplied(m.Migrations, versions, steps) {
+			if err := run(ctx, conn, mig, false); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status — все известные миграции с отметкой, применены ли они
+func (m *Migrator) Status() ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		statuses = statusOf(m.Migrations, versions)
+		return nil
+	})
+	return statuses, err
+}
+
+// Pending — число ещё не применённых миграций
+func (m *Migrator) Pending() (int, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
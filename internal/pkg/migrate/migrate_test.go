@@ -0,0 +1,125 @@
+package migrate
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"your_project/migrations"
+)
+
+func file(body string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(body)}
+}
+
+func versionsOf(migs []Migration) string {
+	var vs []string
+	for _, m := range migs {
+		vs = append(vs, fmt.Sprint(m.Version))
+	}
+	return strings.Join(vs, ",")
+}
+
+func TestLoadOrdersByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"010_tenth.up.sql":    file("up 10"),
+		"010_tenth.down.sql":  file("down 10"),
+		"002_second.up.sql":   file("up 2"),
+		"002_second.down.sql": file("down 2"),
+		"001_init.down.sql":   file("down 1"),
+		"001_init.up.sql":     file("up 1"),
+		"README.md":           file("не миграция"),
+		"old/003_x.up.sql":    file("в подкаталоге не читается"),
+	}
+	migs, err := Load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := versionsOf(migs); got != "1,2,10" {
+		t.Fatalf("порядок %s, ждали 1,2,10", got)
+	}
+	if m := migs[2]; m.Name != "tenth" || m.Up != "up 10" || m.Down != "down 10" {
+		t.Fatalf("миграция 10 = %+v", m)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"нет down": {
+			"001_init.up.sql": file("up"),
+		},
+		"нет up": {
+			"001_init.down.sql": file("down"),
+		},
+		"разные имена": {
+			"001_init.up.sql":    file("up"),
+			"001_other.down.sql": file("down"),
+		},
+	} {
+		if _, err := Load(fsys); err == nil {
+			t.Errorf("%s: Load без ошибки", name)
+		}
+	}
+}
+
+// Встроенные миграции должны загружаться и идти без пропусков с 1
+func TestEmbeddedMigrations(t *testing.T) {
+	migs, err := Load(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migs) == 0 {
+		t.Fatal("нет миграций")
+	}
+	for i, m := range migs {
+		if m.Version != i+1 {
+			t.Fatalf("после версии %d идёт %d", i, m.Version)
+		}
+	}
+}
+
+func TestPlan(t *testing.T) {
+	migs := []Migration{{Version: 1}, {Version: 2}, {Version: 3}, {Version: 4}}
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 2 не применена: например, её добавили в ветке, слитой после 3 и 4
+	applied := map[int]time.Time{1: at, 3: at, 4: at}
+
+	if got := versionsOf(pending(migs, applied)); got != "2" {
+		t.Errorf("pending = %s, ждали 2", got)
+	}
+	if got := versionsOf(pending(migs, nil)); got != "1,2,3,4" {
+		t.Errorf("pending на пустой базе = %s", got)
+	}
+	if got := versionsOf(pending(migs, map[int]time.Time{1: at, 2: at, 3: at, 4: at})); got != "" {
+		t.Errorf("pending на актуальной базе = %s", got)
+	}
+
+	for _, tc := range []struct {
+		steps int
+		want  string
+	}{
+		{0, ""},
+		{1, "4"},
+		{2, "4,3"},
+		{3, "4,3,1"},
+		{10, "4,3,1"},
+	} {
+		if got := versionsOf(lastApplied(migs, applied, tc.steps)); got != tc.want {
+			t.Errorf("lastApplied(%d) = %s, ждали %s", tc.steps, got, tc.want)
+		}
+	}
+
+	statuses := statusOf(migs, applied)
+	var marks []string
+	for _, s := range statuses {
+		marks = append(marks, fmt.Sprint(s.Version, s.AppliedAt != nil))
+	}
+	if got := strings.Join(marks, ","); got != "1 true,2 false,3 true,4 true" {
+		t.Errorf("statusOf = %s", got)
+	}
+	if !statuses[0].AppliedAt.Equal(at) {
+		t.Errorf("AppliedAt = %v", statuses[0].AppliedAt)
+	}
+}
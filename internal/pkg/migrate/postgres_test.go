@@ -0,0 +1,134 @@
+package migrate_test
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"your_project/internal/pkg/migrate"
+	"your_project/internal/repository"
+	"your_project/internal/repository/repotest"
+	"your_project/migrations"
+)
+
+// dsnEnv — строка подключения к PostgreSQL для тестов; без неё они пропускаются.
+// Каждый тест работает в своей схеме и удаляет её в конце.
+const dsnEnv = "TEST_DATABASE_DSN"
+
+// withSearchPath добавляет к DSN параметр search_path: lib/pq передаёт
+// незнакомые ключи серверу как параметры сессии
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		return dsn + sep + "search_path=" + url.QueryEscape(schema)
+	}
+	return dsn + " search_path=" + schema
+}
+
+func openSchema(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skip(dsnEnv + " не задан")
+	}
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		admin.Close()
+		t.Fatal(err)
+	}
+	db, err := sql.Open("postgres", withSearchPath(dsn, schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Errorf("DROP SCHEMA %s: %v", schema, err)
+		}
+		admin.Close()
+	})
+	return db
+}
+
+func pendingCount(t *testing.T, m *migrate.Migrator) int {
+	t.Helper()
+	n, err := m.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// TestPostgres накатывает все миграции, откатывает их, накатывает снова и
+// прогоняет контракт хранилищ над получившейся схемой
+func TestPostgres(t *testing.T) {
+	db := openSchema(t)
+	m, err := migrate.New(db, migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := len(m.Migrations)
+
+	done, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != total || pendingCount(t, m) != 0 {
+		t.Fatalf("Up применил %d из %d", len(done), total)
+	}
+	if done, err := m.Up(); err != nil || len(done) != 0 {
+		t.Fatalf("повторный Up = %d, %v", len(done), err)
+	}
+
+	done, err = m.Down(total)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != total || done[0].Version != m.Migrations[total-1].Version || pendingCount(t, m) != total {
+		t.Fatalf("Down откатил %d из %d", len(done), total)
+	}
+	var leftover []string
+	rows, err := db.Query(`SELECT table_name FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name != 'schema_migrations'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var name string
+		rows.Scan(&name)
+		leftover = append(leftover, name)
+	}
+	rows.Close()
+	if len(leftover) > 0 {
+		t.Fatalf("после полного отката остались таблицы %v", leftover)
+	}
+
+	if _, err := m.Up(); err != nil {
+		t.Fatalf("Up после отката: %v", err)
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Fatalf("миграция %03d_%s не применена", s.Version, s.Name)
+		}
+	}
+
+	repos := repository.New(db)
+	repotest.Run(t, func(t *testing.T) *repository.Repositories { return repos })
+}
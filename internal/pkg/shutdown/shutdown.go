@@ -0,0 +1,20 @@
+// Package shutdown помогает останавливать подсистемы с общим тайм-аутом
+package shutdown
+
+import "context"
+
+// Wait выполняет fn (обычно wg.Wait или закрытие пула), но ждёт её не
+// дольше ctx. По тайм-ауту fn продолжает работать в фоне.
+func Wait(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
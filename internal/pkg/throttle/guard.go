@@ -0,0 +1,109 @@
+package throttle
+
+import (
+	"log"
+	"time"
+)
+
+// Policy описывает, как растёт задержка с числом неудач по одному ключу
+type Policy struct {
+	// FreeAttempts неудач проходят без задержки
+	FreeAttempts int
+	// после них задержка BaseDelay, удваивающаяся с каждой неудачей до MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// после LockoutAfter неудач ключ блокируется на LockoutDuration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	// Window — через сколько без неудач счётчик обнуляется
+	Window time.Duration
+}
+
+// Delay — сколько нужно ждать после последней неудачи при count неудачах
+func (p Policy) Delay(count int) time.Duration {
+	if p.LockoutAfter > 0 && count >= p.LockoutAfter {
+		return p.LockoutDuration
+	}
+	if count <= p.FreeAttempts {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < count && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// Locked — достигнут ли порог блокировки
+func (p Policy) Locked(count int) bool {
+	return p.LockoutAfter > 0 && count >= p.LockoutAfter
+}
+
+// Key — ключ учёта вместе с политикой для него: у адреса IP (за ним может
+// быть NAT) пороги выше, чем у имени пользователя
+type Key struct {
+	Name   string
+	Policy Policy
+}
+
+type Guard struct {
+	Store Store
+}
+
+func NewGuard(store Store) *Guard {
+	return &Guard{Store: store}
+}
+
+// Check возвращает, сколько ещё ждать до следующей попытки (0 — можно) и
+// заблокирован ли какой-то из ключей. Ошибки хранилища не блокируют вход —
+// иначе падение Redis выключило бы логин для всех.
+func (g *Guard) Check(keys ...Key) (wait time.Duration, locked bool) {
+	now := time.Now()
+	for _, k := range keys {
+		a, err := g.Store.Get(k.Name)
+		if err != nil {
+			log.Printf("Ошибка чтения счётчика %s: %v", k.Name, err)
+			continue
+		}
+		if a.Count == 0 {
+			continue
+		}
+		if left := a.Last.Add(k.Policy.Delay(a.Count)).Sub(now); left > wait {
+			wait = left
+			locked = k.Policy.Locked(a.Count)
+		}
+	}
+	return wait, locked
+}
+
+// Fail засчитывает неудачу по всем ключам и сообщает, привела ли она к блокировке
+func (g *Guard) Fail(keys ...Key) (locked bool) {
+	for _, k := range keys {
+		a, err := g.Store.Fail(k.Name, k.Policy.Window)
+		if err != nil {
+			log.Printf("Ошибка записи счётчика %s: %v", k.Name, err)
+			continue
+		}
+		if k.Policy.Locked(a.Count) {
+			locked = true
+		}
+	}
+	return locked
+}
+
+// Close останавливает хранилище счётчиков
+func (g *Guard) Close() {
+	g.Store.Close()
+}
+
+// Reset обнуляет счётчики ключей после успешного входа
+func (g *Guard) Reset(keys ...Key) {
+	for _, k := range keys {
+		if err := g.Store.Reset(k.Name); err != nil {
+			log.Printf("Ошибка сброса счётчика %s: %v", k.Name, err)
+		}
+	}
+}
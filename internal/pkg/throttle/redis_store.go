@@ -0,0 +1,51 @@
+package throttle
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisKeyPrefix = "elowy:throttle:"
+
+// RedisStore делит счётчики между всеми репликами сервера
+type RedisStore struct {
+	rdb *redis.Client
+}
+
+func NewRedisStore(rdb *redis.Client) *RedisStore {
+	return &RedisStore{rdb: rdb}
+}
+
+func (s *RedisStore) Fail(key string, window time.Duration) (Attempt, error) {
+	ctx := context.Background()
+	now := time.Now()
+	k := redisKeyPrefix + key
+	pipe := s.rdb.TxPipeline()
+	count := pipe.HIncrBy(ctx, k, "count", 1)
+	pipe.HSet(ctx, k, "last", now.UnixNano())
+	pipe.Expire(ctx, k, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Attempt{}, err
+	}
+	return Attempt{Count: int(count.Val()), Last: now}, nil
+}
+
+func (s *RedisStore) Get(key string) (Attempt, error) {
+	vals, err := s.rdb.HGetAll(context.Background(), redisKeyPrefix+key).Result()
+	if err != nil {
+		return Attempt{}, err
+	}
+	count, _ := strconv.Atoi(vals["count"])
+	last, _ := strconv.ParseInt(vals["last"], 10, 64)
+	return Attempt{Count: count, Last: time.Unix(0, last)}, nil
+}
+
+func (s *RedisStore) Reset(key string) error {
+	return s.rdb.Del(context.Background(), redisKeyPrefix+key).Err()
+}
+
+// Close ничего не делает: клиент Redis общий, его закрывает владелец
+func (s *RedisStore) Close() {}
@@ -0,0 +1,102 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Attempt — счётчик неудач по ключу (IP, имя пользователя) и время последней
+type Attempt struct {
+	Count int
+	Last  time.Time
+}
+
+// Store хранит счётчики неудачных попыток. Счётчик забывается, если за
+// window не было новых неудач.
+type Store interface {
+	Fail(key string, window time.Duration) (Attempt, error)
+	Get(key string) (Attempt, error)
+	Reset(key string) error
+	// Close останавливает фоновую работу хранилища
+	Close()
+}
+
+// MemoryStore — хранилище одного процесса; с несколькими репликами
+// счётчики у каждой свои, для общего учёта нужен RedisStore
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type memoryEntry struct {
+	Attempt
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{entries: make(map[string]memoryEntry), done: make(chan struct{})}
+	go s.sweep()
+	return s
+}
+
+func (s *MemoryStore) Fail(key string, window time.Duration) (Attempt, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || now.After(e.expires) {
+		e = memoryEntry{}
+	}
+	e.Count++
+	e.Last = now
+	e.expires = now.Add(window)
+	s.entries[key] = e
+	return e.Attempt, nil
+}
+
+func (s *MemoryStore) Get(key string) (Attempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return Attempt{}, nil
+	}
+	return e.Attempt, nil
+}
+
+func (s *MemoryStore) Reset(key string) error {
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// Close останавливает sweep; повторный вызов ничего не делает
+func (s *MemoryStore) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
+
+// sweep раз в минуту выбрасывает истёкшие счётчики, чтобы перебор
+// случайных имён не раздувал память
+func (s *MemoryStore) sweep() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		s.mu.Lock()
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
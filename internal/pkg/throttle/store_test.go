@@ -0,0 +1,42 @@
+package throttle
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreCloseStopsSweep(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		s := NewMemoryStore()
+		s.Close()
+		s.Close()
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("горутин %d, до создания хранилищ было %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMemoryStoreWindow(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+	if a, _ := s.Fail("k", time.Hour); a.Count != 1 {
+		t.Fatalf("первая неудача = %+v", a)
+	}
+	if a, _ := s.Fail("k", time.Hour); a.Count != 2 {
+		t.Fatalf("вторая неудача = %+v", a)
+	}
+	s.Fail("short", -time.Second)
+	if a, _ := s.Get("short"); a.Count != 0 {
+		t.Fatalf("истёкший счётчик = %+v", a)
+	}
+	s.Reset("k")
+	if a, _ := s.Get("k"); a.Count != 0 {
+		t.Fatalf("после Reset = %+v", a)
+	}
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"database/sql"
+	"your_project/internal/models"
+)
+
+type BlockRepository struct {
+	DB *sql.DB
+}
+
+// IsBlockedBetween — заблокировал ли кто-то из двоих другого.
+// Блокировка действует в обе стороны: писать и звонить нельзя ни одному.
+func (r *BlockRepository) IsBlockedBetween(userA, userB int) (bool, error) {
+	var blocked bool
+	err := r.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM blocked_users
+			WHERE (user_id = $1 AND blocked_user_id = $2) OR (user_id = $2 AND blocked_user_id = $1)
+		)`,
+		userA, userB,
+	).Scan(&blocked)
+	return blocked, err
+}
+
+// BlockersOf возвращает пользователей, заблокировавших userID
+func (r *BlockRepository) BlockersOf(userID int) (map[int]bool, error) {
+	rows, err := r.DB.Query(`SELECT user_id FROM blocked_users WHERE blocked_user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	blockers := make(map[int]bool)
+	for rows.Next() {
+		var uid int
+		rows.Scan(&uid)
+		blockers[uid] = true
+	}
+	return blockers, rows.Err()
+}
+
+func (r *BlockRepository) Block(userID, blockedUserID int) error {
+	_, err := r.DB.Exec(
+		`INSERT INTO blocked_users (user_id, blocked_user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		userID, blockedUserID,
+	)
+	return err
+}
+
+func (r *BlockRepository) Unblock(userID, blockedUserID int) error {
+	_, err := r.DB.Exec(
+		`DELETE FROM blocked_users WHERE user_id = $1 AND blocked_user_id = $2`,
+		userID, blockedUserID,
+	)
+	return err
+}
+
+// ListBlocked — кого заблокировал userID
+func (r *BlockRepository) ListBlocked(userID int) ([]models.User, error) {
+	rows, err := r.DB.Query(
+		`SELECT u.id, u.username, COALESCE(u.display_name,''), COALESCE(u.avatar_url,'')
+		FROM blocked_users b JOIN users u ON b.blocked_user_id = u.id
+		WHERE b.user_id = $1`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var users []models.User
+	for rows.Next() {
+		var u models.User
+		rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.AvatarURL)
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"database/sql"
+	"your_project/internal/models"
+)
+
+type ConversationRepository struct {
+	DB *sql.DB
+}
+
+func (r *ConversationRepository) GetOrCreate(userID1, userID2 int) (int, error) {
+	query := `
+		SELECT c.id FROM conversations c
+		JOIN conversation_members m1 ON c.id = m1.conversation_id AND m1.user_id = $1
+		JOIN conversation_members m2 ON c.id = m2.conversation_id AND m2.user_id = $2
+		LIMIT 1`
+	var convID int
+	err := r.DB.QueryRow(query, userID1, userID2).Scan(&convID)
+	if err == nil {
+		return convID, nil
+	}
+	err = r.DB.QueryRow(`INSERT INTO conversations DEFAULT VALUES RETURNING id`).Scan(&convID)
+	if err != nil {
+		return 0, err
+	}
+	_, err = r.DB.Exec(`INSERT INTO conversation_members (conversation_id, user_id) VALUES ($1, $2), ($1, $3)`, convID, userID1, userID2)
+	return convID, err
+}
+
+func (r *ConversationRepository) ListForUser(userID int) ([]models.Conversation, error) {
+	query := `
+		SELECT c.id,
+			u.id as other_user_id,
+			u.username as other_username,
+			COALESCE((SELECT content FROM messages WHERE conversation_id = c.id ORDER BY created_at DESC LIMIT 1), '') as last_message,
+			c.created_at,
+			(SELECT COUNT(*) FROM messages WHERE conversation_id = c.id AND id > cm.last_read_message_id
+				AND sender_id != $1 AND NOT deleted) as unread_count,
+			cm2.last_delivered_message_id, cm2.last_read_message_id,
+			u.last_seen_at
+		FROM conversations c
+		JOIN conversation_members cm ON c.id = cm.conversation_id AND cm.user_id = $1
+		JOIN conversation_members cm2 ON c.id = cm2.conversation_id AND cm2.user_id != $1
+		JOIN users u ON cm2.user_id = u.id
+		ORDER BY c.created_at DESC`
+	rows, err := r.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var convs []models.Conversation
+	for rows.Next() {
+		var conv models.Conversation
+		rows.Scan(&conv.ID, &conv.OtherUserID, &conv.OtherUsername, &conv.LastMessage, &conv.CreatedAt,
+			&conv.UnreadCount, &conv.OtherLastDeliveredID, &conv.OtherLastReadID,
+			&conv.OtherLastSeenAt)
+		convs = append(convs, conv)
+	}
+	return convs, nil
+}
+
+func (r *ConversationRepository) IsMember(conversationID, userID int) (bool, error) {
+	var member bool
+	err := r.DB.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM conversation_members WHERE conversation_id=$1 AND user_id=$2)`,
+		conversationID, userID,
+	).Scan(&member)
+	return member, err
+}
+
+func (r *ConversationRepository) HasBlockedMember(conversationID, userID int) (bool, error) {
+	var blocked bool
+	err := r.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM conversation_members cm
+			JOIN blocked_users b
+				ON (b.user_id = $2 AND b.blocked_user_id = cm.user_id)
+				OR (b.user_id = cm.user_id AND b.blocked_user_id = $2)
+			WHERE cm.conversation_id = $1 AND cm.user_id != $2
+		)`,
+		conversationID, userID,
+	).Scan(&blocked)
+	return blocked, err
+}
+
+func (r *ConversationRepository) MemberIDs(conversationID, excludeUserID int) ([]int, error) {
+	return queryIDs(r.DB,
+		`SELECT user_id FROM conversation_members WHERE conversation_id = $1 AND user_id != $2`,
+		conversationID, excludeUserID,
+	)
+}
+
+func (r *ConversationRepository) ContactIDs(userID int) ([]int, error) {
+	return queryIDs(r.DB, `
+		SELECT DISTINCT cm2.user_id
+		FROM conversation_members cm1
+		JOIN conversation_members cm2 ON cm1.conversation_id = cm2.conversation_id AND cm2.user_id != cm1.user_id
+		WHERE cm1.user_id = $1
+			AND NOT EXISTS (
+				SELECT 1 FROM blocked_users b
+				WHERE (b.user_id = $1 AND b.blocked_user_id = cm2.user_id)
+					OR (b.user_id = cm2.user_id AND b.blocked_user_id = $1)
+			)`,
+		userID,
+	)
+}
+
+// Leave удаляет историю диалога и выводит из него пользователя
+func (r *ConversationRepository) Leave(conversationID, userID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(
+		`DELETE FROM messages WHERE conversation_id = $1 AND conversation_id IN (
+			SELECT conversation_id FROM conversation_members WHERE user_id = $2
+		)`, conversationID, userID,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`DELETE FROM conversation_members WHERE conversation_id = $1 AND user_id = $2`,
+		conversationID, userID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// queryIDs читает список целиком, чтобы не держать соединение с БД во время рассылки
+func queryIDs(db *sql.DB, query string, args ...interface{}) ([]int, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
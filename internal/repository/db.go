@@ -1 +1,39 @@
 package repository
+
+import "database/sql"
+
+// Repositories — все хранилища сервера. New строит их над PostgreSQL,
+// memory.New — в памяти процесса.
+type Repositories struct {
+	Users         UserStore
+	Sessions      SessionStore
+	TwoFactor     TwoFactorStore
+	LoginAttempts LoginAttemptStore
+	EmailTokens   EmailTokenStore
+	Conversations ConversationStore
+	Messages      MessageStore
+	Groups        GroupStore
+	Receipts      ReceiptStore
+	Events        EventStore
+	Blocks        BlockStore
+	Reactions     ReactionStore
+	Pins          PinStore
+}
+
+func New(db *sql.DB) *Repositories {
+	return &Repositories{
+		Users:         &UserRepository{DB: db},
+		Sessions:      &SessionRepository{DB: db},
+		TwoFactor:     &TwoFactorRepository{DB: db},
+		LoginAttempts: &LoginAttemptRepository{DB: db},
+		EmailTokens:   &EmailTokenRepository{DB: db},
+		Conversations: &ConversationRepository{DB: db},
+		Messages:      &MessageRepository{DB: db},
+		Groups:        &GroupRepository{DB: db},
+		Receipts:      &ReceiptRepository{DB: db},
+		Events:        &EventRepository{DB: db},
+		Blocks:        &BlockRepository{DB: db},
+		Reactions:     &ReactionRepository{DB: db},
+		Pins:          &PinRepository{DB: db},
+	}
+}
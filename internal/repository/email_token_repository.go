@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Назначения токенов из писем
+const (
+	TokenVerifyEmail   = "verify"
+	TokenResetPassword = "reset"
+)
+
+var ErrTokenInvalid = errors.New("ссылка недействительна или устарела")
+
+type EmailTokenRepository struct {
+	DB *sql.DB
+}
+
+func (r *EmailTokenRepository) Create(userID int, purpose, email, tokenHash string, expiresAt time.Time) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO email_tokens (token_hash, user_id, purpose, email, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		tokenHash, userID, purpose, email, expiresAt,
+	)
+	return err
+}
+
+// Consume гасит токен и возвращает, кому и на какой адрес он был выдан
+func (r *EmailTokenRepository) Consume(tokenHash, purpose string) (userID int, email string, err error) {
+	err = r.DB.QueryRow(`
+		UPDATE email_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id, email`,
+		tokenHash, purpose,
+	).Scan(&userID, &email)
+	if err == sql.ErrNoRows {
+		return 0, "", ErrTokenInvalid
+	}
+	return userID, email, err
+}
+
+// InvalidateAll гасит все неиспользованные токены пользователя данного назначения
+func (r *EmailTokenRepository) InvalidateAll(userID int, purpose string) error {
+	_, err := r.DB.Exec(
+		`UPDATE email_tokens SET used_at = NOW() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		userID, purpose,
+	)
+	return err
+}
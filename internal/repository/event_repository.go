@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"your_project/internal/models"
+)
+
+type EventRepository struct {
+	DB *sql.DB
+}
+
+// Append записывает событие в ленты пользователей и возвращает их seq.
+// Счётчики увеличиваются и события вставляются одним запросом, поэтому
+// рассылка в большую группу — один round-trip, а seq строго возрастает даже
+// при параллельных вставках с разных узлов. Строки users блокируются по
+// возрастанию id, чтобы встречные рассылки не взаимоблокировались.
+func (r *EventRepository) Append(userIDs []int, eventType string, payload []byte) (map[int]int64, error) {
+	seqs := make(map[int]int64, len(userIDs))
+	if len(userIDs) == 0 {
+		return seqs, nil
+	}
+	ids := make([]int64, len(userIDs))
+	for i, id := range userIDs {
+		ids[i] = int64(id)
+	}
+	rows, err := r.DB.Query(`
+		WITH locked AS (
+			SELECT id FROM users WHERE id = ANY($1) ORDER BY id FOR UPDATE
+		), s AS (
+			UPDATE users u SET event_seq = u.event_seq + 1
+			FROM locked WHERE u.id = locked.id
+			RETURNING u.id, u.event_seq
+		)
+		INSERT INTO user_events (user_id, seq, type, payload)
+		SELECT id, event_seq, $2, $3 FROM s
+		RETURNING user_id, seq`,
+		pq.Array(ids), eventType, string(payload),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID int
+		var seq int64
+		if err := rows.Scan(&userID, &seq); err != nil {
+			return nil, err
+		}
+		seqs[userID] = seq
+	}
+	return seqs, rows.Err()
+}
+
+// Since возвращает до limit событий с seq > afterSeq по возрастанию
+func (r *EventRepository) Since(userID int, afterSeq int64, limit int) ([]models.Event, error) {
+	rows, err := r.DB.Query(
+		`SELECT seq, type, payload FROM user_events
+		WHERE user_id = $1 AND seq > $2 ORDER BY seq ASC LIMIT $3`,
+		userID, afterSeq, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []models.Event
+	for rows.Next() {
+		var e models.Event
+		var payload string
+		if err := rows.Scan(&e.Seq, &e.Type, &payload); err != nil {
+			return nil, err
+		}
+		e.Payload = []byte(payload)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// Bounds возвращает текущий seq пользователя и самый старый из сохранённых
+// (0, если лента пуста)
+func (r *EventRepository) Bounds(userID int) (current, oldest int64, err error) {
+	err = r.DB.QueryRow(`
+		SELECT u.event_seq, COALESCE((SELECT MIN(seq) FROM user_events WHERE user_id = u.id), 0)
+		FROM users u WHERE u.id = $1`,
+		userID,
+	).Scan(&current, &oldest)
+	return current, oldest, err
+}
+
+// Prune удаляет события старше before; клиенты, отставшие сильнее, получат sync_reset
+func (r *EventRepository) Prune(before time.Time) (int64, error) {
+	res, err := r.DB.Exec(`DELETE FROM user_events WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
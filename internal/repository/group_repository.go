@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"database/sql"
+	"your_project/internal/models"
+)
+
+type GroupRepository struct {
+	DB *sql.DB
+}
+
+func (r *GroupRepository) Create(name, avatarURL string, createdBy int, memberIDs []int) (int, []int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	var groupID int
+	if err := tx.QueryRow(
+		`INSERT INTO group_chats (name, avatar_url, created_by) VALUES ($1, $2, $3) RETURNING id`,
+		name, avatarURL, createdBy,
+	).Scan(&groupID); err != nil {
+		return 0, nil, err
+	}
+	// Создатель — админ, остальные — участники; несуществующих и повторы пропускаем
+	if _, err := tx.Exec(
+		`INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, 'admin')`,
+		groupID, createdBy,
+	); err != nil {
+		return 0, nil, err
+	}
+	joined := []int{createdBy}
+	for _, memberID := range memberIDs {
+		if memberID == createdBy {
+			continue
+		}
+		res, err := tx.Exec(`
+			INSERT INTO group_members (group_id, user_id, role)
+			SELECT $1, id, 'member' FROM users WHERE id = $2
+			ON CONFLICT DO NOTHING`,
+			groupID, memberID,
+		)
+		if err != nil {
+			return 0, nil, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			joined = append(joined, memberID)
+		}
+	}
+	return groupID, joined, tx.Commit()
+}
+
+func (r *GroupRepository) ListForUser(userID int) ([]models.GroupSummary, error) {
+	rows, err := r.DB.Query(`
+		SELECT g.id, g.name, COALESCE(g.avatar_url,''),
+			COALESCE((SELECT content FROM group_messages WHERE group_id = g.id AND thread_root_id IS NULL
+				ORDER BY created_at DESC LIMIT 1), '') as last_message,
+			g.created_by,
+			(SELECT COUNT(*) FROM group_messages WHERE group_id = g.id AND thread_root_id IS NULL
+				AND id > gm.last_read_message_id AND sender_id != $1 AND NOT deleted) as unread_count,
+			(SELECT COUNT(*) FROM group_members WHERE group_id = g.id) as member_count
+		FROM group_chats g
+		JOIN group_members gm ON g.id = gm.group_id
+		WHERE gm.user_id = $1
+		ORDER BY g.created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var groups []models.GroupSummary
+	for rows.Next() {
+		var g models.GroupSummary
+		rows.Scan(&g.ID, &g.Name, &g.AvatarURL, &g.LastMessage, &g.CreatedBy, &g.UnreadCount, &g.MemberCount)
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+func (r *GroupRepository) Get(groupID int) (models.GroupInfo, error) {
+	var info models.GroupInfo
+	err := r.DB.QueryRow(
+		`SELECT id, name, COALESCE(avatar_url,''), created_by FROM group_chats WHERE id=$1`,
+		groupID,
+	).Scan(&info.ID, &info.Name, &info.AvatarURL, &info.CreatedBy)
+	if err != nil {
+		return info, err
+	}
+	info.Members, err = r.Members(groupID)
+	return info, err
+}
+
+func (r *GroupRepository) Members(groupID int) ([]models.GroupMember, error) {
+	rows, err := r.DB.Query(`
+		SELECT u.id, u.username, COALESCE(NULLIF(u.display_name,''), u.username), COALESCE(u.avatar_url,''), gm.role
+		FROM group_members gm
+		JOIN users u ON u.id = gm.user_id
+		WHERE gm.group_id = $1
+		ORDER BY gm.role = 'admin' DESC, u.username ASC`,
+		groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var members []models.GroupMember
+	for rows.Next() {
+		var m models.GroupMember
+		rows.Scan(&m.ID, &m.Username, &m.DisplayName, &m.AvatarURL, &m.Role)
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+func (r *GroupRepository) Role(groupID, userID int) (string, error) {
+	var role string
+	err := r.DB.QueryRow(
+		`SELECT role FROM group_members WHERE group_id=$1 AND user_id=$2`,
+		groupID, userID,
+	).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", ErrNotChatMember
+	}
+	return role, err
+}
+
+func (r *GroupRepository) Update(groupID int, name, avatarURL string) error {
+	_, err := r.DB.Exec(
+		`UPDATE group_chats SET name=$1, avatar_url=$2 WHERE id=$3`,
+		name, avatarURL, groupID,
+	)
+	return err
+}
+
+// AddMember добавляет участника; false — он уже состоит в группе
+func (r *GroupRepository) AddMember(groupID, userID int, role string) (bool, error) {
+	res, err := r.DB.Exec(
+		`INSERT INTO group_members (group_id, user_id, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+		groupID, userID, role,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// RemoveMember исключает участника; false — его и не было в группе. Если
+// ушёл последний админ, права переходят к тому, кто вошёл в группу раньше
+// остальных: его ID возвращается вторым (0 — права не передавались).
+func (r *GroupRepository) RemoveMember(groupID, userID int) (bool, int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	// блокировка группы упорядочивает выходы и входы: двое последних админов,
+	// уходя одновременно, не оставят группу без админа
+	if _, err = tx.Exec(`SELECT id FROM group_chats WHERE id=$1 FOR UPDATE`, groupID); err != nil {
+		return false, 0, err
+	}
+	var role string
+	err = tx.QueryRow(
+		`DELETE FROM group_members WHERE group_id=$1 AND user_id=$2 RETURNING role`,
+		groupID, userID,
+	).Scan(&role)
+	if err == sql.ErrNoRows {
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	var newAdminID int
+	if role == "admin" {
+		err = tx.QueryRow(`
+			UPDATE group_members SET role='admin'
+			WHERE group_id=$1 AND user_id = (
+				SELECT user_id FROM group_members WHERE group_id=$1
+				ORDER BY joined_at, user_id LIMIT 1)
+			AND NOT EXISTS (SELECT 1 FROM group_members WHERE group_id=$1 AND role='admin')
+			RETURNING user_id`,
+			groupID,
+		).Scan(&newAdminID)
+		if err != nil && err != sql.ErrNoRows {
+			return false, 0, err
+		}
+	}
+	return true, newAdminID, tx.Commit()
+}
+
+func (r *GroupRepository) MemberIDs(groupID, excludeUserID int) ([]int, error) {
+	return queryIDs(r.DB,
+		`SELECT user_id FROM group_members WHERE group_id = $1 AND user_id != $2`,
+		groupID, excludeUserID,
+	)
+}
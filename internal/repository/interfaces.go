@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"time"
+	"your_project/internal/models"
+)
+
+// Интерфейсы хранилищ. Реализация над PostgreSQL — структуры *Repository
+// этого пакета, в памяти — пакет repository/memory. Ошибки «не найдено»
+// обе реализации возвращают одинаково: sql.ErrNoRows там, где её отдаёт
+// одиночный SELECT, и ErrXxx этого пакета в остальных случаях.
+
+type UserStore interface {
+	CreateUser(user models.User) (int, error)
+	GetUserByUsername(username string) (*models.User, error)
+	GetUserByID(id int) (*models.User, error)
+	GetUserByVerifiedEmail(email string) (*models.User, error)
+	// GetProfile — все поля профиля, включая email и время запроса удаления
+	GetProfile(id int) (*models.User, error)
+	UpdateProfile(userID int, displayName, bio, avatarURL string) error
+	SetEmail(userID int, email string) error
+	MarkEmailVerified(userID int, email string) (bool, error)
+	SetPassword(userID int, hash string) error
+	SetUsername(userID int, username string) error
+	SearchByTag(tag string, currentUserID int) ([]models.User, error)
+	SetFCMToken(userID int, token string) error
+	FCMToken(userID int) (string, error)
+	TouchLastSeen(userID int) (time.Time, error)
+	RequestDeletion(userID int) (time.Time, error)
+	CancelDeletion(userID int) (bool, error)
+	DueForDeletion(requestedBefore time.Time) ([]int, error)
+	SoftDelete(userID int) error
+}
+
+type SessionStore interface {
+	Create(s models.Session, refreshHash string) error
+	Rotate(oldHash, newHash string, expiresAt time.Time) (models.Session, string, error)
+	IsActive(sessionID string, userID int) (bool, error)
+	ListActive(userID int) ([]models.Session, error)
+	Revoke(userID int, sessionID string) (bool, error)
+	RevokeAll(userID int, exceptID string) ([]string, error)
+}
+
+type TwoFactorStore interface {
+	Get(userID int) (TwoFactorState, error)
+	SetPendingSecret(userID int, secret string) error
+	UseStep(userID int, step int64) (bool, error)
+	Enable(userID int, codeHashes []string) error
+	Disable(userID int) error
+	ReplaceRecoveryCodes(userID int, codeHashes []string) error
+	UseRecoveryCode(userID int, codeHash string) (bool, error)
+	RemainingRecoveryCodes(userID int) (int, error)
+}
+
+type LoginAttemptStore interface {
+	Record(a models.LoginAttempt) error
+	ListFailed(userID, limit int) ([]models.LoginAttempt, error)
+	// Prune удаляет записи старше before и возвращает их число
+	Prune(before time.Time) (int64, error)
+}
+
+type EmailTokenStore interface {
+	Create(userID int, purpose, email, tokenHash string, expiresAt time.Time) error
+	Consume(tokenHash, purpose string) (userID int, email string, err error)
+	InvalidateAll(userID int, purpose string) error
+}
+
+type ConversationStore interface {
+	GetOrCreate(userID1, userID2 int) (int, error)
+	ListForUser(userID int) ([]models.Conversation, error)
+	IsMember(conversationID, userID int) (bool, error)
+	// HasBlockedMember — есть ли блокировка между userID и другим участником
+	HasBlockedMember(conversationID, userID int) (bool, error)
+	MemberIDs(conversationID, excludeUserID int) ([]int, error)
+	// ContactIDs — собеседники по личным диалогам, кроме тех, с кем есть блокировка
+	ContactIDs(userID int) ([]int, error)
+	Leave(conversationID, userID int) error
+}
+
+type MessageStore interface {
+	SaveMessage(msg models.Message) (models.Message, error)
+	SaveGroupMessage(msg models.GroupMessage) (models.GroupMessage, error)
+	GetMessages(conversationID int) ([]models.Message, error)
+	GetMessagesPage(conversationID int, p Page) (models.MessagePage, error)
+	GetGroupMessages(groupID int) ([]models.GroupMessage, error)
+	GetGroupMessagesPage(groupID int, p Page) (models.GroupMessagePage, error)
+	EditMessage(chatType string, messageID, userID int, content string) (EditedMessage, error)
+	// DeleteMessage — ID чата и, для ответа в ветке, новые счётчики её корня
+	DeleteMessage(chatType string, messageID, userID int) (int, *ThreadCounters, error)
+	GetMessageChat(chatType string, messageID int) (int, error)
+	// GetEditHistory — прежние версии сообщения; у удалённого истории нет,
+	// вместо неё ErrMessageDeleted
+	GetEditHistory(chatType string, messageID int) ([]models.MessageEdit, error)
+	// ReplyPreview — цитата сообщения messageID из чата chatID или
+	// ErrMessageNotFound, если в этом чате такого сообщения нет
+	ReplyPreview(chatType string, chatID, messageID int) (*models.ReplyPreview, error)
+	// SaveThreadReply сохраняет ответ в ветку и возвращает новое число её
+	// ответов; ReplyToID вне этой ветки — ErrMessageNotFound
+	SaveThreadReply(msg models.GroupMessage) (models.GroupMessage, int, error)
+	GetThreadPage(groupID, rootID int, p Page) (models.ThreadPage, error)
+	ThreadParticipantIDs(rootID, excludeUserID int) ([]int, error)
+	// ForwardSources — пересылаемые сообщения чата chatID по возрастанию ID;
+	// ErrMessageNotFound, если какого-то из них в этом чате нет
+	ForwardSources(chatType string, chatID int, messageIDs []int) ([]ForwardSource, error)
+	// SaveForwarded сохраняет копии sources в чат chatID разом: при ошибке
+	// не сохраняется ни одна
+	SaveForwarded(chatType string, chatID, senderID int, sources []ForwardSource) ([]ForwardedCopy, error)
+}
+
+type GroupStore interface {
+	// Create создаёт группу с создателем-админом и возвращает ID группы и
+	// тех, кто в неё вошёл (создатель и добавленные участники)
+	Create(name, avatarURL string, createdBy int, memberIDs []int) (int, []int, error)
+	ListForUser(userID int) ([]models.GroupSummary, error)
+	Get(groupID int) (models.GroupInfo, error)
+	// Members — участники группы: сначала админы, затем по имени
+	Members(groupID int) ([]models.GroupMember, error)
+	// Role возвращает роль участника или ErrNotChatMember
+	Role(groupID, userID int) (string, error)
+	Update(groupID int, name, avatarURL string) error
+	AddMember(groupID, userID int, role string) (bool, error)
+	// RemoveMember исключает участника (false — его не было в группе) и, если
+	// ушёл последний админ, возвращает ID участника, ставшего админом
+	RemoveMember(groupID, userID int) (bool, int, error)
+	MemberIDs(groupID, excludeUserID int) ([]int, error)
+}
+
+type ReceiptStore interface {
+	Mark(chatType string, chatID, userID, upToID int, read bool) (int, bool, error)
+	GetGroupReceipt(groupID, messageID int) (models.GroupReceipt, error)
+}
+
+type EventStore interface {
+	// Append записывает одно событие в ленты всех userIDs одним запросом и
+	// возвращает выданный каждому seq; несуществующих пользователей нет в ответе
+	Append(userIDs []int, eventType string, payload []byte) (map[int]int64, error)
+	Since(userID int, afterSeq int64, limit int) ([]models.Event, error)
+	Bounds(userID int) (current, oldest int64, err error)
+	Prune(before time.Time) (int64, error)
+}
+
+type BlockStore interface {
+	IsBlockedBetween(userA, userB int) (bool, error)
+	BlockersOf(userID int) (map[int]bool, error)
+	Block(userID, blockedUserID int) error
+	Unblock(userID, blockedUserID int) error
+	ListBlocked(userID int) ([]models.User, error)
+}
+
+type ReactionStore interface {
+	// Set ставит реакцию userID на сообщение, заменяя прежнюю, и возвращает
+	// прежний эмодзи ("" — реакции не было). ErrMessageNotFound или
+	// ErrMessageDeleted, если реагировать не на что.
+	Set(chatType string, messageID, userID int, emoji string) (string, error)
+	// Remove снимает реакцию userID и возвращает её эмодзи ("" — не было)
+	Remove(chatType string, messageID, userID int) (string, error)
+	// Counts — реакции на сообщения messageIDs по эмодзи, в порядке первой
+	// реакции каждым эмодзи; ReactedByMe — среди них есть реакция viewerID
+	Counts(chatType string, messageIDs []int, viewerID int) (map[int][]models.ReactionCount, error)
+	// List — кто какую реакцию поставил, от ранних к поздним
+	List(chatType string, messageID int) ([]models.Reaction, error)
+}
+
+type PinStore interface {
+	// Pin закрепляет сообщение чата chatID от имени userID; повторное
+	// закрепление поднимает его наверх. ErrMessageNotFound, если в этом чате
+	// такого сообщения нет, ErrMessageDeleted — если оно удалено.
+	Pin(chatType string, chatID, messageID, userID int) (models.PinnedMessage, error)
+	// Unpin открепляет сообщение; false — оно не было закреплено
+	Unpin(chatType string, messageID int) (bool, error)
+	// List — закреплённые сообщения чата, от последнего закреплённого к
+	// первому; удалённые сообщения не показываются
+	List(chatType string, chatID int) ([]models.PinnedMessage, error)
+	// Latest — последнее закреплённое сообщение каждого из чатов chatIDs
+	Latest(chatType string, chatIDs []int) (map[int]models.PinnedMessage, error)
+}
+
+// Проверка, что реализации над PostgreSQL удовлетворяют интерфейсам
+var (
+	_ UserStore         = (*UserRepository)(nil)
+	_ SessionStore      = (*SessionRepository)(nil)
+	_ TwoFactorStore    = (*TwoFactorRepository)(nil)
+	_ LoginAttemptStore = (*LoginAttemptRepository)(nil)
+	_ EmailTokenStore   = (*EmailTokenRepository)(nil)
+	_ ConversationStore = (*ConversationRepository)(nil)
+	_ MessageStore      = (*MessageRepository)(nil)
+	_ GroupStore        = (*GroupRepository)(nil)
+	_ ReceiptStore      = (*ReceiptRepository)(nil)
+	_ EventStore        = (*EventRepository)(nil)
+	_ BlockStore        = (*BlockRepository)(nil)
+	_ ReactionStore     = (*ReactionRepository)(nil)
+	_ PinStore          = (*PinRepository)(nil)
+)
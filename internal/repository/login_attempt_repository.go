@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"your_project/internal/models"
+)
+
+type LoginAttemptRepository struct {
+	DB *sql.DB
+}
+
+func (r *LoginAttemptRepository) Record(a models.LoginAttempt) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO login_attempts (user_id, username, ip, user_agent, reason)
+		VALUES ($1, $2, $3, $4, $5)`,
+		a.UserID, a.Username, a.IP, a.UserAgent, a.Reason,
+	)
+	return err
+}
+
+// ListFailed — последние неудачные попытки входа в аккаунт пользователя
+func (r *LoginAttemptRepository) ListFailed(userID, limit int) ([]models.LoginAttempt, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, username, ip, user_agent, reason, created_at
+		FROM login_attempts
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var attempts []models.LoginAttempt
+	for rows.Next() {
+		var a models.LoginAttempt
+		rows.Scan(&a.ID, &a.Username, &a.IP, &a.UserAgent, &a.Reason, &a.CreatedAt)
+		attempts = append(attempts, a)
+	}
+	return attempts, nil
+}
+
+// Prune удаляет записи журнала старше before
+func (r *LoginAttemptRepository) Prune(before time.Time) (int64, error) {
+	res, err := r.DB.Exec(`DELETE FROM login_attempts WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
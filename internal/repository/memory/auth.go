@@ -0,0 +1,275 @@
+package memory
+
+import (
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+
+	"your_project/internal/models"
+	"your_project/internal/repository"
+)
+
+type sessionStore struct{ *Store }
+
+func (s *session) active(now time.Time) bool {
+	return s.revokedAt == nil && s.ExpiresAt.After(now)
+}
+
+func (r *sessionStore) Create(s models.Session, refreshHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[s.UserID]; !ok {
+		return errNoReference
+	}
+	for id, other := range r.sessions {
+		if id == s.ID || other.refreshHash == refreshHash {
+			return errors.New("сессия уже существует")
+		}
+	}
+	now := time.Now()
+	s.CreatedAt, s.LastUsedAt, s.Current = now, now, false
+	r.sessions[s.ID] = &session{Session: s, refreshHash: refreshHash}
+	return nil
+}
+
+func (r *sessionStore) Rotate(oldHash, newHash string, expiresAt time.Time) (models.Session, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, s := range r.sessions {
+		u, ok := r.users[s.UserID]
+		if s.refreshHash != oldHash || !s.active(now) || !ok {
+			continue
+		}
+		s.previousHash, s.refreshHash = oldHash, newHash
+		s.LastUsedAt, s.ExpiresAt = now, expiresAt
+		return models.Session{ID: s.ID, UserID: s.UserID}, u.Username, nil
+	}
+	// предъявлен уже заменённый токен — отзываем сессию
+	for _, s := range r.sessions {
+		if s.previousHash == oldHash && s.revokedAt == nil {
+			s.revokedAt = timePtr(now)
+			return models.Session{ID: s.ID, UserID: s.UserID}, "", repository.ErrRefreshReused
+		}
+	}
+	return models.Session{}, "", repository.ErrSessionNotFound
+}
+
+func (r *sessionStore) IsActive(sessionID string, userID int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[sessionID]
+	return ok && s.UserID == userID && s.active(time.Now()), nil
+}
+
+func (r *sessionStore) ListActive(userID int) ([]models.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var sessions []models.Session
+	for _, s := range r.sessions {
+		if s.UserID == userID && s.active(now) {
+			sessions = append(sessions, s.Session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, nil
+}
+
+func (r *sessionStore) Revoke(userID int, sessionID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.sessions[sessionID]
+	if !ok || s.UserID != userID || s.revokedAt != nil {
+		return false, nil
+	}
+	s.revokedAt = timePtr(time.Now())
+	return true, nil
+}
+
+func (r *sessionStore) RevokeAll(userID int, exceptID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var ids []string
+	for id, s := range r.sessions {
+		if s.UserID == userID && id != exceptID && s.revokedAt == nil {
+			s.revokedAt = timePtr(now)
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+type twoFactorStore struct{ *Store }
+
+func (r *twoFactorStore) Get(userID int) (repository.TwoFactorState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[userID]
+	if !ok {
+		return repository.TwoFactorState{}, sql.ErrNoRows
+	}
+	return u.totp, nil
+}
+
+func (r *twoFactorStore) SetPendingSecret(userID int, secret string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[userID]; ok && !u.totp.Enabled {
+		u.totp.Secret, u.totp.LastStep = secret, 0
+	}
+	return nil
+}
+
+func (r *twoFactorStore) UseStep(userID int, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[userID]
+	if !ok || u.totp.LastStep >= step {
+		return false, nil
+	}
+	u.totp.LastStep = step
+	return true, nil
+}
+
+func (r *twoFactorStore) Enable(userID int, codeHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[userID]; ok {
+		u.totp.Enabled = true
+	}
+	return r.replaceRecoveryCodes(userID, codeHashes)
+}
+
+func (r *twoFactorStore) Disable(userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[userID]; ok {
+		u.totp = repository.TwoFactorState{}
+	}
+	delete(r.recovery, userID)
+	return nil
+}
+
+func (r *twoFactorStore) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.replaceRecoveryCodes(userID, codeHashes)
+}
+
+func (r *twoFactorStore) replaceRecoveryCodes(userID int, codeHashes []string) error {
+	if _, ok := r.users[userID]; !ok {
+		return errNoReference
+	}
+	codes := make([]*recoveryCode, 0, len(codeHashes))
+	for _, h := range codeHashes {
+		codes = append(codes, &recoveryCode{hash: h})
+	}
+	r.recovery[userID] = codes
+	return nil
+}
+
+func (r *twoFactorStore) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.recovery[userID] {
+		if c.hash == codeHash && !c.used {
+			c.used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *twoFactorStore) RemainingRecoveryCodes(userID int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, c := range r.recovery[userID] {
+		if !c.used {
+			n++
+		}
+	}
+	return n, nil
+}
+
+type loginAttemptStore struct{ *Store }
+
+func (r *loginAttemptStore) Record(a models.LoginAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if a.UserID == nil || r.users[*a.UserID] == nil {
+		return errNoReference
+	}
+	r.nextAttemptID++
+	a.ID = r.nextAttemptID
+	a.CreatedAt = time.Now()
+	r.attempts = append(r.attempts, a)
+	return nil
+}
+
+func (r *loginAttemptStore) ListFailed(userID, limit int) ([]models.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var attempts []models.LoginAttempt
+	for i := len(r.attempts) - 1; i >= 0 && len(attempts) < limit; i-- {
+		if a := r.attempts[i]; a.UserID != nil && *a.UserID == userID {
+			attempts = append(attempts, a)
+		}
+	}
+	return attempts, nil
+}
+
+func (r *loginAttemptStore) Prune(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.attempts[:0]
+	for _, a := range r.attempts {
+		if !a.CreatedAt.Before(before) {
+			kept = append(kept, a)
+		}
+	}
+	n := int64(len(r.attempts) - len(kept))
+	r.attempts = kept
+	return n, nil
+}
+
+type emailTokenStore struct{ *Store }
+
+func (r *emailTokenStore) Create(userID int, purpose, email, tokenHash string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.users[userID]; !ok {
+		return errNoReference
+	}
+	if _, ok := r.emailTokens[tokenHash]; ok {
+		return errors.New("токен уже существует")
+	}
+	r.emailTokens[tokenHash] = &emailToken{userID: userID, purpose: purpose, email: email, expiresAt: expiresAt}
+	return nil
+}
+
+func (r *emailTokenStore) Consume(tokenHash, purpose string) (int, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.emailTokens[tokenHash]
+	if !ok || t.purpose != purpose || t.used || !t.expiresAt.After(time.Now()) {
+		return 0, "", repository.ErrTokenInvalid
+	}
+	t.used = true
+	return t.userID, t.email, nil
+}
+
+func (r *emailTokenStore) InvalidateAll(userID int, purpose string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.emailTokens {
+		if t.userID == userID && t.purpose == purpose {
+			t.used = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,402 @@
+package memory
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"your_project/internal/models"
+	"your_project/internal/repository"
+)
+
+type conversationStore struct{ *Store }
+
+func (r *conversationStore) GetOrCreate(userID1, userID2 int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]int, 0, len(r.conversations))
+	for id := range r.conversations {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		c := r.conversations[id]
+		if c.members[userID1] != nil && c.members[userID2] != nil {
+			return id, nil
+		}
+	}
+	if r.users[userID1] == nil || r.users[userID2] == nil {
+		return 0, errNoReference
+	}
+	r.nextChatID[models.ChatDirect]++
+	c := &conversation{
+		id:        r.nextChatID[models.ChatDirect],
+		createdAt: time.Now(),
+		members:   map[int]*marks{userID1: {}, userID2: {}},
+	}
+	r.conversations[c.id] = c
+	return c.id, nil
+}
+
+func (r *conversationStore) ListForUser(userID int) ([]models.Conversation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var convs []models.Conversation
+	for _, c := range r.conversations {
+		own := c.members[userID]
+		if own == nil {
+			continue
+		}
+		for otherID, other := range c.members {
+			u := r.users[otherID]
+			if otherID == userID || u == nil {
+				continue
+			}
+			conv := models.Conversation{
+				ID:                   c.id,
+				OtherUserID:          otherID,
+				OtherUsername:        u.Username,
+				CreatedAt:            c.createdAt,
+				UnreadCount:          r.unreadCount(models.ChatDirect, c.id, userID, own.read),
+				OtherLastDeliveredID: other.delivered,
+				OtherLastReadID:      other.read,
+				OtherLastSeenAt:      u.LastSeenAt,
+			}
+			if last := r.lastMessage(models.ChatDirect, c.id); last != nil {
+				conv.LastMessage = last.content
+			}
+			convs = append(convs, conv)
+		}
+	}
+	sort.Slice(convs, func(i, j int) bool {
+		if !convs[i].CreatedAt.Equal(convs[j].CreatedAt) {
+			return convs[i].CreatedAt.After(convs[j].CreatedAt)
+		}
+		return convs[i].ID > convs[j].ID
+	})
+	return convs, nil
+}
+
+func (r *conversationStore) IsMember(conversationID, userID int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.chatMarks(models.ChatDirect, conversationID, userID) != nil, nil
+}
+
+func (r *conversationStore) HasBlockedMember(conversationID, userID int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.conversations[conversationID]
+	if !ok {
+		return false, nil
+	}
+	for memberID := range c.members {
+		if memberID != userID && r.isBlockedBetween(userID, memberID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *conversationStore) MemberIDs(conversationID, excludeUserID int) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make(map[int]bool)
+	if c, ok := r.conversations[conversationID]; ok {
+		for memberID := range c.members {
+			if memberID != excludeUserID {
+				ids[memberID] = true
+			}
+		}
+	}
+	return sortedIDs(ids), nil
+}
+
+func (r *conversationStore) ContactIDs(userID int) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make(map[int]bool)
+	for _, c := range r.conversations {
+		if c.members[userID] == nil {
+			continue
+		}
+		for memberID := range c.members {
+			if memberID != userID && !r.isBlockedBetween(userID, memberID) {
+				ids[memberID] = true
+			}
+		}
+	}
+	return sortedIDs(ids), nil
+}
+
+func (r *conversationStore) Leave(conversationID, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.conversations[conversationID]
+	if !ok || c.members[userID] == nil {
+		return nil
+	}
+	var kept []*message
+	for _, m := range r.messages[models.ChatDirect] {
+		if m.chatID != conversationID {
+			kept = append(kept, m)
+		}
+	}
+	r.messages[models.ChatDirect] = kept
+	delete(c.members, userID)
+	return nil
+}
+
+type groupStore struct{ *Store }
+
+func (r *groupStore) Create(name, avatarURL string, createdBy int, memberIDs []int) (int, []int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.users[createdBy] == nil {
+		return 0, nil, errNoReference
+	}
+	r.nextChatID[models.ChatGroup]++
+	now := time.Now()
+	g := &group{
+		id:        r.nextChatID[models.ChatGroup],
+		name:      name,
+		avatarURL: avatarURL,
+		createdBy: createdBy,
+		createdAt: now,
+		members:   map[int]*groupMember{createdBy: {role: "admin", joinedAt: now}},
+	}
+	joined := []int{createdBy}
+	for _, memberID := range memberIDs {
+		if g.members[memberID] != nil || r.users[memberID] == nil {
+			continue
+		}
+		g.members[memberID] = &groupMember{role: "member", joinedAt: now}
+		joined = append(joined, memberID)
+	}
+	r.groups[g.id] = g
+	return g.id, joined, nil
+}
+
+func (r *groupStore) ListForUser(userID int) ([]models.GroupSummary, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var owned []*group
+	for _, g := range r.groups {
+		if g.members[userID] != nil {
+			owned = append(owned, g)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		if !owned[i].createdAt.Equal(owned[j].createdAt) {
+			return owned[i].createdAt.After(owned[j].createdAt)
+		}
+		return owned[i].id > owned[j].id
+	})
+	var groups []models.GroupSummary
+	for _, g := range owned {
+		summary := models.GroupSummary{
+			ID:          g.id,
+			Name:        g.name,
+			AvatarURL:   g.avatarURL,
+			CreatedBy:   g.createdBy,
+			UnreadCount: r.unreadCount(models.ChatGroup, g.id, userID, g.members[userID].read),
+			MemberCount: len(g.members),
+		}
+		if last := r.lastMessage(models.ChatGroup, g.id); last != nil {
+			summary.LastMessage = last.content
+		}
+		groups = append(groups, summary)
+	}
+	return groups, nil
+}
+
+func (r *groupStore) Get(groupID int) (models.GroupInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.groups[groupID]
+	if !ok {
+		return models.GroupInfo{}, sql.ErrNoRows
+	}
+	return models.GroupInfo{
+		ID:        g.id,
+		Name:      g.name,
+		AvatarURL: g.avatarURL,
+		CreatedBy: g.createdBy,
+		Members:   r.members(g),
+	}, nil
+}
+
+func (r *groupStore) Members(groupID int) ([]models.GroupMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.groups[groupID]
+	if !ok {
+		return nil, nil
+	}
+	return r.members(g), nil
+}
+
+// members — сначала админы, затем по имени
+func (r *groupStore) members(g *group) []models.GroupMember {
+	var members []models.GroupMember
+	for userID, m := range g.members {
+		u := r.users[userID]
+		if u == nil {
+			continue
+		}
+		displayName := u.DisplayName
+		if displayName == "" {
+			displayName = u.Username
+		}
+		members = append(members, models.GroupMember{
+			ID: userID, Username: u.Username, DisplayName: displayName,
+			AvatarURL: u.AvatarURL, Role: m.role,
+		})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		ai, aj := members[i].Role == "admin", members[j].Role == "admin"
+		if ai != aj {
+			return ai
+		}
+		return members[i].Username < members[j].Username
+	})
+	return members
+}
+
+func (r *groupStore) Role(groupID, userID int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if g, ok := r.groups[groupID]; ok {
+		if m, ok := g.members[userID]; ok {
+			return m.role, nil
+		}
+	}
+	return "", repository.ErrNotChatMember
+}
+
+func (r *groupStore) Update(groupID int, name, avatarURL string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if g, ok := r.groups[groupID]; ok {
+		g.name, g.avatarURL = name, avatarURL
+	}
+	return nil
+}
+
+func (r *groupStore) AddMember(groupID, userID int, role string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.groups[groupID]
+	if !ok || r.users[userID] == nil {
+		return false, errNoReference
+	}
+	if g.members[userID] != nil {
+		return false, nil
+	}
+	g.members[userID] = &groupMember{role: role, joinedAt: time.Now()}
+	return true, nil
+}
+
+func (r *groupStore) RemoveMember(groupID, userID int) (bool, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.groups[groupID]
+	if !ok || g.members[userID] == nil {
+		return false, 0, nil
+	}
+	role := g.members[userID].role
+	delete(g.members, userID)
+	if role != "admin" {
+		return true, 0, nil
+	}
+	// права переходят к вошедшему раньше всех, если админов не осталось
+	successor := 0
+	for id, m := range g.members {
+		if m.role == "admin" {
+			return true, 0, nil
+		}
+		if successor == 0 || m.joinedAt.Before(g.members[successor].joinedAt) ||
+			m.joinedAt.Equal(g.members[successor].joinedAt) && id < successor {
+			successor = id
+		}
+	}
+	if successor != 0 {
+		g.members[successor].role = "admin"
+	}
+	return true, successor, nil
+}
+
+func (r *groupStore) MemberIDs(groupID, excludeUserID int) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make(map[int]bool)
+	if g, ok := r.groups[groupID]; ok {
+		for memberID := range g.members {
+			if memberID != excludeUserID {
+				ids[memberID] = true
+			}
+		}
+	}
+	return sortedIDs(ids), nil
+}
+
+type receiptStore struct{ *Store }
+
+func (r *receiptStore) Mark(chatType string, chatID, userID, upToID int, read bool) (int, bool, error) {
+	if err := checkChatType(chatType); err != nil {
+		return 0, false, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.chatMarks(chatType, chatID, userID)
+	if m == nil {
+		return 0, false, repository.ErrNotChatMember
+	}
+	if lastID := r.lastMessageID(chatType, chatID); upToID > lastID {
+		upToID = lastID
+	}
+	if read {
+		if upToID <= m.read {
+			return m.read, false, nil
+		}
+		m.read = upToID
+		if m.read > m.delivered {
+			m.delivered = m.read
+		}
+		return m.read, true, nil
+	}
+	if upToID <= m.delivered {
+		return m.delivered, false, nil
+	}
+	m.delivered = upToID
+	return m.delivered, true, nil
+}
+
+func (r *receiptStore) GetGroupReceipt(groupID, messageID int) (models.GroupReceipt, error) {
+	receipt := models.GroupReceipt{MessageID: messageID, ReadBy: []models.ReceiptUser{}}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msg := r.findMessage(models.ChatGroup, messageID)
+	g, ok := r.groups[groupID]
+	if msg == nil || msg.chatID != groupID || !ok {
+		return receipt, repository.ErrMessageNotFound
+	}
+	ids := make(map[int]bool)
+	for userID := range g.members {
+		if userID != msg.senderID && r.users[userID] != nil {
+			ids[userID] = true
+		}
+	}
+	for _, userID := range sortedIDs(ids) {
+		m := g.members[userID]
+		receipt.MemberCount++
+		if m.delivered >= messageID {
+			receipt.DeliveredCount++
+		}
+		if m.read >= messageID {
+			receipt.ReadCount++
+			receipt.ReadBy = append(receipt.ReadBy, models.ReceiptUser{ID: userID, Username: r.users[userID].Username})
+		}
+	}
+	return receipt, nil
+}
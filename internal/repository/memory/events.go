@@ -0,0 +1,132 @@
+package memory
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"your_project/internal/models"
+)
+
+type eventStore struct{ *Store }
+
+func (r *eventStore) Append(userIDs []int, eventType string, payload []byte) (map[int]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seqs := make(map[int]int64, len(userIDs))
+	for _, userID := range userIDs {
+		u, ok := r.users[userID]
+		if !ok {
+			continue
+		}
+		u.eventSeq++
+		r.events[userID] = append(r.events[userID], &event{
+			seq:       u.eventSeq,
+			eventType: eventType,
+			payload:   append([]byte(nil), payload...),
+			createdAt: time.Now(),
+		})
+		seqs[userID] = u.eventSeq
+	}
+	return seqs, nil
+}
+
+func (r *eventStore) Since(userID int, afterSeq int64, limit int) ([]models.Event, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []models.Event
+	for _, e := range r.events[userID] {
+		if len(events) == limit {
+			break
+		}
+		if e.seq > afterSeq {
+			events = append(events, models.Event{
+				Seq: e.seq, Type: e.eventType, Payload: append([]byte(nil), e.payload...),
+			})
+		}
+	}
+	return events, nil
+}
+
+func (r *eventStore) Bounds(userID int) (current, oldest int64, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[userID]
+	if !ok {
+		return 0, 0, sql.ErrNoRows
+	}
+	if events := r.events[userID]; len(events) > 0 {
+		oldest = events[0].seq
+	}
+	return u.eventSeq, oldest, nil
+}
+
+func (r *eventStore) Prune(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for userID, events := range r.events {
+		var kept []*event
+		for _, e := range events {
+			if e.createdAt.Before(before) {
+				n++
+				continue
+			}
+			kept = append(kept, e)
+		}
+		r.events[userID] = kept
+	}
+	return n, nil
+}
+
+type blockStore struct{ *Store }
+
+func (r *blockStore) IsBlockedBetween(userA, userB int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.isBlockedBetween(userA, userB), nil
+}
+
+func (r *blockStore) BlockersOf(userID int) (map[int]bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	blockers := make(map[int]bool)
+	for pair := range r.blocks {
+		if pair[1] == userID {
+			blockers[pair[0]] = true
+		}
+	}
+	return blockers, nil
+}
+
+func (r *blockStore) Block(userID, blockedUserID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.users[userID] == nil || r.users[blockedUserID] == nil {
+		return errNoReference
+	}
+	r.blocks[[2]int{userID, blockedUserID}] = true
+	return nil
+}
+
+func (r *blockStore) Unblock(userID, blockedUserID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.blocks, [2]int{userID, blockedUserID})
+	return nil
+}
+
+func (r *blockStore) ListBlocked(userID int) ([]models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []models.User
+	for pair := range r.blocks {
+		if u := r.users[pair[1]]; pair[0] == userID && u != nil {
+			users = append(users, models.User{
+				ID: u.ID, Username: u.Username, DisplayName: u.DisplayName, AvatarURL: u.AvatarURL,
+			})
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
@@ -0,0 +1,503 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"your_project/internal/models"
+	"your_project/internal/repository"
+)
+
+type messageStore struct{ *Store }
+
+func checkChatType(chatType string) error {
+	if chatType != models.ChatDirect && chatType != models.ChatGroup {
+		return fmt.Errorf("неизвестный тип чата %q", chatType)
+	}
+	return nil
+}
+
+// insert сохраняет сообщение и выдаёт ему ID и время
+func (r *messageStore) insert(chatType string, m *message) {
+	r.nextMessageID[chatType]++
+	m.id = r.nextMessageID[chatType]
+	m.createdAt = time.Now()
+	r.messages[chatType] = append(r.messages[chatType], m)
+}
+
+func (r *messageStore) SaveMessage(msg models.Message) (models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conversations[msg.ConversationID] == nil || r.users[msg.SenderID] == nil {
+		return msg, errNoReference
+	}
+	if msg.ReplyToID != 0 && r.findMessage(models.ChatDirect, msg.ReplyToID) == nil {
+		return msg, errNoReference
+	}
+	forwardedFrom, err := r.forwardMark(msg.ForwardedFrom)
+	if err != nil {
+		return msg, err
+	}
+	m := &message{
+		chatID: msg.ConversationID, senderID: msg.SenderID,
+		content: msg.Content, mediaURL: msg.MediaURL, mediaType: msg.MediaType,
+		replyToID: msg.ReplyToID, forwardedFrom: forwardedFrom,
+	}
+	r.insert(models.ChatDirect, m)
+	msg.ID, msg.CreatedAt = m.id, m.createdAt
+	return msg, nil
+}
+
+func (r *messageStore) SaveGroupMessage(msg models.GroupMessage) (models.GroupMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.groups[msg.GroupID] == nil || r.users[msg.SenderID] == nil {
+		return msg, errNoReference
+	}
+	if msg.ReplyToID != 0 && r.findMessage(models.ChatGroup, msg.ReplyToID) == nil {
+		return msg, errNoReference
+	}
+	forwardedFrom, err := r.forwardMark(msg.ForwardedFrom)
+	if err != nil {
+		return msg, err
+	}
+	m := &message{
+		chatID: msg.GroupID, senderID: msg.SenderID,
+		content: msg.Content, mediaURL: msg.MediaURL, mediaType: msg.MediaType,
+		replyToID: msg.ReplyToID, forwardedFrom: forwardedFrom,
+	}
+	r.insert(models.ChatGroup, m)
+	msg.ID, msg.CreatedAt = m.id, m.createdAt
+	return msg, nil
+}
+
+func (r *messageStore) direct(m *message) models.Message {
+	msg := models.Message{
+		ID: m.id, ConversationID: m.chatID, SenderID: m.senderID,
+		SenderUsername: r.users[m.senderID].Username,
+		Content:        m.content, MediaURL: m.mediaURL, MediaType: m.mediaType,
+		CreatedAt: m.createdAt, EditedAt: m.editedAt, Deleted: m.deleted,
+	}
+	msg.ReplyToID, msg.ReplyTo = r.quote(models.ChatDirect, m.replyToID)
+	msg.ForwardedFrom = r.forwardInfo(m)
+	return msg
+}
+
+func (r *messageStore) group(m *message) models.GroupMessage {
+	msg := models.GroupMessage{
+		ID: m.id, GroupID: m.chatID, SenderID: m.senderID,
+		SenderUsername: r.users[m.senderID].Username,
+		Content:        m.content, MediaURL: m.mediaURL, MediaType: m.mediaType,
+		CreatedAt: m.createdAt, EditedAt: m.editedAt, Deleted: m.deleted,
+		ThreadRootID: m.threadRootID, ThreadReplyCount: m.threadReplyCount, ThreadLastReplyAt: m.threadLastReplyAt,
+	}
+	msg.ReplyToID, msg.ReplyTo = r.quote(models.ChatGroup, m.replyToID)
+	msg.ForwardedFrom = r.forwardInfo(m)
+	return msg
+}
+
+// quote — цитата для ответа на replyToID; оригинал, удалённый вместе с
+// диалогом, обнуляет ссылку, как ON DELETE SET NULL
+func (r *messageStore) quote(chatType string, replyToID int) (int, *models.ReplyPreview) {
+	if replyToID == 0 {
+		return 0, nil
+	}
+	m := r.findMessage(chatType, replyToID)
+	if m == nil {
+		return 0, nil
+	}
+	return m.id, r.preview(m)
+}
+
+func (r *messageStore) preview(m *message) *models.ReplyPreview {
+	return repository.NewReplyPreview(m.id, m.senderID, r.users[m.senderID].Username, m.content, m.mediaType, m.deleted)
+}
+
+func (r *messageStore) ReplyPreview(chatType string, chatID, messageID int) (*models.ReplyPreview, error) {
+	if err := checkChatType(chatType); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.findMessage(chatType, messageID)
+	if m == nil || m.chatID != chatID {
+		return nil, repository.ErrMessageNotFound
+	}
+	return r.preview(m), nil
+}
+
+// forwardMark — копия атрибуции для хранения; автор, как внешний ключ,
+// должен существовать
+func (r *messageStore) forwardMark(f *models.ForwardInfo) (*models.ForwardInfo, error) {
+	if f == nil {
+		return nil, nil
+	}
+	if f.SenderID != 0 && r.users[f.SenderID] == nil {
+		return nil, errNoReference
+	}
+	mark := *f
+	mark.SenderUsername = ""
+	return &mark, nil
+}
+
+// forwardInfo — атрибуция пересланного с текущим именем автора; удалённый
+// аккаунт обнуляет автора, как ON DELETE SET NULL
+func (r *messageStore) forwardInfo(m *message) *models.ForwardInfo {
+	if m.forwardedFrom == nil {
+		return nil
+	}
+	info := *m.forwardedFrom
+	if u := r.users[info.SenderID]; u != nil {
+		info.SenderUsername = u.Username
+	} else {
+		info.SenderID = 0
+	}
+	return &info
+}
+
+func (r *messageStore) ForwardSources(chatType string, chatID int, messageIDs []int) ([]repository.ForwardSource, error) {
+	if err := checkChatType(chatType); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wanted := make(map[int]bool, len(messageIDs))
+	for _, id := range messageIDs {
+		wanted[id] = true
+	}
+	var sources []repository.ForwardSource
+	for _, m := range r.messages[chatType] {
+		if m.chatID != chatID || !wanted[m.id] {
+			continue
+		}
+		sources = append(sources, repository.ForwardSource{
+			ID: m.id, Content: m.content, MediaURL: m.mediaURL, MediaType: m.mediaType, Deleted: m.deleted,
+			From: repository.NewForwardInfo(chatType, chatID, m.id,
+				m.senderID, r.users[m.senderID].Username, r.forwardInfo(m)),
+		})
+	}
+	if len(sources) != len(wanted) {
+		return nil, repository.ErrMessageNotFound
+	}
+	return sources, nil
+}
+
+func (r *messageStore) SaveForwarded(chatType string, chatID, senderID int, sources []repository.ForwardSource) ([]repository.ForwardedCopy, error) {
+	if err := checkChatType(chatType); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	chatExists := r.conversations[chatID] != nil
+	if chatType == models.ChatGroup {
+		chatExists = r.groups[chatID] != nil
+	}
+	if !chatExists || r.users[senderID] == nil {
+		return nil, errNoReference
+	}
+	// сначала проверяются все копии, чтобы при ошибке не сохранить ни одной
+	marks := make([]*models.ForwardInfo, len(sources))
+	for i, src := range sources {
+		from := src.From
+		mark, err := r.forwardMark(&from)
+		if err != nil {
+			return nil, err
+		}
+		marks[i] = mark
+	}
+	copies := make([]repository.ForwardedCopy, len(sources))
+	for i, src := range sources {
+		m := &message{
+			chatID: chatID, senderID: senderID,
+			content: src.Content, mediaURL: src.MediaURL, mediaType: src.MediaType,
+			forwardedFrom: marks[i],
+		}
+		r.insert(chatType, m)
+		copies[i] = repository.ForwardedCopy{ID: m.id, CreatedAt: m.createdAt}
+	}
+	return copies, nil
+}
+
+func (r *messageStore) SaveThreadReply(msg models.GroupMessage) (models.GroupMessage, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	root := r.findMessage(models.ChatGroup, msg.ThreadRootID)
+	if root == nil || root.chatID != msg.GroupID || root.threadRootID != 0 {
+		return msg, 0, repository.ErrThreadNotFound
+	}
+	if r.users[msg.SenderID] == nil {
+		return msg, 0, errNoReference
+	}
+	if msg.ReplyToID != 0 {
+		switch to := r.findMessage(models.ChatGroup, msg.ReplyToID); {
+		case to == nil:
+			return msg, 0, errNoReference
+		case to.id != root.id && to.threadRootID != root.id:
+			return msg, 0, repository.ErrMessageNotFound
+		}
+	}
+	m := &message{
+		chatID: msg.GroupID, senderID: msg.SenderID,
+		content: msg.Content, mediaURL: msg.MediaURL, mediaType: msg.MediaType,
+		replyToID: msg.ReplyToID, threadRootID: root.id,
+	}
+	r.insert(models.ChatGroup, m)
+	root.threadReplyCount++
+	root.threadLastReplyAt = timePtr(m.createdAt)
+	msg.ID, msg.CreatedAt = m.id, m.createdAt
+	return msg, root.threadReplyCount, nil
+}
+
+func (r *messageStore) GetThreadPage(groupID, rootID int, p repository.Page) (models.ThreadPage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	page := models.ThreadPage{Messages: []models.GroupMessage{}}
+	root := r.findMessage(models.ChatGroup, rootID)
+	if root == nil || root.chatID != groupID || root.threadRootID != 0 {
+		return page, repository.ErrThreadNotFound
+	}
+	page.Root = r.group(root)
+	msgs, next, prev := paginate(r.threadMessages(rootID), p)
+	for _, m := range msgs {
+		page.Messages = append(page.Messages, r.group(m))
+	}
+	page.NextCursor, page.PrevCursor = next, prev
+	return page, nil
+}
+
+// threadMessages — ответы ветки по возрастанию ID
+func (r *messageStore) threadMessages(rootID int) []*message {
+	var msgs []*message
+	for _, m := range r.messages[models.ChatGroup] {
+		if m.threadRootID == rootID {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+func (r *messageStore) ThreadParticipantIDs(rootID, excludeUserID int) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make(map[int]bool)
+	root := r.findMessage(models.ChatGroup, rootID)
+	if root == nil {
+		return nil, nil
+	}
+	g := r.groups[root.chatID]
+	for _, m := range append([]*message{root}, r.threadMessages(rootID)...) {
+		if m.senderID != excludeUserID && g != nil && g.members[m.senderID] != nil {
+			ids[m.senderID] = true
+		}
+	}
+	return sortedIDs(ids), nil
+}
+
+func (r *messageStore) GetMessages(conversationID int) ([]models.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var msgs []models.Message
+	for _, m := range r.chatMessages(models.ChatDirect, conversationID) {
+		msgs = append(msgs, r.direct(m))
+	}
+	return msgs, nil
+}
+
+func (r *messageStore) GetMessagesPage(conversationID int, p repository.Page) (models.MessagePage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	page := models.MessagePage{Messages: []models.Message{}}
+	msgs, next, prev := paginate(r.chatMessages(models.ChatDirect, conversationID), p)
+	for _, m := range msgs {
+		page.Messages = append(page.Messages, r.direct(m))
+	}
+	page.NextCursor, page.PrevCursor = next, prev
+	return page, nil
+}
+
+func (r *messageStore) GetGroupMessages(groupID int) ([]models.GroupMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var msgs []models.GroupMessage
+	for _, m := range r.chatMessages(models.ChatGroup, groupID) {
+		msgs = append(msgs, r.group(m))
+	}
+	return msgs, nil
+}
+
+func (r *messageStore) GetGroupMessagesPage(groupID int, p repository.Page) (models.GroupMessagePage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	page := models.GroupMessagePage{Messages: []models.GroupMessage{}}
+	msgs, next, prev := paginate(r.chatMessages(models.ChatGroup, groupID), p)
+	for _, m := range msgs {
+		page.Messages = append(page.Messages, r.group(m))
+	}
+	page.NextCursor, page.PrevCursor = next, prev
+	return page, nil
+}
+
+// paginate повторяет курсорную пагинацию MessageRepository над сообщениями
+// чата, отсортированными по ID: страница — непрерывный отрезок msgs
+func paginate(msgs []*message, p repository.Page) (page []*message, next, prev int) {
+	limit := p.Limit
+	if limit <= 0 {
+		limit = repository.DefaultPageLimit
+	}
+	if limit > repository.MaxPageLimit {
+		limit = repository.MaxPageLimit
+	}
+	// firstAbove — индекс первого сообщения с ID > id
+	firstAbove := func(id int) int {
+		return sort.Search(len(msgs), func(i int) bool { return msgs[i].id > id })
+	}
+	var from, to int // [from, to)
+	switch {
+	case p.AroundID != 0:
+		older := (limit + 1) / 2
+		mid := firstAbove(p.AroundID)
+		from, to = max(mid-older, 0), min(mid+limit-older, len(msgs))
+	case p.AfterID != 0:
+		from = firstAbove(p.AfterID)
+		to = min(from+limit, len(msgs))
+	case p.BeforeID != 0:
+		to = firstAbove(p.BeforeID - 1)
+		from = max(to-limit, 0)
+	default:
+		to = len(msgs)
+		from = max(to-limit, 0)
+	}
+	if from >= to {
+		return nil, 0, 0
+	}
+	if from > 0 {
+		next = msgs[from].id
+	}
+	if to < len(msgs) {
+		prev = msgs[to-1].id
+	}
+	return msgs[from:to], next, prev
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// ownMessage находит сообщение и проверяет, что его отправил userID и оно не удалено
+func (r *messageStore) ownMessage(chatType string, messageID, userID int) (*message, error) {
+	if err := checkChatType(chatType); err != nil {
+		return nil, err
+	}
+	m := r.findMessage(chatType, messageID)
+	switch {
+	case m == nil:
+		return nil, repository.ErrMessageNotFound
+	case m.senderID != userID:
+		return nil, repository.ErrNotMessageSender
+	case m.deleted:
+		return nil, repository.ErrMessageDeleted
+	}
+	return m, nil
+}
+
+func (r *messageStore) EditMessage(chatType string, messageID, userID int, content string) (repository.EditedMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, err := r.ownMessage(chatType, messageID, userID)
+	if err != nil {
+		return repository.EditedMessage{}, err
+	}
+	now := time.Now()
+	r.nextEditID++
+	r.edits = append(r.edits, &edit{
+		id: r.nextEditID, chatType: chatType, messageID: messageID,
+		oldContent: m.content, editedAt: now,
+	})
+	m.content, m.editedAt = content, timePtr(now)
+	return repository.EditedMessage{ChatID: m.chatID, ThreadRootID: m.threadRootID, EditedAt: now}, nil
+}
+
+func (r *messageStore) DeleteMessage(chatType string, messageID, userID int) (int, *repository.ThreadCounters, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, err := r.ownMessage(chatType, messageID, userID)
+	if err != nil {
+		return 0, nil, err
+	}
+	m.deleted = true
+	m.content, m.mediaURL, m.mediaType = "", "", ""
+	kept := r.edits[:0]
+	for _, e := range r.edits {
+		if e.chatType != chatType || e.messageID != messageID {
+			kept = append(kept, e)
+		}
+	}
+	r.edits = kept
+	if chatType != models.ChatGroup || m.threadRootID == 0 {
+		return m.chatID, nil, nil
+	}
+	return m.chatID, r.recountThread(m.threadRootID), nil
+}
+
+// recountThread пересчитывает счётчики корня по неудалённым ответам ветки
+func (r *messageStore) recountThread(rootID int) *repository.ThreadCounters {
+	root := r.findMessage(models.ChatGroup, rootID)
+	root.threadReplyCount, root.threadLastReplyAt = 0, nil
+	for _, m := range r.threadMessages(rootID) {
+		if m.deleted {
+			continue
+		}
+		root.threadReplyCount++
+		if root.threadLastReplyAt == nil || m.createdAt.After(*root.threadLastReplyAt) {
+			root.threadLastReplyAt = timePtr(m.createdAt)
+		}
+	}
+	return &repository.ThreadCounters{
+		RootID: rootID, ReplyCount: root.threadReplyCount, LastReplyAt: root.threadLastReplyAt,
+	}
+}
+
+func (r *messageStore) GetMessageChat(chatType string, messageID int) (int, error) {
+	if err := checkChatType(chatType); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.findMessage(chatType, messageID)
+	if m == nil {
+		return 0, repository.ErrMessageNotFound
+	}
+	return m.chatID, nil
+}
+
+func (r *messageStore) GetEditHistory(chatType string, messageID int) ([]models.MessageEdit, error) {
+	if err := checkChatType(chatType); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch m := r.findMessage(chatType, messageID); {
+	case m == nil:
+		return nil, repository.ErrMessageNotFound
+	case m.deleted:
+		return nil, repository.ErrMessageDeleted
+	}
+	var edits []models.MessageEdit
+	for _, e := range r.edits {
+		if e.chatType == chatType && e.messageID == messageID {
+			edits = append(edits, models.MessageEdit{ID: e.id, OldContent: e.oldContent, EditedAt: e.editedAt})
+		}
+	}
+	return edits, nil
+}
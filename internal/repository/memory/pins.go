@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"your_project/internal/models"
+	"your_project/internal/repository"
+)
+
+type pinStore struct{ *Store }
+
+func (r *pinStore) Pin(chatType string, chatID, messageID, userID int) (models.PinnedMessage, error) {
+	if err := checkChatType(chatType); err != nil {
+		return models.PinnedMessage{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.findMessage(chatType, messageID)
+	switch {
+	case m == nil || m.chatID != chatID:
+		return models.PinnedMessage{}, repository.ErrMessageNotFound
+	case m.deleted:
+		return models.PinnedMessage{}, repository.ErrMessageDeleted
+	case r.users[userID] == nil:
+		return models.PinnedMessage{}, errNoReference
+	}
+	p := &pin{chatID: chatID, pinnedBy: userID, pinnedAt: time.Now()}
+	r.pins[messageKey{chatType, messageID}] = p
+	return r.pinned(m, p), nil
+}
+
+func (r *pinStore) Unpin(chatType string, messageID int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := messageKey{chatType, messageID}
+	if r.pins[key] == nil {
+		return false, nil
+	}
+	delete(r.pins, key)
+	return true, nil
+}
+
+func (r *pinStore) List(chatType string, chatID int) ([]models.PinnedMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var pins []models.PinnedMessage
+	for key, p := range r.pins {
+		if key.chatType != chatType || p.chatID != chatID {
+			continue
+		}
+		// как JOIN с таблицей сообщений и NOT m.deleted
+		if m := r.findMessage(chatType, key.messageID); m != nil && !m.deleted {
+			pins = append(pins, r.pinned(m, p))
+		}
+	}
+	sort.Slice(pins, func(i, j int) bool {
+		if !pins[i].PinnedAt.Equal(pins[j].PinnedAt) {
+			return pins[i].PinnedAt.After(pins[j].PinnedAt)
+		}
+		return pins[i].MessageID > pins[j].MessageID
+	})
+	return pins, nil
+}
+
+func (r *pinStore) Latest(chatType string, chatIDs []int) (map[int]models.PinnedMessage, error) {
+	latest := make(map[int]models.PinnedMessage)
+	for _, chatID := range chatIDs {
+		pins, err := r.List(chatType, chatID)
+		if err != nil {
+			return nil, err
+		}
+		if len(pins) > 0 {
+			latest[chatID] = pins[0]
+		}
+	}
+	return latest, nil
+}
+
+// pinned собирает закреп; закрепивший с удалённым аккаунтом обнуляется,
+// как ON DELETE SET NULL
+func (r *pinStore) pinned(m *message, p *pin) models.PinnedMessage {
+	pm := models.PinnedMessage{
+		MessageID: m.id, SenderID: m.senderID, SenderUsername: r.users[m.senderID].Username,
+		Content: m.content, MediaURL: m.mediaURL, MediaType: m.mediaType, CreatedAt: m.createdAt,
+		PinnedAt: p.pinnedAt,
+	}
+	if u := r.users[p.pinnedBy]; u != nil {
+		pm.PinnedBy, pm.PinnedByUsername = p.pinnedBy, u.Username
+	}
+	return pm
+}
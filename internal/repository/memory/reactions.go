@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"time"
+
+	"your_project/internal/models"
+	"your_project/internal/repository"
+)
+
+type reactionStore struct{ *Store }
+
+func (r *reactionStore) Set(chatType string, messageID, userID int, emoji string) (string, error) {
+	if err := checkChatType(chatType); err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.findMessage(chatType, messageID)
+	switch {
+	case m == nil:
+		return "", repository.ErrMessageNotFound
+	case m.deleted:
+		return "", repository.ErrMessageDeleted
+	case r.users[userID] == nil:
+		return "", errNoReference
+	}
+	key := messageKey{chatType, messageID}
+	previous := ""
+	var kept []*reaction
+	for _, rc := range r.reactions[key] {
+		if rc.userID == userID {
+			previous = rc.emoji
+			continue
+		}
+		kept = append(kept, rc)
+	}
+	// как ON CONFLICT DO UPDATE с created_at = NOW(): реакция переезжает в конец
+	r.reactions[key] = append(kept, &reaction{userID: userID, emoji: emoji, createdAt: time.Now()})
+	return previous, nil
+}
+
+func (r *reactionStore) Remove(chatType string, messageID, userID int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := messageKey{chatType, messageID}
+	reactions := r.reactions[key]
+	for i, rc := range reactions {
+		if rc.userID == userID {
+			r.reactions[key] = append(reactions[:i:i], reactions[i+1:]...)
+			return rc.emoji, nil
+		}
+	}
+	return "", nil
+}
+
+func (r *reactionStore) Counts(chatType string, messageIDs []int, viewerID int) (map[int][]models.ReactionCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[int][]models.ReactionCount)
+	for _, messageID := range messageIDs {
+		index := make(map[string]int)
+		var list []models.ReactionCount
+		for _, rc := range r.reactions[messageKey{chatType, messageID}] {
+			i, ok := index[rc.emoji]
+			if !ok {
+				i = len(list)
+				index[rc.emoji] = i
+				list = append(list, models.ReactionCount{Emoji: rc.emoji})
+			}
+			list[i].Count++
+			if rc.userID == viewerID {
+				list[i].ReactedByMe = true
+			}
+		}
+		if list != nil {
+			counts[messageID] = list
+		}
+	}
+	return counts, nil
+}
+
+func (r *reactionStore) List(chatType string, messageID int) ([]models.Reaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var reactions []models.Reaction
+	for _, rc := range r.reactions[messageKey{chatType, messageID}] {
+		if u := r.users[rc.userID]; u != nil {
+			reactions = append(reactions, models.Reaction{
+				UserID: rc.userID, Username: u.Username, Emoji: rc.emoji, CreatedAt: rc.createdAt,
+			})
+		}
+	}
+	return reactions, nil
+}
@@ -0,0 +1,288 @@
+// Package memory — хранилища в памяти процесса с тем же поведением, что и
+// реализация над PostgreSQL: те же ошибки, порядок и правила блокировок.
+// Нужны, чтобы поднимать сервер целиком (HTTP и WebSocket) без базы данных.
+package memory
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"your_project/internal/models"
+	"your_project/internal/repository"
+)
+
+// errNoReference — аналог нарушения внешнего ключа в PostgreSQL
+var errNoReference = errors.New("связанная запись не найдена")
+
+// Store — общее состояние всех хранилищ. Каждый метод берёт mu целиком:
+// этого достаточно для тестов и повторяет атомарность транзакций БД.
+type Store struct {
+	mu sync.Mutex
+
+	users      map[int]*user
+	nextUserID int
+
+	sessions      map[string]*session
+	recovery      map[int][]*recoveryCode
+	attempts      []models.LoginAttempt
+	nextAttemptID int
+	emailTokens   map[string]*emailToken
+
+	conversations map[int]*conversation
+	groups        map[int]*group
+	nextChatID    map[string]int
+
+	// сообщения каждого типа чата по возрастанию ID
+	messages      map[string][]*message
+	nextMessageID map[string]int
+	edits         []*edit
+	nextEditID    int
+
+	// blocks[{кто, кого}]
+	blocks map[[2]int]bool
+	events map[int][]*event
+
+	// реакции на сообщение в порядке постановки
+	reactions map[messageKey][]*reaction
+	pins      map[messageKey]*pin
+}
+
+type user struct {
+	models.User
+	emailVerifiedAt     *time.Time
+	deletionRequestedAt *time.Time
+	deletedAt           *time.Time
+	fcmToken            string
+	totp                repository.TwoFactorState
+	eventSeq            int64
+}
+
+type session struct {
+	models.Session
+	refreshHash  string
+	previousHash string
+	revokedAt    *time.Time
+}
+
+type recoveryCode struct {
+	hash string
+	used bool
+}
+
+type emailToken struct {
+	userID    int
+	purpose   string
+	email     string
+	expiresAt time.Time
+	used      bool
+}
+
+// marks — отметки доставки и прочтения участника чата
+type marks struct {
+	delivered, read int
+}
+
+type conversation struct {
+	id        int
+	createdAt time.Time
+	members   map[int]*marks
+}
+
+type groupMember struct {
+	role     string
+	joinedAt time.Time
+	marks
+}
+
+type group struct {
+	id        int
+	name      string
+	avatarURL string
+	createdBy int
+	createdAt time.Time
+	members   map[int]*groupMember
+}
+
+type message struct {
+	id        int
+	chatID    int
+	senderID  int
+	content   string
+	mediaURL  string
+	mediaType string
+	createdAt time.Time
+	editedAt  *time.Time
+	deleted   bool
+	replyToID int
+	// первоисточник пересланного; имя автора берётся из users при чтении
+	forwardedFrom *models.ForwardInfo
+	// ответ в ветке и, у корня, счётчики ветки
+	threadRootID      int
+	threadReplyCount  int
+	threadLastReplyAt *time.Time
+}
+
+type messageKey struct {
+	chatType  string
+	messageID int
+}
+
+type reaction struct {
+	userID    int
+	emoji     string
+	createdAt time.Time
+}
+
+type pin struct {
+	chatID   int
+	pinnedBy int
+	pinnedAt time.Time
+}
+
+type edit struct {
+	id         int
+	chatType   string
+	messageID  int
+	oldContent string
+	editedAt   time.Time
+}
+
+type event struct {
+	seq       int64
+	eventType string
+	payload   []byte
+	createdAt time.Time
+}
+
+func NewStore() *Store {
+	return &Store{
+		users:         make(map[int]*user),
+		sessions:      make(map[string]*session),
+		recovery:      make(map[int][]*recoveryCode),
+		emailTokens:   make(map[string]*emailToken),
+		conversations: make(map[int]*conversation),
+		groups:        make(map[int]*group),
+		nextChatID:    make(map[string]int),
+		messages:      make(map[string][]*message),
+		nextMessageID: make(map[string]int),
+		blocks:        make(map[[2]int]bool),
+		events:        make(map[int][]*event),
+		reactions:     make(map[messageKey][]*reaction),
+		pins:          make(map[messageKey]*pin),
+	}
+}
+
+// New возвращает все хранилища над новым пустым Store
+func New() *repository.Repositories {
+	return NewStore().Repositories()
+}
+
+func (s *Store) Repositories() *repository.Repositories {
+	return &repository.Repositories{
+		Users:         &userStore{s},
+		Sessions:      &sessionStore{s},
+		TwoFactor:     &twoFactorStore{s},
+		LoginAttempts: &loginAttemptStore{s},
+		EmailTokens:   &emailTokenStore{s},
+		Conversations: &conversationStore{s},
+		Messages:      &messageStore{s},
+		Groups:        &groupStore{s},
+		Receipts:      &receiptStore{s},
+		Events:        &eventStore{s},
+		Blocks:        &blockStore{s},
+		Reactions:     &reactionStore{s},
+		Pins:          &pinStore{s},
+	}
+}
+
+// Дальше — помощники, которые вызываются под s.mu
+
+func (s *Store) isBlockedBetween(a, b int) bool {
+	return s.blocks[[2]int{a, b}] || s.blocks[[2]int{b, a}]
+}
+
+// chatMessages — основная лента чата (без ответов в ветках) по возрастанию ID
+func (s *Store) chatMessages(chatType string, chatID int) []*message {
+	var msgs []*message
+	for _, m := range s.messages[chatType] {
+		if m.chatID == chatID && m.threadRootID == 0 {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+func (s *Store) findMessage(chatType string, messageID int) *message {
+	msgs := s.messages[chatType]
+	i := sort.Search(len(msgs), func(i int) bool { return msgs[i].id >= messageID })
+	if i < len(msgs) && msgs[i].id == messageID {
+		return msgs[i]
+	}
+	return nil
+}
+
+// lastMessage — последнее сообщение основной ленты чата (включая удалённые),
+// nil — пусто
+func (s *Store) lastMessage(chatType string, chatID int) *message {
+	msgs := s.messages[chatType]
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].chatID == chatID && msgs[i].threadRootID == 0 {
+			return msgs[i]
+		}
+	}
+	return nil
+}
+
+// lastMessageID — ID последнего сообщения чата вместе с ветками; 0 — пусто
+func (s *Store) lastMessageID(chatType string, chatID int) int {
+	msgs := s.messages[chatType]
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].chatID == chatID {
+			return msgs[i].id
+		}
+	}
+	return 0
+}
+
+// unreadCount — чужие неудалённые сообщения новее отметки прочтения
+func (s *Store) unreadCount(chatType string, chatID, userID, readUpTo int) int {
+	n := 0
+	for _, m := range s.messages[chatType] {
+		if m.chatID == chatID && m.threadRootID == 0 && m.id > readUpTo && m.senderID != userID && !m.deleted {
+			n++
+		}
+	}
+	return n
+}
+
+// chatMarks возвращает отметки участника чата; nil — он не участник
+func (s *Store) chatMarks(chatType string, chatID, userID int) *marks {
+	switch chatType {
+	case models.ChatDirect:
+		if c, ok := s.conversations[chatID]; ok {
+			return c.members[userID]
+		}
+	case models.ChatGroup:
+		if g, ok := s.groups[chatID]; ok {
+			if m, ok := g.members[userID]; ok {
+				return &m.marks
+			}
+		}
+	}
+	return nil
+}
+
+func sortedIDs(set map[int]bool) []int {
+	ids := make([]int, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
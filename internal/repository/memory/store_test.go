@@ -0,0 +1,12 @@
+package memory
+
+import (
+	"testing"
+
+	"your_project/internal/repository"
+	"your_project/internal/repository/repotest"
+)
+
+func TestContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) *repository.Repositories { return New() })
+}
@@ -0,0 +1,278 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"your_project/internal/models"
+	"your_project/internal/repository"
+)
+
+type userStore struct{ *Store }
+
+// emailVerifiedBy — подтверждён ли адрес другим пользователем (без учёта
+// регистра); неподтверждённые адреса могут повторяться
+func (s *Store) emailVerifiedBy(email string, exceptID int) bool {
+	for id, u := range s.users {
+		if id != exceptID && u.emailVerifiedAt != nil && strings.EqualFold(u.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *userStore) CreateUser(u models.User) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, other := range r.users {
+		if other.Username == u.Username {
+			return 0, repository.ErrUsernameTaken
+		}
+	}
+	r.nextUserID++
+	row := &user{User: models.User{
+		ID:          r.nextUserID,
+		Username:    u.Username,
+		Password:    u.Password,
+		DisplayName: u.DisplayName,
+		UserTag:     u.UserTag,
+		Email:       u.Email,
+	}}
+	r.users[row.ID] = row
+	return row.ID, nil
+}
+
+// brief — поля, которые отдают GetUserByUsername и GetUserByID
+func (u *user) brief() *models.User {
+	return &models.User{
+		ID: u.ID, Username: u.Username, Password: u.Password,
+		DisplayName: u.DisplayName, UserTag: u.UserTag,
+	}
+}
+
+func (r *userStore) GetUserByUsername(username string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Username == username {
+			return u.brief(), nil
+		}
+	}
+	return &models.User{}, sql.ErrNoRows
+}
+
+func (r *userStore) GetUserByID(id int) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return &models.User{}, sql.ErrNoRows
+	}
+	return u.brief(), nil
+}
+
+func (r *userStore) GetUserByVerifiedEmail(email string) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.emailVerifiedAt != nil && strings.EqualFold(u.Email, email) {
+			return &models.User{ID: u.ID, Username: u.Username, Email: u.Email, EmailVerified: true}, nil
+		}
+	}
+	return &models.User{}, sql.ErrNoRows
+}
+
+func (r *userStore) GetProfile(id int) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return &models.User{}, sql.ErrNoRows
+	}
+	p := u.User
+	p.Password = ""
+	p.EmailVerified = u.emailVerifiedAt != nil
+	p.DeletionRequestedAt = u.deletionRequestedAt
+	return &p, nil
+}
+
+func (r *userStore) UpdateProfile(userID int, displayName, bio, avatarURL string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[userID]; ok {
+		u.DisplayName, u.Bio, u.AvatarURL = displayName, bio, avatarURL
+	}
+	return nil
+}
+
+func (r *userStore) SetEmail(userID int, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[userID]; ok {
+		u.Email, u.emailVerifiedAt = email, nil
+	}
+	return nil
+}
+
+func (r *userStore) MarkEmailVerified(userID int, email string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[userID]
+	if !ok || u.Email == "" || !strings.EqualFold(u.Email, email) {
+		return false, nil
+	}
+	if r.emailVerifiedBy(email, userID) {
+		return false, repository.ErrEmailTaken
+	}
+	u.emailVerifiedAt = timePtr(time.Now())
+	for id, other := range r.users {
+		if id != userID && other.emailVerifiedAt == nil && strings.EqualFold(other.Email, email) {
+			other.Email = ""
+		}
+	}
+	return true, nil
+}
+
+func (r *userStore) SetPassword(userID int, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[userID]; ok {
+		u.Password = hash
+	}
+	return nil
+}
+
+func (r *userStore) SetUsername(userID int, username string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, u := range r.users {
+		if id != userID && strings.EqualFold(u.Username, username) {
+			return repository.ErrUsernameTaken
+		}
+	}
+	if u, ok := r.users[userID]; ok {
+		u.Username = username
+	}
+	return nil
+}
+
+func (r *userStore) SearchByTag(tag string, currentUserID int) ([]models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tag = strings.ToLower(tag)
+	var found []models.User
+	for id, u := range r.users {
+		if id == currentUserID || u.deletedAt != nil || r.isBlockedBetween(id, currentUserID) {
+			continue
+		}
+		if strings.Contains(strings.ToLower(u.UserTag), tag) {
+			found = append(found, models.User{
+				ID: u.ID, Username: u.Username, DisplayName: u.DisplayName, UserTag: u.UserTag,
+			})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].ID < found[j].ID })
+	if len(found) > 20 {
+		found = found[:20]
+	}
+	return found, nil
+}
+
+func (r *userStore) SetFCMToken(userID int, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[userID]; ok {
+		u.fcmToken = token
+	}
+	return nil
+}
+
+func (r *userStore) FCMToken(userID int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[userID]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return u.fcmToken, nil
+}
+
+func (r *userStore) TouchLastSeen(userID int) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[userID]
+	if !ok {
+		return time.Time{}, sql.ErrNoRows
+	}
+	u.LastSeenAt = timePtr(time.Now())
+	return *u.LastSeenAt, nil
+}
+
+func (r *userStore) RequestDeletion(userID int) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[userID]
+	if !ok || u.deletedAt != nil {
+		return time.Time{}, sql.ErrNoRows
+	}
+	if u.deletionRequestedAt == nil {
+		u.deletionRequestedAt = timePtr(time.Now())
+	}
+	return *u.deletionRequestedAt, nil
+}
+
+func (r *userStore) CancelDeletion(userID int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[userID]
+	if !ok || u.deletionRequestedAt == nil || u.deletedAt != nil {
+		return false, nil
+	}
+	u.deletionRequestedAt = nil
+	return true, nil
+}
+
+func (r *userStore) DueForDeletion(requestedBefore time.Time) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	due := make(map[int]bool)
+	for id, u := range r.users {
+		if u.deletionRequestedAt != nil && u.deletionRequestedAt.Before(requestedBefore) && u.deletedAt == nil {
+			due[id] = true
+		}
+	}
+	return sortedIDs(due), nil
+}
+
+func (r *userStore) SoftDelete(userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[userID]; ok && u.deletedAt == nil {
+		u.Username = fmt.Sprintf("deleted_%d", userID)
+		u.Password = ""
+		u.DisplayName = "Удалённый аккаунт"
+		u.UserTag, u.Bio, u.AvatarURL, u.fcmToken = "", "", "", ""
+		u.Email, u.emailVerifiedAt = "", nil
+		u.totp.Secret, u.totp.Enabled = "", false
+		u.deletedAt = timePtr(time.Now())
+	}
+	for _, g := range r.groups {
+		delete(g.members, userID)
+	}
+	for pair := range r.blocks {
+		if pair[0] == userID || pair[1] == userID {
+			delete(r.blocks, pair)
+		}
+	}
+	delete(r.recovery, userID)
+	for hash, t := range r.emailTokens {
+		if t.userID == userID {
+			delete(r.emailTokens, hash)
+		}
+	}
+	delete(r.events, userID)
+	return nil
+}
@@ -2,51 +2,147 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+
 	"your_project/internal/models"
 )
 
+var (
+	ErrMessageNotFound  = errors.New("сообщение не найдено")
+	ErrNotMessageSender = errors.New("сообщение отправлено другим пользователем")
+	ErrMessageDeleted   = errors.New("сообщение удалено")
+	ErrThreadNotFound   = errors.New("ветка не найдена")
+)
+
+// ForwardSource — пересылаемое сообщение и атрибуция, которую получит копия
+type ForwardSource struct {
+	ID        int
+	Content   string
+	MediaURL  string
+	MediaType string
+	Deleted   bool
+	From      models.ForwardInfo
+}
+
+// ForwardedCopy — сохранённая копия пересланного сообщения
+type ForwardedCopy struct {
+	ID        int
+	CreatedAt time.Time
+}
+
+// EditedMessage — результат правки: чат сообщения, корень ветки, если это
+// ответ в ней (иначе 0), и время правки
+type EditedMessage struct {
+	ChatID       int
+	ThreadRootID int
+	EditedAt     time.Time
+}
+
+// ThreadCounters — счётчики ветки RootID после удаления одного из её ответов
+type ThreadCounters struct {
+	RootID      int
+	ReplyCount  int
+	LastReplyAt *time.Time
+}
+
 type MessageRepository struct {
 	DB *sql.DB
 }
 
-func (r *MessageRepository) GetOrCreateConversation(userID1, userID2 int) (int, error) {
+func (r *MessageRepository) SaveMessage(msg models.Message) (models.Message, error) {
 	query := `
-		SELECT c.id FROM conversations c
-		JOIN conversation_members m1 ON c.id = m1.conversation_id AND m1.user_id = $1
-		JOIN conversation_members m2 ON c.id = m2.conversation_id AND m2.user_id = $2
-		LIMIT 1`
-	var convID int
-	err := r.DB.QueryRow(query, userID1, userID2).Scan(&convID)
-	if err == nil {
-		return convID, nil
+		INSERT INTO messages (conversation_id, sender_id, content, media_url, media_type, reply_to_id,
+			forward_sender_id, forward_chat_type, forward_chat_id, forward_message_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), ` + forwardValues + `)
+		RETURNING id, created_at`
+	args := append([]interface{}{msg.ConversationID, msg.SenderID, msg.Content, msg.MediaURL, msg.MediaType, msg.ReplyToID},
+		forwardArgs(msg.ForwardedFrom)...)
+	err := r.DB.QueryRow(query, args...).Scan(&msg.ID, &msg.CreatedAt)
+	return msg, err
+}
+
+func (r *MessageRepository) SaveGroupMessage(msg models.GroupMessage) (models.GroupMessage, error) {
+	query := `
+		INSERT INTO group_messages (group_id, sender_id, content, media_url, media_type, reply_to_id,
+			forward_sender_id, forward_chat_type, forward_chat_id, forward_message_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), ` + forwardValues + `)
+		RETURNING id, created_at`
+	args := append([]interface{}{msg.GroupID, msg.SenderID, msg.Content, msg.MediaURL, msg.MediaType, msg.ReplyToID},
+		forwardArgs(msg.ForwardedFrom)...)
+	err := r.DB.QueryRow(query, args...).Scan(&msg.ID, &msg.CreatedAt)
+	return msg, err
+}
+
+// SaveForwarded сохраняет копии sources в чат chatID от имени senderID одной
+// транзакцией: либо все, либо ни одной. Копии идут в порядке sources.
+func (r *MessageRepository) SaveForwarded(chatType string, chatID, senderID int, sources []ForwardSource) ([]ForwardedCopy, error) {
+	table, chatColumn, err := messageTable(chatType)
+	if err != nil {
+		return nil, err
 	}
-	err = r.DB.QueryRow(`INSERT INTO conversations DEFAULT VALUES RETURNING id`).Scan(&convID)
+	tx, err := r.DB.Begin()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	_, err = r.DB.Exec(`INSERT INTO conversation_members (conversation_id, user_id) VALUES ($1, $2), ($1, $3)`, convID, userID1, userID2)
-	return convID, err
-}
+	defer tx.Rollback()
 
-func (r *MessageRepository) SaveMessage(msg models.Message) (models.Message, error) {
 	query := `
-		INSERT INTO messages (conversation_id, sender_id, content, media_url, media_type)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO ` + table + ` (` + chatColumn + `, sender_id, content, media_url, media_type, reply_to_id,
+			forward_sender_id, forward_chat_type, forward_chat_id, forward_message_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), ` + forwardValues + `)
 		RETURNING id, created_at`
-	err := r.DB.QueryRow(query, msg.ConversationID, msg.SenderID, msg.Content, msg.MediaURL, msg.MediaType).
-		Scan(&msg.ID, &msg.CreatedAt)
-	return msg, err
+	copies := make([]ForwardedCopy, len(sources))
+	for i, src := range sources {
+		from := src.From
+		args := append([]interface{}{chatID, senderID, src.Content, src.MediaURL, src.MediaType, 0},
+			forwardArgs(&from)...)
+		if err := tx.QueryRow(query, args...).Scan(&copies[i].ID, &copies[i].CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	return copies, tx.Commit()
 }
 
 func (r *MessageRepository) GetMessages(conversationID int) ([]models.Message, error) {
+	return r.queryMessages(`m.conversation_id = $1`, conversationID)
+}
+
+// GetMessagesPage возвращает страницу истории диалога по курсору
+func (r *MessageRepository) GetMessagesPage(conversationID int, p Page) (models.MessagePage, error) {
+	page := models.MessagePage{Messages: []models.Message{}}
+	b, err := r.pageBounds(pageScope{"messages", "conversation_id = $1", conversationID}, p)
+	if err != nil || b.empty() {
+		return page, err
+	}
+	msgs, err := r.queryMessages(`m.conversation_id = $1 AND m.id BETWEEN $2 AND $3`, conversationID, b.fromID, b.toID)
+	if err != nil {
+		return page, err
+	}
+	if msgs != nil {
+		page.Messages = msgs
+	}
+	page.NextCursor, page.PrevCursor = b.cursors()
+	return page, nil
+}
+
+func (r *MessageRepository) queryMessages(where string, args ...interface{}) ([]models.Message, error) {
 	query := `
 		SELECT m.id, m.conversation_id, m.sender_id, u.username, m.content,
-			COALESCE(m.media_url,''), COALESCE(m.media_type,''), m.created_at
+			COALESCE(m.media_url,''), COALESCE(m.media_type,''), m.created_at,
+			m.edited_at, m.deleted, ` + replyColumns + `, ` + forwardColumns("m") + `
 		FROM messages m
 		JOIN users u ON m.sender_id = u.id
-		WHERE m.conversation_id = $1
-		ORDER BY m.created_at ASC`
-	rows, err := r.DB.Query(query, conversationID)
+		LEFT JOIN messages r ON r.id = m.reply_to_id
+		LEFT JOIN users ru ON ru.id = r.sender_id
+		LEFT JOIN users fu ON fu.id = m.forward_sender_id
+		WHERE ` + where + `
+		ORDER BY m.id ASC`
+	rows, err := r.DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -54,35 +150,547 @@ func (r *MessageRepository) GetMessages(conversationID int) ([]models.Message, e
 	var messages []models.Message
 	for rows.Next() {
 		var msg models.Message
-		rows.Scan(&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.SenderUsername,
-			&msg.Content, &msg.MediaURL, &msg.MediaType, &msg.CreatedAt)
+		var reply replyRow
+		var fwd forwardRow
+		rows.Scan(append(append([]interface{}{&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.SenderUsername,
+			&msg.Content, &msg.MediaURL, &msg.MediaType, &msg.CreatedAt,
+			&msg.EditedAt, &msg.Deleted}, reply.dest()...), fwd.dest()...)...)
+		msg.ReplyToID, msg.ReplyTo = reply.preview()
+		msg.ForwardedFrom = fwd.info()
 		messages = append(messages, msg)
 	}
 	return messages, nil
 }
 
-func (r *MessageRepository) GetConversations(userID int) ([]models.Conversation, error) {
+// GetGroupMessages — основная лента группы, без ответов в ветках
+func (r *MessageRepository) GetGroupMessages(groupID int) ([]models.GroupMessage, error) {
+	return r.queryGroupMessages(`gm.group_id = $1 AND gm.thread_root_id IS NULL`, groupID)
+}
+
+// GetGroupMessagesPage возвращает страницу истории группы по курсору
+func (r *MessageRepository) GetGroupMessagesPage(groupID int, p Page) (models.GroupMessagePage, error) {
+	page := models.GroupMessagePage{Messages: []models.GroupMessage{}}
+	b, err := r.pageBounds(pageScope{"group_messages", "group_id = $1 AND thread_root_id IS NULL", groupID}, p)
+	if err != nil || b.empty() {
+		return page, err
+	}
+	msgs, err := r.queryGroupMessages(`gm.group_id = $1 AND gm.thread_root_id IS NULL AND gm.id BETWEEN $2 AND $3`, groupID, b.fromID, b.toID)
+	if err != nil {
+		return page, err
+	}
+	if msgs != nil {
+		page.Messages = msgs
+	}
+	page.NextCursor, page.PrevCursor = b.cursors()
+	return page, nil
+}
+
+func (r *MessageRepository) queryGroupMessages(where string, args ...interface{}) ([]models.GroupMessage, error) {
 	query := `
-		SELECT c.id,
-			u.id as other_user_id,
-			u.username as other_username,
-			COALESCE((SELECT content FROM messages WHERE conversation_id = c.id ORDER BY created_at DESC LIMIT 1), '') as last_message,
-			c.created_at
-		FROM conversations c
-		JOIN conversation_members cm ON c.id = cm.conversation_id AND cm.user_id = $1
-		JOIN conversation_members cm2 ON c.id = cm2.conversation_id AND cm2.user_id != $1
-		JOIN users u ON cm2.user_id = u.id
-		ORDER BY c.created_at DESC`
-	rows, err := r.DB.Query(query, userID)
+		SELECT gm.id, gm.group_id, gm.sender_id, u.username,
+			gm.content, COALESCE(gm.media_url,''), COALESCE(gm.media_type,''), gm.created_at,
+			gm.edited_at, gm.deleted, COALESCE(gm.thread_root_id, 0),
+			gm.thread_reply_count, gm.thread_last_reply_at, ` + replyColumns + `, ` + forwardColumns("gm") + `
+		FROM group_messages gm
+		JOIN users u ON gm.sender_id = u.id
+		LEFT JOIN group_messages r ON r.id = gm.reply_to_id
+		LEFT JOIN users ru ON ru.id = r.sender_id
+		LEFT JOIN users fu ON fu.id = gm.forward_sender_id
+		WHERE ` + where + `
+		ORDER BY gm.id ASC`
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var msgs []models.GroupMessage
+	for rows.Next() {
+		var m models.GroupMessage
+		var reply replyRow
+		var fwd forwardRow
+		rows.Scan(append(append([]interface{}{&m.ID, &m.GroupID, &m.SenderID, &m.SenderUsername,
+			&m.Content, &m.MediaURL, &m.MediaType, &m.CreatedAt,
+			&m.EditedAt, &m.Deleted, &m.ThreadRootID,
+			&m.ThreadReplyCount, &m.ThreadLastReplyAt}, reply.dest()...), fwd.dest()...)...)
+		m.ReplyToID, m.ReplyTo = reply.preview()
+		m.ForwardedFrom = fwd.info()
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+// SaveThreadReply сохраняет ответ в ветку msg.ThreadRootID и обновляет
+// счётчики корня. Корень должен быть сообщением основной ленты той же группы,
+// иначе ErrThreadNotFound; msg.ReplyToID — корнем или ответом этой ветки,
+// иначе ErrMessageNotFound. Возвращает ответ и новое число ответов ветки.
+func (r *MessageRepository) SaveThreadReply(msg models.GroupMessage) (models.GroupMessage, int, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return msg, 0, err
+	}
+	defer tx.Rollback()
+
+	// FOR UPDATE — параллельные ответы не теряют приращения счётчика
+	var rootID int
+	err = tx.QueryRow(
+		`SELECT id FROM group_messages WHERE id=$1 AND group_id=$2 AND thread_root_id IS NULL FOR UPDATE`,
+		msg.ThreadRootID, msg.GroupID,
+	).Scan(&rootID)
+	if err == sql.ErrNoRows {
+		return msg, 0, ErrThreadNotFound
+	}
+	if err != nil {
+		return msg, 0, err
+	}
+	// отвечать внутри ветки можно только на её корень и её ответы
+	if msg.ReplyToID != 0 {
+		var replyToID int
+		err = tx.QueryRow(
+			`SELECT id FROM group_messages WHERE id=$1 AND (id=$2 OR thread_root_id=$2)`,
+			msg.ReplyToID, rootID,
+		).Scan(&replyToID)
+		if err == sql.ErrNoRows {
+			return msg, 0, ErrMessageNotFound
+		}
+		if err != nil {
+			return msg, 0, err
+		}
+	}
+	err = tx.QueryRow(`
+		INSERT INTO group_messages (group_id, sender_id, content, media_url, media_type, reply_to_id, thread_root_id)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), $7)
+		RETURNING id, created_at`,
+		msg.GroupID, msg.SenderID, msg.Content, msg.MediaURL, msg.MediaType, msg.ReplyToID, rootID,
+	).Scan(&msg.ID, &msg.CreatedAt)
+	if err != nil {
+		return msg, 0, err
+	}
+	var replyCount int
+	if err = tx.QueryRow(
+		`UPDATE group_messages SET thread_reply_count = thread_reply_count + 1, thread_last_reply_at = $2
+		WHERE id=$1 RETURNING thread_reply_count`,
+		rootID, msg.CreatedAt,
+	).Scan(&replyCount); err != nil {
+		return msg, 0, err
+	}
+	return msg, replyCount, tx.Commit()
+}
+
+// GetThreadPage возвращает корень ветки и страницу её ответов по курсору
+func (r *MessageRepository) GetThreadPage(groupID, rootID int, p Page) (models.ThreadPage, error) {
+	page := models.ThreadPage{Messages: []models.GroupMessage{}}
+	roots, err := r.queryGroupMessages(`gm.id = $1 AND gm.group_id = $2 AND gm.thread_root_id IS NULL`, rootID, groupID)
+	if err != nil {
+		return page, err
+	}
+	if len(roots) == 0 {
+		return page, ErrThreadNotFound
+	}
+	page.Root = roots[0]
+
+	b, err := r.pageBounds(pageScope{"group_messages", "thread_root_id = $1", rootID}, p)
+	if err != nil || b.empty() {
+		return page, err
+	}
+	msgs, err := r.queryGroupMessages(`gm.thread_root_id = $1 AND gm.id BETWEEN $2 AND $3`, rootID, b.fromID, b.toID)
+	if err != nil {
+		return page, err
+	}
+	if msgs != nil {
+		page.Messages = msgs
+	}
+	page.NextCursor, page.PrevCursor = b.cursors()
+	return page, nil
+}
+
+// ThreadParticipantIDs — автор корня и все, кто отвечал в ветке, из тех,
+// кто ещё состоит в группе
+func (r *MessageRepository) ThreadParticipantIDs(rootID, excludeUserID int) ([]int, error) {
+	rows, err := r.DB.Query(`
+		SELECT DISTINCT p.sender_id
+		FROM group_messages p
+		JOIN group_members gm ON gm.group_id = p.group_id AND gm.user_id = p.sender_id
+		WHERE (p.id = $1 OR p.thread_root_id = $1) AND p.sender_id != $2
+		ORDER BY p.sender_id`, rootID, excludeUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// replyColumns — поля цитируемого сообщения r и его отправителя ru из LEFT JOIN
+const replyColumns = `r.id, COALESCE(r.sender_id, 0), COALESCE(ru.username, ''),
+			COALESCE(r.content, ''), COALESCE(r.media_type, ''), COALESCE(r.deleted, FALSE)`
+
+// replyRow принимает replyColumns; ID не Valid — сообщение не ответ
+type replyRow struct {
+	id                           sql.NullInt64
+	senderID                     int
+	username, content, mediaType string
+	deleted                      bool
+}
+
+func (r *replyRow) dest() []interface{} {
+	return []interface{}{&r.id, &r.senderID, &r.username, &r.content, &r.mediaType, &r.deleted}
+}
+
+func (r *replyRow) preview() (int, *models.ReplyPreview) {
+	if !r.id.Valid {
+		return 0, nil
+	}
+	return int(r.id.Int64), NewReplyPreview(int(r.id.Int64), r.senderID, r.username, r.content, r.mediaType, r.deleted)
+}
+
+// ReplySnippetLen — сколько символов текста оригинала попадает в цитату
+const ReplySnippetLen = 100
+
+// DeletedReplySnippet — текст цитаты вместо удалённого оригинала
+const DeletedReplySnippet = "Сообщение удалено"
+
+// NewReplyPreview собирает цитату: обрезает текст до ReplySnippetLen
+// символов, а у удалённого сообщения оставляет только заглушку
+func NewReplyPreview(id, senderID int, senderUsername, content, mediaType string, deleted bool) *models.ReplyPreview {
+	p := &models.ReplyPreview{ID: id, SenderID: senderID, SenderUsername: senderUsername, MediaType: mediaType}
+	if deleted {
+		p.Snippet, p.MediaType, p.Deleted = DeletedReplySnippet, "", true
+		return p
+	}
+	if utf8.RuneCountInString(content) > ReplySnippetLen {
+		content = string([]rune(content)[:ReplySnippetLen]) + "…"
+	}
+	p.Snippet = content
+	return p
+}
+
+// ReplyPreview возвращает цитату сообщения messageID, если оно из чата chatID;
+// иначе ErrMessageNotFound — отвечать можно только в пределах чата
+func (r *MessageRepository) ReplyPreview(chatType string, chatID, messageID int) (*models.ReplyPreview, error) {
+	table, chatColumn, err := messageTable(chatType)
+	if err != nil {
+		return nil, err
+	}
+	var senderID int
+	var username, content, mediaType string
+	var deleted bool
+	err = r.DB.QueryRow(
+		`SELECT m.sender_id, u.username, m.content, COALESCE(m.media_type,''), m.deleted
+		FROM `+table+` m
+		JOIN users u ON u.id = m.sender_id
+		WHERE m.id=$1 AND m.`+chatColumn+`=$2`,
+		messageID, chatID,
+	).Scan(&senderID, &username, &content, &mediaType, &deleted)
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewReplyPreview(messageID, senderID, username, content, mediaType, deleted), nil
+}
+
+// forwardValues — плейсхолдеры INSERT для forwardArgs
+const forwardValues = `NULLIF($7, 0), NULLIF($8, ''), NULLIF($9, 0), NULLIF($10, 0)`
+
+// forwardArgs — значения колонок пересылки; nil — обычное сообщение
+func forwardArgs(f *models.ForwardInfo) []interface{} {
+	if f == nil {
+		return []interface{}{0, "", 0, 0}
+	}
+	return []interface{}{f.SenderID, f.ChatType, f.ChatID, f.MessageID}
+}
+
+// forwardColumns — атрибуция пересылки сообщения alias и автор оригинала fu из LEFT JOIN
+func forwardColumns(alias string) string {
+	return `COALESCE(` + alias + `.forward_sender_id, 0), COALESCE(fu.username, ''),
+			COALESCE(` + alias + `.forward_chat_type, ''), COALESCE(` + alias + `.forward_chat_id, 0),
+			COALESCE(` + alias + `.forward_message_id, 0)`
+}
+
+// forwardRow принимает forwardColumns; пустой chatType — сообщение не пересланное
+type forwardRow struct {
+	senderID          int
+	username          string
+	chatType          string
+	chatID, messageID int
+}
+
+func (f *forwardRow) dest() []interface{} {
+	return []interface{}{&f.senderID, &f.username, &f.chatType, &f.chatID, &f.messageID}
+}
+
+func (f *forwardRow) info() *models.ForwardInfo {
+	if f.chatType == "" {
+		return nil
+	}
+	return &models.ForwardInfo{
+		SenderID: f.senderID, SenderUsername: f.username,
+		ChatType: f.chatType, ChatID: f.chatID, MessageID: f.messageID,
+	}
+}
+
+// ForwardSources возвращает сообщения messageIDs чата chatID по возрастанию ID
+// с атрибуцией для их копий: у пересланного — его первоисточник. Если хоть
+// одного сообщения нет в этом чате — ErrMessageNotFound.
+func (r *MessageRepository) ForwardSources(chatType string, chatID int, messageIDs []int) ([]ForwardSource, error) {
+	table, chatColumn, err := messageTable(chatType)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(messageIDs))
+	for i, id := range messageIDs {
+		ids[i] = int64(id)
+	}
+	rows, err := r.DB.Query(`
+		SELECT m.id, m.sender_id, u.username, m.content,
+			COALESCE(m.media_url,''), COALESCE(m.media_type,''), m.deleted, `+forwardColumns("m")+`
+		FROM `+table+` m
+		JOIN users u ON u.id = m.sender_id
+		LEFT JOIN users fu ON fu.id = m.forward_sender_id
+		WHERE m.`+chatColumn+` = $1 AND m.id = ANY($2)
+		ORDER BY m.id`, chatID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sources []ForwardSource
+	for rows.Next() {
+		var s ForwardSource
+		var senderID int
+		var username string
+		var fwd forwardRow
+		if err := rows.Scan(append([]interface{}{&s.ID, &senderID, &username, &s.Content,
+			&s.MediaURL, &s.MediaType, &s.Deleted}, fwd.dest()...)...); err != nil {
+			return nil, err
+		}
+		s.From = NewForwardInfo(chatType, chatID, s.ID, senderID, username, fwd.info())
+		sources = append(sources, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(sources) != countDistinct(messageIDs) {
+		return nil, ErrMessageNotFound
+	}
+	return sources, nil
+}
+
+// NewForwardInfo — атрибуция копии сообщения messageID: первоисточник
+// original, если оно само переслано, иначе автор и чат этого сообщения
+func NewForwardInfo(chatType string, chatID, messageID, senderID int, senderUsername string, original *models.ForwardInfo) models.ForwardInfo {
+	if original != nil {
+		return *original
+	}
+	return models.ForwardInfo{
+		SenderID: senderID, SenderUsername: senderUsername,
+		ChatType: chatType, ChatID: chatID, MessageID: messageID,
+	}
+}
+
+func countDistinct(ids []int) int {
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		seen[id] = true
+	}
+	return len(seen)
+}
+
+// messageTable возвращает таблицу сообщений и колонку чата для типа чата
+func messageTable(chatType string) (table, chatColumn string, err error) {
+	switch chatType {
+	case models.ChatDirect:
+		return "messages", "conversation_id", nil
+	case models.ChatGroup:
+		return "group_messages", "group_id", nil
+	}
+	return "", "", fmt.Errorf("неизвестный тип чата %q", chatType)
+}
+
+// lockOwnMessage блокирует строку сообщения и проверяет, что её отправил userID
+func lockOwnMessage(tx *sql.Tx, table, chatColumn string, messageID, userID int) (chatID int, content string, err error) {
+	var senderID int
+	var deleted bool
+	err = tx.QueryRow(
+		`SELECT `+chatColumn+`, sender_id, content, deleted FROM `+table+` WHERE id=$1 FOR UPDATE`,
+		messageID,
+	).Scan(&chatID, &senderID, &content, &deleted)
+	if err == sql.ErrNoRows {
+		return 0, "", ErrMessageNotFound
+	}
+	if err != nil {
+		return 0, "", err
+	}
+	if senderID != userID {
+		return 0, "", ErrNotMessageSender
+	}
+	if deleted {
+		return 0, "", ErrMessageDeleted
+	}
+	return chatID, content, nil
+}
+
+// EditMessage меняет текст сообщения, сохраняя прежний текст в message_edits.
+func (r *MessageRepository) EditMessage(chatType string, messageID, userID int, content string) (EditedMessage, error) {
+	table, chatColumn, err := messageTable(chatType)
+	if err != nil {
+		return EditedMessage{}, err
+	}
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return EditedMessage{}, err
+	}
+	defer tx.Rollback()
+
+	chatID, oldContent, err := lockOwnMessage(tx, table, chatColumn, messageID, userID)
+	if err != nil {
+		return EditedMessage{}, err
+	}
+	if _, err = tx.Exec(
+		`INSERT INTO message_edits (chat_type, message_id, old_content) VALUES ($1, $2, $3)`,
+		chatType, messageID, oldContent,
+	); err != nil {
+		return EditedMessage{}, err
+	}
+	// ветки есть только у групповых сообщений
+	threadRoot := "0"
+	if chatType == models.ChatGroup {
+		threadRoot = "COALESCE(thread_root_id, 0)"
+	}
+	edited := EditedMessage{ChatID: chatID}
+	if err = tx.QueryRow(
+		`UPDATE `+table+` SET content=$1, edited_at=NOW() WHERE id=$2 RETURNING edited_at, `+threadRoot,
+		content, messageID,
+	).Scan(&edited.EditedAt, &edited.ThreadRootID); err != nil {
+		return EditedMessage{}, err
+	}
+	return edited, tx.Commit()
+}
+
+// DeleteMessage превращает сообщение в «надгробие»: строка остаётся,
+// а текст, вложение и история правок стираются. Возвращает ID чата и, если
+// удалён ответ в ветке, пересчитанные счётчики её корня (иначе nil).
+func (r *MessageRepository) DeleteMessage(chatType string, messageID, userID int) (int, *ThreadCounters, error) {
+	table, chatColumn, err := messageTable(chatType)
+	if err != nil {
+		return 0, nil, err
+	}
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	chatID, _, err := lockOwnMessage(tx, table, chatColumn, messageID, userID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if _, err = tx.Exec(
+		`UPDATE `+table+` SET deleted=TRUE, content='', media_url='', media_type='' WHERE id=$1`,
+		messageID,
+	); err != nil {
+		return 0, nil, err
+	}
+	if _, err = tx.Exec(
+		`DELETE FROM message_edits WHERE chat_type=$1 AND message_id=$2`,
+		chatType, messageID,
+	); err != nil {
+		return 0, nil, err
+	}
+	var thread *ThreadCounters
+	if chatType == models.ChatGroup {
+		if thread, err = recountThread(tx, messageID); err != nil {
+			return 0, nil, err
+		}
+	}
+	return chatID, thread, tx.Commit()
+}
+
+// recountThread пересчитывает счётчики ветки, в которой лежит ответ
+// messageID, по её неудалённым ответам. Для сообщения основной ленты — nil.
+func recountThread(tx *sql.Tx, messageID int) (*ThreadCounters, error) {
+	var rootID sql.NullInt64
+	if err := tx.QueryRow(
+		`SELECT thread_root_id FROM group_messages WHERE id=$1`, messageID,
+	).Scan(&rootID); err != nil || !rootID.Valid {
+		return nil, err
+	}
+	// корень блокируется отдельным запросом, как в SaveThreadReply: пересчёт
+	// идёт уже после блокировки и видит все ответы, сохранённые до неё
+	if _, err := tx.Exec(
+		`SELECT id FROM group_messages WHERE id=$1 FOR UPDATE`, rootID.Int64,
+	); err != nil {
+		return nil, err
+	}
+	thread := &ThreadCounters{RootID: int(rootID.Int64)}
+	err := tx.QueryRow(`
+		UPDATE group_messages root SET
+			thread_reply_count = (SELECT COUNT(*) FROM group_messages
+				WHERE thread_root_id = root.id AND NOT deleted),
+			thread_last_reply_at = (SELECT MAX(created_at) FROM group_messages
+				WHERE thread_root_id = root.id AND NOT deleted)
+		WHERE id=$1
+		RETURNING thread_reply_count, thread_last_reply_at`,
+		thread.RootID,
+	).Scan(&thread.ReplyCount, &thread.LastReplyAt)
+	if err != nil {
+		return nil, err
+	}
+	return thread, nil
+}
+
+// GetMessageChat возвращает ID чата, к которому относится сообщение
+func (r *MessageRepository) GetMessageChat(chatType string, messageID int) (int, error) {
+	table, chatColumn, err := messageTable(chatType)
+	if err != nil {
+		return 0, err
+	}
+	var chatID int
+	err = r.DB.QueryRow(`SELECT `+chatColumn+` FROM `+table+` WHERE id=$1`, messageID).Scan(&chatID)
+	if err == sql.ErrNoRows {
+		return 0, ErrMessageNotFound
+	}
+	return chatID, err
+}
+
+// GetEditHistory возвращает прежние версии сообщения, от старых к новым
+func (r *MessageRepository) GetEditHistory(chatType string, messageID int) ([]models.MessageEdit, error) {
+	table, _, err := messageTable(chatType)
+	if err != nil {
+		return nil, err
+	}
+	var deleted bool
+	err = r.DB.QueryRow(`SELECT deleted FROM `+table+` WHERE id=$1`, messageID).Scan(&deleted)
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deleted {
+		return nil, ErrMessageDeleted
+	}
+	rows, err := r.DB.Query(
+		`SELECT id, old_content, edited_at FROM message_edits
+		WHERE chat_type=$1 AND message_id=$2 ORDER BY edited_at ASC, id ASC`,
+		chatType, messageID,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var convs []models.Conversation
+	var edits []models.MessageEdit
 	for rows.Next() {
-		var conv models.Conversation
-		rows.Scan(&conv.ID, &conv.OtherUserID, &conv.OtherUsername, &conv.LastMessage, &conv.CreatedAt)
-		convs = append(convs, conv)
+		var e models.MessageEdit
+		rows.Scan(&e.ID, &e.OldContent, &e.EditedAt)
+		edits = append(edits, e)
 	}
-	return convs, nil
+	return edits, nil
 }
@@ -0,0 +1,116 @@
+package repository
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+// Page — параметры курсорной пагинации истории. Задаётся не больше одного
+// из BeforeID (сообщения старше), AfterID (новее) и AroundID (окно вокруг
+// сообщения, включая его); без них возвращаются последние сообщения.
+type Page struct {
+	BeforeID int
+	AfterID  int
+	AroundID int
+	Limit    int
+}
+
+// pageBounds — диапазон ID страницы и наличие сообщений за её пределами
+type pageBounds struct {
+	fromID, toID int
+	older, newer bool
+}
+
+func (b pageBounds) empty() bool {
+	return b.toID == 0
+}
+
+// cursors возвращает next (before_id для более старых) и prev (after_id для
+// более новых); 0 — в эту сторону сообщений нет
+func (b pageBounds) cursors() (next, prev int) {
+	if b.older {
+		next = b.fromID
+	}
+	if b.newer {
+		prev = b.toID
+	}
+	return next, prev
+}
+
+// pageScope — лента, которую листаем: таблица и условие на $1 (диалог,
+// основная лента группы или одна ветка)
+type pageScope struct {
+	table, where string
+	id           int
+}
+
+// pageBounds сначала выбирает только ID страницы по индексу (chat, id),
+// а затем сами сообщения загружаются одним диапазоном BETWEEN с тем же
+// условием ленты
+func (r *MessageRepository) pageBounds(s pageScope, p Page) (pageBounds, error) {
+	var b pageBounds
+	var err error
+	limit := p.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	if limit > MaxPageLimit {
+		limit = MaxPageLimit
+	}
+
+	base := `SELECT id FROM ` + s.table + ` WHERE ` + s.where
+	var ids []int
+	switch {
+	case p.AroundID != 0:
+		// половина окна — сам AroundID и сообщения до него, остальное после
+		older := (limit + 1) / 2
+		ids, err = r.selectIDs(base+` AND id <= $2 ORDER BY id DESC LIMIT $3`, s.id, p.AroundID, older)
+		if err == nil {
+			var newer []int
+			newer, err = r.selectIDs(base+` AND id > $2 ORDER BY id ASC LIMIT $3`, s.id, p.AroundID, limit-older)
+			ids = append(ids, newer...)
+		}
+	case p.AfterID != 0:
+		ids, err = r.selectIDs(base+` AND id > $2 ORDER BY id ASC LIMIT $3`, s.id, p.AfterID, limit)
+	case p.BeforeID != 0:
+		ids, err = r.selectIDs(base+` AND id < $2 ORDER BY id DESC LIMIT $3`, s.id, p.BeforeID, limit)
+	default:
+		ids, err = r.selectIDs(base+` ORDER BY id DESC LIMIT $2`, s.id, limit)
+	}
+	if err != nil || len(ids) == 0 {
+		return b, err
+	}
+
+	b.fromID, b.toID = ids[0], ids[0]
+	for _, id := range ids {
+		if id < b.fromID {
+			b.fromID = id
+		}
+		if id > b.toID {
+			b.toID = id
+		}
+	}
+	exists := `SELECT EXISTS(SELECT 1 FROM ` + s.table + ` WHERE ` + s.where + ` AND id `
+	if err = r.DB.QueryRow(exists+`< $2)`, s.id, b.fromID).Scan(&b.older); err != nil {
+		return b, err
+	}
+	err = r.DB.QueryRow(exists+`> $2)`, s.id, b.toID).Scan(&b.newer)
+	return b, err
+}
+
+func (r *MessageRepository) selectIDs(query string, args ...interface{}) ([]int, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
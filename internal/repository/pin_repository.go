@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	"your_project/internal/models"
+)
+
+type PinRepository struct {
+	DB *sql.DB
+}
+
+// pinColumns — закреп p, его сообщение m с автором u и закрепивший pu
+const pinColumns = `p.chat_id, m.id, m.sender_id, u.username, m.content,
+			COALESCE(m.media_url,''), COALESCE(m.media_type,''), m.created_at,
+			COALESCE(p.pinned_by, 0), COALESCE(pu.username, ''), p.pinned_at`
+
+// pinJoins — FROM для pinColumns над таблицей сообщений table
+func pinJoins(table string) string {
+	return `FROM pinned_messages p
+		JOIN ` + table + ` m ON m.id = p.message_id
+		JOIN users u ON u.id = m.sender_id
+		LEFT JOIN users pu ON pu.id = p.pinned_by`
+}
+
+func scanPins(rows *sql.Rows) (chatIDs []int, pins []models.PinnedMessage, err error) {
+	defer rows.Close()
+	for rows.Next() {
+		var chatID int
+		var p models.PinnedMessage
+		if err := rows.Scan(&chatID, &p.MessageID, &p.SenderID, &p.SenderUsername, &p.Content,
+			&p.MediaURL, &p.MediaType, &p.CreatedAt,
+			&p.PinnedBy, &p.PinnedByUsername, &p.PinnedAt); err != nil {
+			return nil, nil, err
+		}
+		chatIDs = append(chatIDs, chatID)
+		pins = append(pins, p)
+	}
+	return chatIDs, pins, rows.Err()
+}
+
+func (r *PinRepository) Pin(chatType string, chatID, messageID, userID int) (models.PinnedMessage, error) {
+	table, chatColumn, err := messageTable(chatType)
+	if err != nil {
+		return models.PinnedMessage{}, err
+	}
+	var deleted bool
+	err = r.DB.QueryRow(`SELECT deleted FROM `+table+` WHERE id=$1 AND `+chatColumn+`=$2`, messageID, chatID).
+		Scan(&deleted)
+	if err == sql.ErrNoRows {
+		return models.PinnedMessage{}, ErrMessageNotFound
+	}
+	if err != nil {
+		return models.PinnedMessage{}, err
+	}
+	if deleted {
+		return models.PinnedMessage{}, ErrMessageDeleted
+	}
+
+	if _, err = r.DB.Exec(`
+		INSERT INTO pinned_messages (chat_type, chat_id, message_id, pinned_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (chat_type, message_id) DO UPDATE SET pinned_by = EXCLUDED.pinned_by, pinned_at = NOW()`,
+		chatType, chatID, messageID, userID,
+	); err != nil {
+		return models.PinnedMessage{}, err
+	}
+	rows, err := r.DB.Query(`SELECT `+pinColumns+` `+pinJoins(table)+`
+		WHERE p.chat_type = $1 AND p.message_id = $2`, chatType, messageID)
+	if err != nil {
+		return models.PinnedMessage{}, err
+	}
+	_, pins, err := scanPins(rows)
+	if err != nil {
+		return models.PinnedMessage{}, err
+	}
+	if len(pins) == 0 {
+		// сообщение исчезло вместе с диалогом между вставкой и чтением
+		return models.PinnedMessage{}, ErrMessageNotFound
+	}
+	return pins[0], nil
+}
+
+func (r *PinRepository) Unpin(chatType string, messageID int) (bool, error) {
+	res, err := r.DB.Exec(`DELETE FROM pinned_messages WHERE chat_type=$1 AND message_id=$2`, chatType, messageID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *PinRepository) List(chatType string, chatID int) ([]models.PinnedMessage, error) {
+	table, _, err := messageTable(chatType)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.DB.Query(`SELECT `+pinColumns+` `+pinJoins(table)+`
+		WHERE p.chat_type = $1 AND p.chat_id = $2 AND NOT m.deleted
+		ORDER BY p.pinned_at DESC, p.message_id DESC`, chatType, chatID)
+	if err != nil {
+		return nil, err
+	}
+	_, pins, err := scanPins(rows)
+	return pins, err
+}
+
+func (r *PinRepository) Latest(chatType string, chatIDs []int) (map[int]models.PinnedMessage, error) {
+	latest := make(map[int]models.PinnedMessage)
+	if len(chatIDs) == 0 {
+		return latest, nil
+	}
+	table, _, err := messageTable(chatType)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(chatIDs))
+	for i, id := range chatIDs {
+		ids[i] = int64(id)
+	}
+	rows, err := r.DB.Query(`SELECT DISTINCT ON (p.chat_id) `+pinColumns+` `+pinJoins(table)+`
+		WHERE p.chat_type = $1 AND p.chat_id = ANY($2) AND NOT m.deleted
+		ORDER BY p.chat_id, p.pinned_at DESC, p.message_id DESC`, chatType, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	chats, pins, err := scanPins(rows)
+	if err != nil {
+		return nil, err
+	}
+	for i, chatID := range chats {
+		latest[chatID] = pins[i]
+	}
+	return latest, nil
+}
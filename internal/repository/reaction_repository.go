@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	"your_project/internal/models"
+)
+
+type ReactionRepository struct {
+	DB *sql.DB
+}
+
+func (r *ReactionRepository) Set(chatType string, messageID, userID int, emoji string) (string, error) {
+	table, _, err := messageTable(chatType)
+	if err != nil {
+		return "", err
+	}
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var deleted bool
+	err = tx.QueryRow(`SELECT deleted FROM `+table+` WHERE id=$1`, messageID).Scan(&deleted)
+	if err == sql.ErrNoRows {
+		return "", ErrMessageNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if deleted {
+		return "", ErrMessageDeleted
+	}
+
+	var previous string
+	err = tx.QueryRow(
+		`SELECT emoji FROM message_reactions WHERE chat_type=$1 AND message_id=$2 AND user_id=$3 FOR UPDATE`,
+		chatType, messageID, userID,
+	).Scan(&previous)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	if _, err = tx.Exec(`
+		INSERT INTO message_reactions (chat_type, message_id, user_id, emoji)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (chat_type, message_id, user_id) DO UPDATE SET emoji = EXCLUDED.emoji, created_at = NOW()`,
+		chatType, messageID, userID, emoji,
+	); err != nil {
+		return "", err
+	}
+	return previous, tx.Commit()
+}
+
+func (r *ReactionRepository) Remove(chatType string, messageID, userID int) (string, error) {
+	var emoji string
+	err := r.DB.QueryRow(
+		`DELETE FROM message_reactions WHERE chat_type=$1 AND message_id=$2 AND user_id=$3 RETURNING emoji`,
+		chatType, messageID, userID,
+	).Scan(&emoji)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return emoji, err
+}
+
+func (r *ReactionRepository) Counts(chatType string, messageIDs []int, viewerID int) (map[int][]models.ReactionCount, error) {
+	counts := make(map[int][]models.ReactionCount)
+	if len(messageIDs) == 0 {
+		return counts, nil
+	}
+	ids := make([]int64, len(messageIDs))
+	for i, id := range messageIDs {
+		ids[i] = int64(id)
+	}
+	rows, err := r.DB.Query(`
+		SELECT message_id, emoji, COUNT(*), BOOL_OR(user_id = $3)
+		FROM message_reactions
+		WHERE chat_type = $1 AND message_id = ANY($2)
+		GROUP BY message_id, emoji
+		ORDER BY message_id, MIN(created_at), emoji`,
+		chatType, pq.Array(ids), viewerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var messageID int
+		var c models.ReactionCount
+		if err := rows.Scan(&messageID, &c.Emoji, &c.Count, &c.ReactedByMe); err != nil {
+			return nil, err
+		}
+		counts[messageID] = append(counts[messageID], c)
+	}
+	return counts, rows.Err()
+}
+
+func (r *ReactionRepository) List(chatType string, messageID int) ([]models.Reaction, error) {
+	rows, err := r.DB.Query(`
+		SELECT mr.user_id, u.username, mr.emoji, mr.created_at
+		FROM message_reactions mr
+		JOIN users u ON u.id = mr.user_id
+		WHERE mr.chat_type = $1 AND mr.message_id = $2
+		ORDER BY mr.created_at ASC, mr.user_id ASC`,
+		chatType, messageID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var reactions []models.Reaction
+	for rows.Next() {
+		var rc models.Reaction
+		if err := rows.Scan(&rc.UserID, &rc.Username, &rc.Emoji, &rc.CreatedAt); err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, rc)
+	}
+	return reactions, rows.Err()
+}
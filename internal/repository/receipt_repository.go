@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"your_project/internal/models"
+)
+
+var ErrNotChatMember = errors.New("пользователь не состоит в чате")
+
+type ReceiptRepository struct {
+	DB *sql.DB
+}
+
+// memberTable возвращает таблицу участников для типа чата
+func memberTable(chatType string) (string, error) {
+	switch chatType {
+	case models.ChatDirect:
+		return "conversation_members", nil
+	case models.ChatGroup:
+		return "group_members", nil
+	}
+	return "", fmt.Errorf("неизвестный тип чата %q", chatType)
+}
+
+// Mark сдвигает отметку доставки (read=false) или прочтения (read=true)
+// участника до upToID. Отметки только растут и не выходят за последнее
+// сообщение чата; прочтение заодно означает доставку. Возвращает новую
+// отметку и признак того, что она сдвинулась.
+func (r *ReceiptRepository) Mark(chatType string, chatID, userID, upToID int, read bool) (int, bool, error) {
+	members, err := memberTable(chatType)
+	if err != nil {
+		return 0, false, err
+	}
+	messages, chatColumn, _ := messageTable(chatType)
+
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	var delivered, readUpTo int
+	err = tx.QueryRow(
+		`SELECT last_delivered_message_id, last_read_message_id FROM `+members+`
+		WHERE `+chatColumn+`=$1 AND user_id=$2 FOR UPDATE`,
+		chatID, userID,
+	).Scan(&delivered, &readUpTo)
+	if err == sql.ErrNoRows {
+		return 0, false, ErrNotChatMember
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	var lastID int
+	if err = tx.QueryRow(
+		`SELECT COALESCE(MAX(id), 0) FROM `+messages+` WHERE `+chatColumn+`=$1`, chatID,
+	).Scan(&lastID); err != nil {
+		return 0, false, err
+	}
+	if upToID > lastID {
+		upToID = lastID
+	}
+
+	var mark int
+	changed := false
+	if read {
+		if upToID > readUpTo {
+			readUpTo, changed = upToID, true
+		}
+		if readUpTo > delivered {
+			delivered = readUpTo
+		}
+		mark = readUpTo
+	} else {
+		if upToID > delivered {
+			delivered, changed = upToID, true
+		}
+		mark = delivered
+	}
+	if !changed {
+		return mark, false, nil
+	}
+
+	if _, err = tx.Exec(
+		`UPDATE `+members+` SET last_delivered_message_id=$1, last_read_message_id=$2
+		WHERE `+chatColumn+`=$3 AND user_id=$4`,
+		delivered, readUpTo, chatID, userID,
+	); err != nil {
+		return 0, false, err
+	}
+	return mark, true, tx.Commit()
+}
+
+// GetGroupReceipt считает, сколько участников группы (кроме отправителя)
+// получили и прочитали сообщение
+func (r *ReceiptRepository) GetGroupReceipt(groupID, messageID int) (models.GroupReceipt, error) {
+	receipt := models.GroupReceipt{MessageID: messageID, ReadBy: []models.ReceiptUser{}}
+
+	var senderID int
+	err := r.DB.QueryRow(
+		`SELECT sender_id FROM group_messages WHERE id=$1 AND group_id=$2`, messageID, groupID,
+	).Scan(&senderID)
+	if err == sql.ErrNoRows {
+		return receipt, ErrMessageNotFound
+	}
+	if err != nil {
+		return receipt, err
+	}
+
+	rows, err := r.DB.Query(`
+		SELECT u.id, u.username, gm.last_delivered_message_id >= $2, gm.last_read_message_id >= $2
+		FROM group_members gm
+		JOIN users u ON gm.user_id = u.id
+		WHERE gm.group_id = $1 AND gm.user_id != $3`,
+		groupID, messageID, senderID,
+	)
+	if err != nil {
+		return receipt, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u models.ReceiptUser
+		var delivered, read bool
+		rows.Scan(&u.ID, &u.Username, &delivered, &read)
+		receipt.MemberCount++
+		if delivered {
+			receipt.DeliveredCount++
+		}
+		if read {
+			receipt.ReadCount++
+			receipt.ReadBy = append(receipt.ReadBy, u)
+		}
+	}
+	return receipt, nil
+}
@@ -0,0 +1,895 @@
+// Package repotest — общий контракт хранилищ repository. Один и тот же
+// набор проверок гоняется и над PostgreSQL, и над memory, чтобы обе
+// реализации вели себя одинаково: те же ошибки, порядок и правила.
+package repotest
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"your_project/internal/models"
+	"your_project/internal/repository"
+)
+
+// Open возвращает хранилища для одного подтеста. Пустая база не нужна:
+// имена пользователей уникальны, а проверки смотрят только на свои данные.
+type Open func(t *testing.T) *repository.Repositories
+
+// Run прогоняет контракт над хранилищами, которые возвращает open
+func Run(t *testing.T, open Open) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, open(t)) })
+	t.Run("AccountSettings", func(t *testing.T) { testAccountSettings(t, open(t)) })
+	t.Run("LoginAttempts", func(t *testing.T) { testLoginAttempts(t, open(t)) })
+	t.Run("Conversations", func(t *testing.T) { testConversations(t, open(t)) })
+	t.Run("Messages", func(t *testing.T) { testMessages(t, open(t)) })
+	t.Run("Pagination", func(t *testing.T) { testPagination(t, open(t)) })
+	t.Run("Threads", func(t *testing.T) { testThreads(t, open(t)) })
+	t.Run("ForwardSources", func(t *testing.T) { testForwardSources(t, open(t)) })
+	t.Run("Groups", func(t *testing.T) { testGroups(t, open(t)) })
+	t.Run("Receipts", func(t *testing.T) { testReceipts(t, open(t)) })
+	t.Run("Events", func(t *testing.T) { testEvents(t, open(t)) })
+	t.Run("Blocks", func(t *testing.T) { testBlocks(t, open(t)) })
+	t.Run("Reactions", func(t *testing.T) { testReactions(t, open(t)) })
+	t.Run("Pins", func(t *testing.T) { testPins(t, open(t)) })
+}
+
+var userSeq = time.Now().UnixNano() % 1e9
+
+// NewUser создаёт пользователя с уникальным именем на основе name
+func NewUser(t *testing.T, r *repository.Repositories, name string) (int, string) {
+	t.Helper()
+	username := fmt.Sprintf("%s_%d", name, atomic.AddInt64(&userSeq, 1))
+	id, err := r.Users.CreateUser(models.User{Username: username, Password: "hash", DisplayName: name, UserTag: username})
+	if err != nil {
+		t.Fatalf("CreateUser(%s): %v", username, err)
+	}
+	return id, username
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wantErr(t *testing.T, what string, got, want error) {
+	t.Helper()
+	if got != want {
+		t.Fatalf("%s: ошибка %v, ждали %v", what, got, want)
+	}
+}
+
+func testUsers(t *testing.T, r *repository.Repositories) {
+	id, username := NewUser(t, r, "alice")
+
+	u, err := r.Users.GetUserByUsername(username)
+	must(t, err)
+	if u.ID != id || u.Password != "hash" {
+		t.Fatalf("GetUserByUsername = %+v", u)
+	}
+	u, err = r.Users.GetUserByID(id)
+	must(t, err)
+	if u.Username != username {
+		t.Fatalf("GetUserByID = %+v", u)
+	}
+
+	_, err = r.Users.GetUserByID(-1)
+	wantErr(t, "GetUserByID неизвестного", err, sql.ErrNoRows)
+	_, err = r.Users.GetUserByUsername(username + "_nobody")
+	wantErr(t, "GetUserByUsername неизвестного", err, sql.ErrNoRows)
+
+	if _, err := r.Users.CreateUser(models.User{Username: username, Password: "hash"}); err == nil {
+		t.Fatal("CreateUser с занятым именем прошёл")
+	}
+
+	otherID, otherName := NewUser(t, r, "bob")
+	wantErr(t, "SetUsername на занятое", r.Users.SetUsername(otherID, username), repository.ErrUsernameTaken)
+	must(t, r.Users.SetUsername(otherID, otherName+"x"))
+
+	// неподтверждённый адрес могут указать несколько аккаунтов: заявка не
+	// занимает его, пока не подтверждена
+	email := username + "@example.com"
+	must(t, r.Users.SetEmail(otherID, strings.ToUpper(email)))
+	must(t, r.Users.SetEmail(id, email))
+
+	_, err = r.Users.GetUserByVerifiedEmail(email)
+	wantErr(t, "GetUserByVerifiedEmail до подтверждения", err, sql.ErrNoRows)
+	ok, err := r.Users.MarkEmailVerified(id, email)
+	must(t, err)
+	if !ok {
+		t.Fatal("MarkEmailVerified не подтвердил текущий адрес")
+	}
+	u, err = r.Users.GetUserByVerifiedEmail(email)
+	must(t, err)
+	if u.ID != id {
+		t.Fatalf("GetUserByVerifiedEmail = %+v", u)
+	}
+	// подтверждение снимает чужую заявку, а повторная упирается в занятость
+	p, err := r.Users.GetProfile(otherID)
+	must(t, err)
+	if p.Email != "" {
+		t.Fatalf("неподтверждённая заявка на подтверждённый адрес осталась: %q", p.Email)
+	}
+	must(t, r.Users.SetEmail(otherID, email))
+	_, err = r.Users.MarkEmailVerified(otherID, email)
+	wantErr(t, "MarkEmailVerified подтверждённого другим", err, repository.ErrEmailTaken)
+
+	must(t, r.Users.UpdateProfile(id, "Alice", "bio", "/a.png"))
+	p, err = r.Users.GetProfile(id)
+	must(t, err)
+	if p.DisplayName != "Alice" || p.Bio != "bio" || p.Email != email || !p.EmailVerified || p.Password != "" {
+		t.Fatalf("GetProfile = %+v", p)
+	}
+}
+
+// dueForDeletion — есть ли id среди аккаунтов, чей срок отмены истёк к before
+func dueForDeletion(t *testing.T, r *repository.Repositories, id int, before time.Time) bool {
+	t.Helper()
+	ids, err := r.Users.DueForDeletion(before)
+	must(t, err)
+	for _, due := range ids {
+		if due == id {
+			return true
+		}
+	}
+	return false
+}
+
+func testAccountSettings(t *testing.T, r *repository.Repositories) {
+	id, username := NewUser(t, r, "settings")
+	other, _ := NewUser(t, r, "other")
+
+	must(t, r.Users.SetPassword(id, "new-hash"))
+	u, err := r.Users.GetUserByID(id)
+	must(t, err)
+	if u.Password != "new-hash" {
+		t.Fatalf("пароль после SetPassword = %q", u.Password)
+	}
+
+	// запрос на удаление идемпотентен: срок считается от первого запроса
+	at, err := r.Users.RequestDeletion(id)
+	must(t, err)
+	again, err := r.Users.RequestDeletion(id)
+	must(t, err)
+	if !again.Equal(at) {
+		t.Fatalf("повторный RequestDeletion сдвинул срок: %v, было %v", again, at)
+	}
+	if dueForDeletion(t, r, id, at.Add(-time.Second)) || !dueForDeletion(t, r, id, at.Add(time.Second)) {
+		t.Fatal("DueForDeletion не учитывает время запроса")
+	}
+
+	cancelled, err := r.Users.CancelDeletion(id)
+	must(t, err)
+	if !cancelled {
+		t.Fatal("CancelDeletion не отменил запрос")
+	}
+	if cancelled, err = r.Users.CancelDeletion(id); err != nil || cancelled {
+		t.Fatalf("повторный CancelDeletion = %v, %v", cancelled, err)
+	}
+	if dueForDeletion(t, r, id, at.Add(time.Hour)) {
+		t.Fatal("отменённое удаление осталось в DueForDeletion")
+	}
+
+	// обезличивание: связи и адрес стираются, имя освобождается
+	group, _, err := r.Groups.Create("удаление", "", other, []int{id})
+	must(t, err)
+	must(t, r.Blocks.Block(other, id))
+	email := username + "@example.com"
+	must(t, r.Users.SetEmail(id, email))
+	_, err = r.Users.MarkEmailVerified(id, email)
+	must(t, err)
+	_, err = r.Users.RequestDeletion(id)
+	must(t, err)
+
+	must(t, r.Users.SoftDelete(id))
+	u, err = r.Users.GetUserByID(id)
+	must(t, err)
+	if u.Username != fmt.Sprintf("deleted_%d", id) || u.Password != "" {
+		t.Fatalf("после SoftDelete = %+v", u)
+	}
+	_, err = r.Users.GetUserByUsername(username)
+	wantErr(t, "GetUserByUsername удалённого", err, sql.ErrNoRows)
+	_, err = r.Users.GetUserByVerifiedEmail(email)
+	wantErr(t, "GetUserByVerifiedEmail удалённого", err, sql.ErrNoRows)
+	members, err := r.Groups.Members(group)
+	must(t, err)
+	if len(members) != 1 || members[0].ID != other {
+		t.Fatalf("участники группы после SoftDelete = %+v", members)
+	}
+	blocked, err := r.Blocks.IsBlockedBetween(other, id)
+	must(t, err)
+	if blocked {
+		t.Fatal("блокировка пережила SoftDelete")
+	}
+	if dueForDeletion(t, r, id, time.Now().Add(time.Hour)) {
+		t.Fatal("удалённый аккаунт остался в DueForDeletion")
+	}
+	_, err = r.Users.RequestDeletion(id)
+	wantErr(t, "RequestDeletion удалённого", err, sql.ErrNoRows)
+	if cancelled, err = r.Users.CancelDeletion(id); err != nil || cancelled {
+		t.Fatalf("CancelDeletion удалённого = %v, %v", cancelled, err)
+	}
+	must(t, r.Users.SetUsername(other, username))
+}
+
+func testLoginAttempts(t *testing.T, r *repository.Repositories) {
+	id, username := NewUser(t, r, "attempts")
+	for _, reason := range []string{models.LoginBadPassword, models.LoginLocked} {
+		must(t, r.LoginAttempts.Record(models.LoginAttempt{UserID: &id, Username: username, IP: "10.0.0.1", Reason: reason}))
+	}
+	// без пользователя журнал не пишется: его некому показать
+	if err := r.LoginAttempts.Record(models.LoginAttempt{Username: "nobody", Reason: models.LoginBadPassword}); err == nil {
+		t.Fatal("Record без user_id прошёл")
+	}
+
+	list, err := r.LoginAttempts.ListFailed(id, 10)
+	must(t, err)
+	if len(list) != 2 || list[0].Reason != models.LoginLocked || list[0].IP != "10.0.0.1" {
+		t.Fatalf("ListFailed = %+v", list)
+	}
+	if list, _ = r.LoginAttempts.ListFailed(id, 1); len(list) != 1 {
+		t.Fatalf("ListFailed с limit 1 = %d записей", len(list))
+	}
+
+	n, err := r.LoginAttempts.Prune(time.Now().Add(-time.Hour))
+	must(t, err)
+	if list, _ = r.LoginAttempts.ListFailed(id, 10); len(list) != 2 {
+		t.Fatalf("Prune старых удалил свежие: осталось %d, удалено %d", len(list), n)
+	}
+	n, err = r.LoginAttempts.Prune(time.Now().Add(time.Hour))
+	must(t, err)
+	if list, _ = r.LoginAttempts.ListFailed(id, 10); len(list) != 0 || n < 2 {
+		t.Fatalf("Prune всех: осталось %d, удалено %d", len(list), n)
+	}
+}
+
+func testConversations(t *testing.T, r *repository.Repositories) {
+	a, _ := NewUser(t, r, "a")
+	b, _ := NewUser(t, r, "b")
+	c, _ := NewUser(t, r, "c")
+
+	conv, err := r.Conversations.GetOrCreate(a, b)
+	must(t, err)
+	again, err := r.Conversations.GetOrCreate(b, a)
+	must(t, err)
+	if again != conv {
+		t.Fatalf("GetOrCreate в обратном порядке создал новый диалог %d, был %d", again, conv)
+	}
+
+	for _, tc := range []struct {
+		user int
+		want bool
+	}{{a, true}, {b, true}, {c, false}} {
+		got, err := r.Conversations.IsMember(conv, tc.user)
+		must(t, err)
+		if got != tc.want {
+			t.Fatalf("IsMember(%d) = %v, ждали %v", tc.user, got, tc.want)
+		}
+	}
+
+	ids, err := r.Conversations.MemberIDs(conv, a)
+	must(t, err)
+	if len(ids) != 1 || ids[0] != b {
+		t.Fatalf("MemberIDs без a = %v", ids)
+	}
+
+	list, err := r.Conversations.ListForUser(a)
+	must(t, err)
+	if len(list) != 1 || list[0].ID != conv || list[0].OtherUserID != b {
+		t.Fatalf("ListForUser = %+v", list)
+	}
+
+	contacts, err := r.Conversations.ContactIDs(a)
+	must(t, err)
+	if len(contacts) != 1 || contacts[0] != b {
+		t.Fatalf("ContactIDs = %v", contacts)
+	}
+	must(t, r.Blocks.Block(b, a))
+	blocked, err := r.Conversations.HasBlockedMember(conv, a)
+	must(t, err)
+	if !blocked {
+		t.Fatal("HasBlockedMember не видит блокировку собеседника")
+	}
+	contacts, err = r.Conversations.ContactIDs(a)
+	must(t, err)
+	if len(contacts) != 0 {
+		t.Fatalf("ContactIDs с блокировкой = %v", contacts)
+	}
+
+	must(t, r.Conversations.Leave(conv, a))
+	if ok, _ := r.Conversations.IsMember(conv, a); ok {
+		t.Fatal("после Leave пользователь остался в диалоге")
+	}
+}
+
+func testMessages(t *testing.T, r *repository.Repositories) {
+	a, aName := NewUser(t, r, "a")
+	b, _ := NewUser(t, r, "b")
+	conv, err := r.Conversations.GetOrCreate(a, b)
+	must(t, err)
+
+	first, err := r.Messages.SaveMessage(models.Message{ConversationID: conv, SenderID: a, Content: "привет"})
+	must(t, err)
+	if first.ID == 0 || first.CreatedAt.IsZero() {
+		t.Fatalf("SaveMessage не выдал ID и время: %+v", first)
+	}
+	reply, err := r.Messages.SaveMessage(models.Message{ConversationID: conv, SenderID: b, Content: "и тебе", ReplyToID: first.ID})
+	must(t, err)
+
+	msgs, err := r.Messages.GetMessages(conv)
+	must(t, err)
+	if len(msgs) != 2 || msgs[0].ID != first.ID || msgs[1].ReplyTo == nil || msgs[1].ReplyTo.SenderUsername != aName {
+		t.Fatalf("GetMessages = %+v", msgs)
+	}
+
+	preview, err := r.Messages.ReplyPreview(models.ChatDirect, conv, first.ID)
+	must(t, err)
+	if preview.ID != first.ID || preview.Snippet != "привет" {
+		t.Fatalf("ReplyPreview = %+v", preview)
+	}
+	_, err = r.Messages.ReplyPreview(models.ChatDirect, conv+1000000, first.ID)
+	wantErr(t, "ReplyPreview из чужого чата", err, repository.ErrMessageNotFound)
+
+	chatID, err := r.Messages.GetMessageChat(models.ChatDirect, first.ID)
+	must(t, err)
+	if chatID != conv {
+		t.Fatalf("GetMessageChat = %d, ждали %d", chatID, conv)
+	}
+
+	_, err = r.Messages.EditMessage(models.ChatDirect, first.ID, b, "чужое")
+	wantErr(t, "EditMessage чужого", err, repository.ErrNotMessageSender)
+	_, err = r.Messages.EditMessage(models.ChatDirect, -1, a, "нет")
+	wantErr(t, "EditMessage несуществующего", err, repository.ErrMessageNotFound)
+
+	edited, err := r.Messages.EditMessage(models.ChatDirect, first.ID, a, "привет!")
+	must(t, err)
+	if edited.ChatID != conv || edited.ThreadRootID != 0 || edited.EditedAt.IsZero() {
+		t.Fatalf("EditMessage = %+v", edited)
+	}
+	edits, err := r.Messages.GetEditHistory(models.ChatDirect, first.ID)
+	must(t, err)
+	if len(edits) != 1 || edits[0].OldContent != "привет" {
+		t.Fatalf("GetEditHistory = %+v", edits)
+	}
+
+	_, _, err = r.Messages.DeleteMessage(models.ChatDirect, first.ID, b)
+	wantErr(t, "DeleteMessage чужого", err, repository.ErrNotMessageSender)
+	deletedChat, thread, err := r.Messages.DeleteMessage(models.ChatDirect, first.ID, a)
+	must(t, err)
+	if deletedChat != conv || thread != nil {
+		t.Fatalf("DeleteMessage = %d, %+v", deletedChat, thread)
+	}
+	_, err = r.Messages.GetEditHistory(models.ChatDirect, first.ID)
+	wantErr(t, "GetEditHistory удалённого", err, repository.ErrMessageDeleted)
+	_, err = r.Messages.GetEditHistory(models.ChatDirect, -1)
+	wantErr(t, "GetEditHistory несуществующего", err, repository.ErrMessageNotFound)
+	_, err = r.Messages.EditMessage(models.ChatDirect, first.ID, a, "снова")
+	wantErr(t, "EditMessage удалённого", err, repository.ErrMessageDeleted)
+
+	msgs, err = r.Messages.GetMessages(conv)
+	must(t, err)
+	if !msgs[0].Deleted || msgs[0].Content != "" {
+		t.Fatalf("удалённое сообщение = %+v", msgs[0])
+	}
+	if msgs[1].ID != reply.ID || msgs[1].ReplyTo == nil || !msgs[1].ReplyTo.Deleted {
+		t.Fatalf("цитата удалённого = %+v", msgs[1].ReplyTo)
+	}
+}
+
+func testPagination(t *testing.T, r *repository.Repositories) {
+	a, _ := NewUser(t, r, "a")
+	b, _ := NewUser(t, r, "b")
+	conv, err := r.Conversations.GetOrCreate(a, b)
+	must(t, err)
+	var ids []int
+	for i := 0; i < 5; i++ {
+		m, err := r.Messages.SaveMessage(models.Message{ConversationID: conv, SenderID: a, Content: fmt.Sprint(i)})
+		must(t, err)
+		ids = append(ids, m.ID)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		page       repository.Page
+		want       []int
+		next, prev int
+	}{
+		{"последние", repository.Page{Limit: 2}, ids[3:5], ids[3], 0},
+		{"до", repository.Page{BeforeID: ids[3], Limit: 2}, ids[1:3], ids[1], ids[2]},
+		{"после", repository.Page{AfterID: ids[0], Limit: 2}, ids[1:3], ids[1], ids[2]},
+		{"вокруг", repository.Page{AroundID: ids[2], Limit: 3}, ids[1:4], ids[1], ids[3]},
+		{"до первого", repository.Page{BeforeID: ids[0]}, nil, 0, 0},
+	} {
+		page, err := r.Messages.GetMessagesPage(conv, tc.page)
+		must(t, err)
+		var got []int
+		for _, m := range page.Messages {
+			got = append(got, m.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) || page.NextCursor != tc.next || page.PrevCursor != tc.prev {
+			t.Errorf("%s: %v next=%d prev=%d, ждали %v next=%d prev=%d",
+				tc.name, got, page.NextCursor, page.PrevCursor, tc.want, tc.next, tc.prev)
+		}
+	}
+}
+
+func testThreads(t *testing.T, r *repository.Repositories) {
+	a, _ := NewUser(t, r, "a")
+	b, _ := NewUser(t, r, "b")
+	group, _, err := r.Groups.Create("ветки", "", a, []int{b})
+	must(t, err)
+	root, err := r.Messages.SaveGroupMessage(models.GroupMessage{GroupID: group, SenderID: a, Content: "корень"})
+	must(t, err)
+
+	var replies []models.GroupMessage
+	for i, sender := range []int{b, a} {
+		reply, count, err := r.Messages.SaveThreadReply(models.GroupMessage{GroupID: group, SenderID: sender, Content: "ответ", ThreadRootID: root.ID})
+		must(t, err)
+		if count != i+1 {
+			t.Fatalf("SaveThreadReply вернул счётчик %d, ждали %d", count, i+1)
+		}
+		replies = append(replies, reply)
+	}
+
+	_, _, err = r.Messages.SaveThreadReply(models.GroupMessage{GroupID: group, SenderID: a, ThreadRootID: replies[0].ID})
+	wantErr(t, "ветка от ответа", err, repository.ErrThreadNotFound)
+	_, _, err = r.Messages.SaveThreadReply(models.GroupMessage{GroupID: group + 1000000, SenderID: a, ThreadRootID: root.ID})
+	wantErr(t, "ветка в чужой группе", err, repository.ErrThreadNotFound)
+
+	page, err := r.Messages.GetThreadPage(group, root.ID, repository.Page{})
+	must(t, err)
+	if page.Root.ThreadReplyCount != 2 || page.Root.ThreadLastReplyAt == nil || len(page.Messages) != 2 {
+		t.Fatalf("GetThreadPage = %+v", page)
+	}
+	main, err := r.Messages.GetGroupMessagesPage(group, repository.Page{})
+	must(t, err)
+	if len(main.Messages) != 1 || main.Messages[0].ID != root.ID {
+		t.Fatalf("ответы ветки попали в основную ленту: %+v", main.Messages)
+	}
+
+	ids, err := r.Messages.ThreadParticipantIDs(root.ID, a)
+	must(t, err)
+	if len(ids) != 1 || ids[0] != b {
+		t.Fatalf("ThreadParticipantIDs = %v", ids)
+	}
+
+	// внутри ветки отвечать можно на её корень и ответы, но не на сообщение
+	// основной ленты или другой ветки той же группы
+	other, err := r.Messages.SaveGroupMessage(models.GroupMessage{GroupID: group, SenderID: b, Content: "другой корень"})
+	must(t, err)
+	otherReply, _, err := r.Messages.SaveThreadReply(models.GroupMessage{GroupID: group, SenderID: b, Content: "чужая ветка", ThreadRootID: other.ID})
+	must(t, err)
+	for _, to := range []int{root.ID, replies[0].ID} {
+		_, _, err = r.Messages.SaveThreadReply(models.GroupMessage{GroupID: group, SenderID: a, Content: "цитата", ThreadRootID: root.ID, ReplyToID: to})
+		must(t, err)
+	}
+	for _, to := range []int{other.ID, otherReply.ID} {
+		_, _, err = r.Messages.SaveThreadReply(models.GroupMessage{GroupID: group, SenderID: a, Content: "цитата", ThreadRootID: root.ID, ReplyToID: to})
+		wantErr(t, "ответ на сообщение вне ветки", err, repository.ErrMessageNotFound)
+	}
+
+	// правка сообщает корень ветки, чтобы клиент применил её в ветке
+	edited, err := r.Messages.EditMessage(models.ChatGroup, otherReply.ID, b, "правка в ветке")
+	must(t, err)
+	if edited.ChatID != group || edited.ThreadRootID != other.ID {
+		t.Fatalf("EditMessage ответа = %+v, ждали корень %d", edited, other.ID)
+	}
+	edited, err = r.Messages.EditMessage(models.ChatGroup, other.ID, b, "правка корня")
+	must(t, err)
+	if edited.ThreadRootID != 0 {
+		t.Fatalf("EditMessage корня = %+v", edited)
+	}
+
+	// удаление ответа пересчитывает счётчики по оставшимся
+	page, err = r.Messages.GetThreadPage(group, root.ID, repository.Page{})
+	must(t, err)
+	count, last := page.Root.ThreadReplyCount, page.Messages[len(page.Messages)-1]
+	if count != 4 {
+		t.Fatalf("ответов в ветке %d, ждали 4", count)
+	}
+	_, thread, err := r.Messages.DeleteMessage(models.ChatGroup, last.ID, last.SenderID)
+	must(t, err)
+	prev := page.Messages[len(page.Messages)-2]
+	if thread == nil || thread.RootID != root.ID || thread.ReplyCount != count-1 ||
+		thread.LastReplyAt == nil || !thread.LastReplyAt.Equal(prev.CreatedAt) {
+		t.Fatalf("DeleteMessage ответа = %+v, ждали %d ответов и последний в %v", thread, count-1, prev.CreatedAt)
+	}
+	_, _, err = r.Messages.DeleteMessage(models.ChatGroup, last.ID, last.SenderID)
+	wantErr(t, "повторное удаление ответа", err, repository.ErrMessageDeleted)
+	page, err = r.Messages.GetThreadPage(group, root.ID, repository.Page{})
+	must(t, err)
+	if page.Root.ThreadReplyCount != count-1 || !page.Root.ThreadLastReplyAt.Equal(prev.CreatedAt) {
+		t.Fatalf("корень после удаления ответа = %+v", page.Root)
+	}
+	for _, m := range page.Messages[:len(page.Messages)-1] {
+		_, _, err = r.Messages.DeleteMessage(models.ChatGroup, m.ID, m.SenderID)
+		must(t, err)
+	}
+	page, err = r.Messages.GetThreadPage(group, root.ID, repository.Page{})
+	must(t, err)
+	if page.Root.ThreadReplyCount != 0 || page.Root.ThreadLastReplyAt != nil {
+		t.Fatalf("корень без ответов = %+v", page.Root)
+	}
+	_, thread, err = r.Messages.DeleteMessage(models.ChatGroup, root.ID, a)
+	must(t, err)
+	if thread != nil {
+		t.Fatalf("удаление корня вернуло счётчики %+v", thread)
+	}
+}
+
+func testForwardSources(t *testing.T, r *repository.Repositories) {
+	a, aName := NewUser(t, r, "a")
+	b, _ := NewUser(t, r, "b")
+	conv, err := r.Conversations.GetOrCreate(a, b)
+	must(t, err)
+	first, err := r.Messages.SaveMessage(models.Message{ConversationID: conv, SenderID: a, Content: "один"})
+	must(t, err)
+	second, err := r.Messages.SaveMessage(models.Message{ConversationID: conv, SenderID: b, Content: "два"})
+	must(t, err)
+
+	sources, err := r.Messages.ForwardSources(models.ChatDirect, conv, []int{second.ID, first.ID})
+	must(t, err)
+	if len(sources) != 2 || sources[0].ID != first.ID || sources[1].ID != second.ID {
+		t.Fatalf("ForwardSources не по возрастанию ID: %+v", sources)
+	}
+	if f := sources[0].From; f.SenderID != a || f.SenderUsername != aName || f.MessageID != first.ID {
+		t.Fatalf("атрибуция = %+v", f)
+	}
+	_, err = r.Messages.ForwardSources(models.ChatDirect, conv, []int{first.ID, -1})
+	wantErr(t, "ForwardSources с чужим ID", err, repository.ErrMessageNotFound)
+
+	// переслать пересланное — первоисточник сохраняется
+	group, _, err := r.Groups.Create("пересылка", "", b, nil)
+	must(t, err)
+	copied, err := r.Messages.SaveGroupMessage(models.GroupMessage{GroupID: group, SenderID: b, Content: "один", ForwardedFrom: &sources[0].From})
+	must(t, err)
+	again, err := r.Messages.ForwardSources(models.ChatGroup, group, []int{copied.ID})
+	must(t, err)
+	if f := again[0].From; f.SenderID != a || f.ChatType != models.ChatDirect || f.MessageID != first.ID {
+		t.Fatalf("атрибуция пересланного повторно = %+v", f)
+	}
+
+	copies, err := r.Messages.SaveForwarded(models.ChatGroup, group, b, sources)
+	must(t, err)
+	if len(copies) != 2 || copies[0].ID >= copies[1].ID || copies[0].CreatedAt.IsZero() {
+		t.Fatalf("SaveForwarded = %+v", copies)
+	}
+	saved, err := r.Messages.GetGroupMessages(group)
+	must(t, err)
+	if len(saved) != 3 || saved[1].ID != copies[0].ID || saved[1].Content != "один" ||
+		saved[2].ForwardedFrom == nil || saved[2].ForwardedFrom.MessageID != second.ID {
+		t.Fatalf("копии в группе = %+v", saved)
+	}
+
+	// копия с несуществующим автором оригинала срывает сохранение всех
+	broken := append([]repository.ForwardSource{}, sources...)
+	broken[1].From.SenderID = -1
+	_, err = r.Messages.SaveForwarded(models.ChatGroup, group, b, broken)
+	if err == nil {
+		t.Fatal("SaveForwarded с несуществующим автором без ошибки")
+	}
+	saved, err = r.Messages.GetGroupMessages(group)
+	must(t, err)
+	if len(saved) != 3 {
+		t.Fatalf("после ошибки SaveForwarded сохранилась часть копий: %d сообщений", len(saved))
+	}
+}
+
+func testGroups(t *testing.T, r *repository.Repositories) {
+	admin, _ := NewUser(t, r, "admin")
+	member, _ := NewUser(t, r, "member")
+	stranger, _ := NewUser(t, r, "stranger")
+
+	group, joined, err := r.Groups.Create("группа", "", admin, []int{member, member})
+	must(t, err)
+	if len(joined) != 2 || joined[0] != admin || joined[1] != member {
+		t.Fatalf("Create вернул участников %v", joined)
+	}
+
+	for _, tc := range []struct {
+		user int
+		role string
+		err  error
+	}{
+		{admin, "admin", nil},
+		{member, "member", nil},
+		{stranger, "", repository.ErrNotChatMember},
+	} {
+		role, err := r.Groups.Role(group, tc.user)
+		if role != tc.role || err != tc.err {
+			t.Fatalf("Role(%d) = %q, %v; ждали %q, %v", tc.user, role, err, tc.role, tc.err)
+		}
+	}
+
+	members, err := r.Groups.Members(group)
+	must(t, err)
+	if len(members) != 2 || members[0].ID != admin {
+		t.Fatalf("Members — админ не первым: %+v", members)
+	}
+
+	added, err := r.Groups.AddMember(group, stranger, "member")
+	must(t, err)
+	again, err := r.Groups.AddMember(group, stranger, "member")
+	must(t, err)
+	if !added || again {
+		t.Fatalf("AddMember = %v, повторно %v", added, again)
+	}
+	ids, err := r.Groups.MemberIDs(group, admin)
+	must(t, err)
+	if fmt.Sprint(ids) != fmt.Sprint([]int{member, stranger}) {
+		t.Fatalf("MemberIDs = %v", ids)
+	}
+
+	removed, newAdmin, err := r.Groups.RemoveMember(group, stranger)
+	must(t, err)
+	again, _, err = r.Groups.RemoveMember(group, stranger)
+	must(t, err)
+	if !removed || again || newAdmin != 0 {
+		t.Fatalf("RemoveMember = %v (новый админ %d), повторно %v", removed, newAdmin, again)
+	}
+
+	must(t, r.Groups.Update(group, "новое имя", "/g.png"))
+	info, err := r.Groups.Get(group)
+	must(t, err)
+	if info.Name != "новое имя" || info.AvatarURL != "/g.png" || info.CreatedBy != admin {
+		t.Fatalf("Get = %+v", info)
+	}
+	_, err = r.Groups.Get(-1)
+	wantErr(t, "Get несуществующей", err, sql.ErrNoRows)
+
+	list, err := r.Groups.ListForUser(member)
+	must(t, err)
+	if len(list) != 1 || list[0].ID != group || list[0].MemberCount != 2 {
+		t.Fatalf("ListForUser = %+v", list)
+	}
+
+	// последний админ, уходя, передаёт права вошедшему раньше остальных
+	// (при равном времени входа — с меньшим ID)
+	second, _ := NewUser(t, r, "g2")
+	third, _ := NewUser(t, r, "g3")
+	late, _ := NewUser(t, r, "g4")
+	group, _, err = r.Groups.Create("преемник", "", admin, []int{second, third})
+	must(t, err)
+	_, err = r.Groups.AddMember(group, late, "admin")
+	must(t, err)
+	for _, step := range []struct {
+		leaving, newAdmin int
+	}{
+		{admin, 0}, // админ late остаётся
+		{late, second},
+		{third, 0},
+		{second, 0}, // группа опустела
+	} {
+		removed, newAdmin, err := r.Groups.RemoveMember(group, step.leaving)
+		must(t, err)
+		if !removed || newAdmin != step.newAdmin {
+			t.Fatalf("RemoveMember(%d) = %v, новый админ %d; ждали %d", step.leaving, removed, newAdmin, step.newAdmin)
+		}
+		if newAdmin != 0 {
+			if role, err := r.Groups.Role(group, newAdmin); err != nil || role != "admin" {
+				t.Fatalf("роль преемника = %q, %v", role, err)
+			}
+		}
+	}
+}
+
+func testReceipts(t *testing.T, r *repository.Repositories) {
+	a, _ := NewUser(t, r, "a")
+	b, bName := NewUser(t, r, "b")
+	c, _ := NewUser(t, r, "c")
+	group, _, err := r.Groups.Create("отметки", "", a, []int{b, c})
+	must(t, err)
+	msg, err := r.Messages.SaveGroupMessage(models.GroupMessage{GroupID: group, SenderID: a, Content: "x"})
+	must(t, err)
+
+	mark, changed, err := r.Receipts.Mark(models.ChatGroup, group, b, msg.ID+100, true)
+	must(t, err)
+	if mark != msg.ID || !changed {
+		t.Fatalf("Mark за последним сообщением = %d, %v", mark, changed)
+	}
+	mark, changed, err = r.Receipts.Mark(models.ChatGroup, group, b, msg.ID, false)
+	must(t, err)
+	if mark != msg.ID || changed {
+		t.Fatalf("доставка после прочтения = %d, %v", mark, changed)
+	}
+	_, _, err = r.Receipts.Mark(models.ChatGroup, group+1000000, b, msg.ID, true)
+	wantErr(t, "Mark не участником", err, repository.ErrNotChatMember)
+
+	_, _, err = r.Receipts.Mark(models.ChatGroup, group, c, msg.ID, false)
+	must(t, err)
+	receipt, err := r.Receipts.GetGroupReceipt(group, msg.ID)
+	must(t, err)
+	if receipt.MemberCount != 2 || receipt.DeliveredCount != 2 || receipt.ReadCount != 1 ||
+		len(receipt.ReadBy) != 1 || receipt.ReadBy[0].Username != bName {
+		t.Fatalf("GetGroupReceipt = %+v", receipt)
+	}
+}
+
+func testEvents(t *testing.T, r *repository.Repositories) {
+	a, _ := NewUser(t, r, "a")
+	b, _ := NewUser(t, r, "b")
+
+	seqs, err := r.Events.Append([]int{a, b, -1}, "x", []byte(`{"n":1}`))
+	must(t, err)
+	if len(seqs) != 2 || seqs[a] != 1 || seqs[b] != 1 {
+		t.Fatalf("Append = %v", seqs)
+	}
+	seqs, err = r.Events.Append([]int{a}, "y", []byte(`{"n":2}`))
+	must(t, err)
+	if seqs[a] != 2 {
+		t.Fatalf("второй Append = %v", seqs)
+	}
+
+	events, err := r.Events.Since(a, 0, 10)
+	must(t, err)
+	if len(events) != 2 || events[0].Type != "x" || events[1].Seq != 2 || string(events[1].Payload) != `{"n":2}` {
+		t.Fatalf("Since = %+v", events)
+	}
+	events, err = r.Events.Since(a, 0, 1)
+	must(t, err)
+	if len(events) != 1 || events[0].Seq != 1 {
+		t.Fatalf("Since с лимитом = %+v", events)
+	}
+
+	current, oldest, err := r.Events.Bounds(a)
+	must(t, err)
+	if current != 2 || oldest != 1 {
+		t.Fatalf("Bounds = %d, %d", current, oldest)
+	}
+	_, _, err = r.Events.Bounds(-1)
+	wantErr(t, "Bounds неизвестного", err, sql.ErrNoRows)
+
+	// после очистки seq не сбрасывается, а лента пуста
+	_, err = r.Events.Prune(time.Now().Add(time.Hour))
+	must(t, err)
+	current, oldest, err = r.Events.Bounds(a)
+	must(t, err)
+	if current != 2 || oldest != 0 {
+		t.Fatalf("Bounds после Prune = %d, %d", current, oldest)
+	}
+}
+
+func testBlocks(t *testing.T, r *repository.Repositories) {
+	a, _ := NewUser(t, r, "a")
+	b, bName := NewUser(t, r, "b")
+
+	must(t, r.Blocks.Block(a, b))
+	must(t, r.Blocks.Block(a, b))
+	for _, pair := range [][2]int{{a, b}, {b, a}} {
+		blocked, err := r.Blocks.IsBlockedBetween(pair[0], pair[1])
+		must(t, err)
+		if !blocked {
+			t.Fatalf("IsBlockedBetween%v = false", pair)
+		}
+	}
+	blockers, err := r.Blocks.BlockersOf(b)
+	must(t, err)
+	if len(blockers) != 1 || !blockers[a] {
+		t.Fatalf("BlockersOf = %v", blockers)
+	}
+	list, err := r.Blocks.ListBlocked(a)
+	must(t, err)
+	if len(list) != 1 || list[0].Username != bName {
+		t.Fatalf("ListBlocked = %+v", list)
+	}
+
+	must(t, r.Blocks.Unblock(a, b))
+	blocked, err := r.Blocks.IsBlockedBetween(b, a)
+	must(t, err)
+	if blocked {
+		t.Fatal("блокировка осталась после Unblock")
+	}
+}
+
+func testReactions(t *testing.T, r *repository.Repositories) {
+	a, aName := NewUser(t, r, "a")
+	b, _ := NewUser(t, r, "b")
+	conv, err := r.Conversations.GetOrCreate(a, b)
+	must(t, err)
+	msg, err := r.Messages.SaveMessage(models.Message{ConversationID: conv, SenderID: a, Content: "x"})
+	must(t, err)
+
+	previous, err := r.Reactions.Set(models.ChatDirect, msg.ID, a, "👍")
+	must(t, err)
+	if previous != "" {
+		t.Fatalf("первая реакция заменила %q", previous)
+	}
+	_, err = r.Reactions.Set(models.ChatDirect, msg.ID, b, "🔥")
+	must(t, err)
+	previous, err = r.Reactions.Set(models.ChatDirect, msg.ID, b, "👍")
+	must(t, err)
+	if previous != "🔥" {
+		t.Fatalf("замена вернула %q", previous)
+	}
+
+	counts, err := r.Reactions.Counts(models.ChatDirect, []int{msg.ID}, b)
+	must(t, err)
+	if got := counts[msg.ID]; len(got) != 1 || got[0].Count != 2 || !got[0].ReactedByMe {
+		t.Fatalf("Counts = %+v", got)
+	}
+	list, err := r.Reactions.List(models.ChatDirect, msg.ID)
+	must(t, err)
+	if len(list) != 2 || list[0].Username != aName {
+		t.Fatalf("List = %+v", list)
+	}
+
+	removed, err := r.Reactions.Remove(models.ChatDirect, msg.ID, a)
+	must(t, err)
+	again, err := r.Reactions.Remove(models.ChatDirect, msg.ID, a)
+	must(t, err)
+	if removed != "👍" || again != "" {
+		t.Fatalf("Remove = %q, повторно %q", removed, again)
+	}
+
+	_, err = r.Reactions.Set(models.ChatDirect, -1, a, "👍")
+	wantErr(t, "Set на несуществующее", err, repository.ErrMessageNotFound)
+	_, _, err = r.Messages.DeleteMessage(models.ChatDirect, msg.ID, a)
+	must(t, err)
+	_, err = r.Reactions.Set(models.ChatDirect, msg.ID, a, "👍")
+	wantErr(t, "Set на удалённое", err, repository.ErrMessageDeleted)
+}
+
+func testPins(t *testing.T, r *repository.Repositories) {
+	a, aName := NewUser(t, r, "a")
+	b, _ := NewUser(t, r, "b")
+	group, _, err := r.Groups.Create("закрепы", "", a, []int{b})
+	must(t, err)
+	var msgs []models.GroupMessage
+	for i := 0; i < 2; i++ {
+		m, err := r.Messages.SaveGroupMessage(models.GroupMessage{GroupID: group, SenderID: b, Content: fmt.Sprint(i)})
+		must(t, err)
+		msgs = append(msgs, m)
+	}
+
+	pinned, err := r.Pins.Pin(models.ChatGroup, group, msgs[0].ID, a)
+	must(t, err)
+	if pinned.MessageID != msgs[0].ID || pinned.PinnedByUsername != aName || pinned.Content != "0" {
+		t.Fatalf("Pin = %+v", pinned)
+	}
+	// без паузы оба закрепа могут получить одно время, и порядок решит ID
+	time.Sleep(10 * time.Millisecond)
+	_, err = r.Pins.Pin(models.ChatGroup, group, msgs[1].ID, a)
+	must(t, err)
+
+	list, err := r.Pins.List(models.ChatGroup, group)
+	must(t, err)
+	if len(list) != 2 || list[0].MessageID != msgs[1].ID {
+		t.Fatalf("List не от последнего закреплённого: %+v", list)
+	}
+
+	// повторное закрепление поднимает наверх
+	time.Sleep(10 * time.Millisecond)
+	_, err = r.Pins.Pin(models.ChatGroup, group, msgs[0].ID, a)
+	must(t, err)
+	latest, err := r.Pins.Latest(models.ChatGroup, []int{group, group + 1000000})
+	must(t, err)
+	if len(latest) != 1 || latest[group].MessageID != msgs[0].ID {
+		t.Fatalf("Latest = %+v", latest)
+	}
+
+	_, err = r.Pins.Pin(models.ChatGroup, group+1000000, msgs[0].ID, a)
+	wantErr(t, "Pin из чужого чата", err, repository.ErrMessageNotFound)
+
+	_, _, err = r.Messages.DeleteMessage(models.ChatGroup, msgs[0].ID, b)
+	must(t, err)
+	_, err = r.Pins.Pin(models.ChatGroup, group, msgs[0].ID, a)
+	wantErr(t, "Pin удалённого", err, repository.ErrMessageDeleted)
+	list, err = r.Pins.List(models.ChatGroup, group)
+	must(t, err)
+	if len(list) != 1 || list[0].MessageID != msgs[1].ID {
+		t.Fatalf("удалённое осталось в закрепах: %+v", list)
+	}
+
+	ok, err := r.Pins.Unpin(models.ChatGroup, msgs[1].ID)
+	must(t, err)
+	again, err := r.Pins.Unpin(models.ChatGroup, msgs[1].ID)
+	must(t, err)
+	if !ok || again {
+		t.Fatalf("Unpin = %v, повторно %v", ok, again)
+	}
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+	"your_project/internal/models"
+)
+
+var (
+	ErrSessionNotFound = errors.New("сессия не найдена или отозвана")
+	// ErrRefreshReused — предъявлен уже заменённый refresh-токен; сессия отозвана
+	ErrRefreshReused = errors.New("refresh-токен использован повторно")
+)
+
+type SessionRepository struct {
+	DB *sql.DB
+}
+
+func (r *SessionRepository) Create(s models.Session, refreshHash string) error {
+	_, err := r.DB.Exec(`
+		INSERT INTO sessions (id, user_id, refresh_token_hash, device_name, ip, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		s.ID, s.UserID, refreshHash, s.DeviceName, s.IP, s.UserAgent, s.ExpiresAt,
+	)
+	return err
+}
+
+// Rotate заменяет refresh-токен сессии на новый. Если предъявлен предыдущий
+// (уже заменённый) токен, сессия отзывается целиком: кто-то из двух
+// владельцев токена — злоумышленник. Возвращает сессию и имя пользователя
+// для нового access-токена.
+func (r *SessionRepository) Rotate(oldHash, newHash string, expiresAt time.Time) (models.Session, string, error) {
+	var s models.Session
+	var username string
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return s, "", err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		SELECT s.id, s.user_id, u.username
+		FROM sessions s JOIN users u ON s.user_id = u.id
+		WHERE s.refresh_token_hash = $1 AND s.revoked_at IS NULL AND s.expires_at > NOW()
+		FOR UPDATE OF s`,
+		oldHash,
+	).Scan(&s.ID, &s.UserID, &username)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(
+			`UPDATE sessions SET revoked_at = NOW()
+			WHERE previous_token_hash = $1 AND revoked_at IS NULL RETURNING id, user_id`,
+			oldHash,
+		).Scan(&s.ID, &s.UserID)
+		if err == nil {
+			if err = tx.Commit(); err != nil {
+				return s, "", err
+			}
+			return s, "", ErrRefreshReused
+		}
+		if err == sql.ErrNoRows {
+			return s, "", ErrSessionNotFound
+		}
+		return s, "", err
+	}
+	if err != nil {
+		return s, "", err
+	}
+
+	if _, err = tx.Exec(`
+		UPDATE sessions SET refresh_token_hash = $1, previous_token_hash = $2,
+			last_used_at = NOW(), expires_at = $3
+		WHERE id = $4`,
+		newHash, oldHash, expiresAt, s.ID,
+	); err != nil {
+		return s, "", err
+	}
+	return s, username, tx.Commit()
+}
+
+// IsActive проверяет, что сессия не отозвана и не истекла
+func (r *SessionRepository) IsActive(sessionID string, userID int) (bool, error) {
+	var active bool
+	err := r.DB.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM sessions
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+		)`,
+		sessionID, userID,
+	).Scan(&active)
+	return active, err
+}
+
+func (r *SessionRepository) ListActive(userID int) ([]models.Session, error) {
+	rows, err := r.DB.Query(`
+		SELECT id, device_name, ip, user_agent, created_at, last_used_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sessions []models.Session
+	for rows.Next() {
+		s := models.Session{UserID: userID}
+		rows.Scan(&s.ID, &s.DeviceName, &s.IP, &s.UserAgent, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt)
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
+
+// Revoke отзывает одну сессию пользователя; false — такой активной сессии нет
+func (r *SessionRepository) Revoke(userID int, sessionID string) (bool, error) {
+	res, err := r.DB.Exec(
+		`UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+		sessionID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// RevokeAll отзывает все сессии пользователя, кроме exceptID (если задан),
+// и возвращает ID отозванных — чтобы закрыть их сокеты
+func (r *SessionRepository) RevokeAll(userID int, exceptID string) ([]string, error) {
+	rows, err := r.DB.Query(`
+		UPDATE sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND id != $2 AND revoked_at IS NULL
+		RETURNING id`,
+		userID, exceptID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
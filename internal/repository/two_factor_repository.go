@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"database/sql"
+)
+
+// TwoFactorState — настройки TOTP пользователя. Secret заполнен и до
+// включения: его выдаёт setup, а enable подтверждает первым кодом.
+type TwoFactorState struct {
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
+
+type TwoFactorRepository struct {
+	DB *sql.DB
+}
+
+func (r *TwoFactorRepository) Get(userID int) (TwoFactorState, error) {
+	var st TwoFactorState
+	err := r.DB.QueryRow(
+		`SELECT COALESCE(totp_secret, ''), totp_enabled, totp_last_step FROM users WHERE id = $1`,
+		userID,
+	).Scan(&st.Secret, &st.Enabled, &st.LastStep)
+	return st, err
+}
+
+// SetPendingSecret сохраняет новый секрет, пока 2FA не включена
+func (r *TwoFactorRepository) SetPendingSecret(userID int, secret string) error {
+	_, err := r.DB.Exec(
+		`UPDATE users SET totp_secret = $1, totp_last_step = 0 WHERE id = $2 AND NOT totp_enabled`,
+		secret, userID,
+	)
+	return err
+}
+
+// UseStep фиксирует шаг принятого кода; false — код этого или более
+// позднего шага уже использовали
+func (r *TwoFactorRepository) UseStep(userID int, step int64) (bool, error) {
+	res, err := r.DB.Exec(
+		`UPDATE users SET totp_last_step = $1 WHERE id = $2 AND totp_last_step < $1`,
+		step, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// Enable включает 2FA и заменяет коды восстановления
+func (r *TwoFactorRepository) Enable(userID int, codeHashes []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`UPDATE users SET totp_enabled = TRUE WHERE id = $1`, userID); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *TwoFactorRepository) Disable(userID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(
+		`UPDATE users SET totp_enabled = FALSE, totp_secret = NULL, totp_last_step = 0 WHERE id = $1`,
+		userID,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, h := range codeHashes {
+		if _, err := tx.Exec(
+			`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`,
+			userID, h,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode гасит код восстановления; false — кода нет или он уже использован
+func (r *TwoFactorRepository) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	res, err := r.DB.Exec(
+		`UPDATE recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, codeHash,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// RemainingRecoveryCodes — сколько неиспользованных кодов осталось
+func (r *TwoFactorRepository) RemainingRecoveryCodes(userID int) (int, error) {
+	var n int
+	err := r.DB.QueryRow(
+		`SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL`,
+		userID,
+	).Scan(&n)
+	return n, err
+}
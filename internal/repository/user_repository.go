@@ -2,17 +2,27 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"time"
 	"your_project/internal/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrEmailTaken    = errors.New("адрес уже используется")
+	ErrUsernameTaken = errors.New("имя пользователя занято")
 )
 
 type UserRepository struct {
 	DB *sql.DB
 }
 
-func (r *UserRepository) CreateUser(user models.User) error {
-	query := `INSERT INTO users (username, password, display_name, user_tag) VALUES ($1, $2, $3, $4)`
-	_, err := r.DB.Exec(query, user.Username, user.Password, user.DisplayName, user.UserTag)
-	return err
+func (r *UserRepository) CreateUser(user models.User) (int, error) {
+	var id int
+	query := `INSERT INTO users (username, password, display_name, user_tag, email) VALUES ($1, $2, $3, $4, NULLIF($5, '')) RETURNING id`
+	err := r.DB.QueryRow(query, user.Username, user.Password, user.DisplayName, user.UserTag, user.Email).Scan(&id)
+	return id, err
 }
 
 func (r *UserRepository) GetUserByUsername(username string) (*models.User, error) {
@@ -22,8 +32,104 @@ func (r *UserRepository) GetUserByUsername(username string) (*models.User, error
 	return user, err
 }
 
+func (r *UserRepository) GetUserByID(id int) (*models.User, error) {
+	user := &models.User{}
+	query := `SELECT id, username, password, COALESCE(display_name,''), COALESCE(user_tag,'') FROM users WHERE id=$1`
+	err := r.DB.QueryRow(query, id).Scan(&user.ID, &user.Username, &user.Password, &user.DisplayName, &user.UserTag)
+	return user, err
+}
+
+// GetUserByVerifiedEmail ищет пользователя по подтверждённому адресу —
+// на неподтверждённый письмо сброса пароля не отправляется
+func (r *UserRepository) GetUserByVerifiedEmail(email string) (*models.User, error) {
+	user := &models.User{}
+	query := `SELECT id, username, email FROM users WHERE LOWER(email) = LOWER($1) AND email_verified_at IS NOT NULL`
+	err := r.DB.QueryRow(query, email).Scan(&user.ID, &user.Username, &user.Email)
+	user.EmailVerified = err == nil
+	return user, err
+}
+
+func (r *UserRepository) GetProfile(id int) (*models.User, error) {
+	u := &models.User{}
+	err := r.DB.QueryRow(
+		`SELECT id, username, COALESCE(display_name,''), COALESCE(user_tag,''), COALESCE(bio,''), COALESCE(avatar_url,''),
+			COALESCE(email,''), email_verified_at IS NOT NULL, last_seen_at, deletion_requested_at
+		FROM users WHERE id=$1`,
+		id,
+	).Scan(&u.ID, &u.Username, &u.DisplayName, &u.UserTag, &u.Bio, &u.AvatarURL,
+		&u.Email, &u.EmailVerified, &u.LastSeenAt, &u.DeletionRequestedAt)
+	return u, err
+}
+
+func (r *UserRepository) UpdateProfile(userID int, displayName, bio, avatarURL string) error {
+	_, err := r.DB.Exec(
+		`UPDATE users SET display_name=$1, bio=$2, avatar_url=$3 WHERE id=$4`,
+		displayName, bio, avatarURL, userID,
+	)
+	return err
+}
+
+// SetEmail меняет адрес; подтверждение старого адреса сбрасывается.
+// Неподтверждённый адрес ни с кем не конфликтует: уникален только
+// подтверждённый, и занятость проверяет MarkEmailVerified.
+func (r *UserRepository) SetEmail(userID int, email string) error {
+	_, err := r.DB.Exec(
+		`UPDATE users SET email = $1, email_verified_at = NULL WHERE id = $2`,
+		email, userID,
+	)
+	return err
+}
+
+// MarkEmailVerified подтверждает адрес, если он всё ещё текущий у
+// пользователя, и снимает неподтверждённые заявки на него у других.
+// ErrEmailTaken — адрес уже подтверждён другим аккаунтом.
+func (r *UserRepository) MarkEmailVerified(userID int, email string) (bool, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		`UPDATE users SET email_verified_at = NOW() WHERE id = $1 AND LOWER(email) = LOWER($2)`,
+		userID, email,
+	)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return false, ErrEmailTaken
+	}
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if _, err = tx.Exec(
+		`UPDATE users SET email = NULL
+		WHERE LOWER(email) = LOWER($1) AND id != $2 AND email_verified_at IS NULL`,
+		email, userID,
+	); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (r *UserRepository) SetPassword(userID int, hash string) error {
+	_, err := r.DB.Exec(`UPDATE users SET password = $1 WHERE id = $2`, hash, userID)
+	return err
+}
+
 func (r *UserRepository) SearchByTag(tag string, currentUserID int) ([]models.User, error) {
-	query := `SELECT id, username, COALESCE(display_name,''), COALESCE(user_tag,'') FROM users WHERE user_tag ILIKE $1 AND id != $2 LIMIT 20`
+	// Пользователи, с которыми есть блокировка в любую сторону, в поиск не попадают
+	query := `
+		SELECT id, username, COALESCE(display_name,''), COALESCE(user_tag,'')
+		FROM users
+		WHERE user_tag ILIKE $1 AND id != $2 AND deleted_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM blocked_users b
+				WHERE (b.user_id = $2 AND b.blocked_user_id = users.id)
+					OR (b.user_id = users.id AND b.blocked_user_id = $2)
+			)
+		LIMIT 20`
 	rows, err := r.DB.Query(query, "%"+tag+"%", currentUserID)
 	if err != nil {
 		return nil, err
@@ -37,3 +143,124 @@ func (r *UserRepository) SearchByTag(tag string, currentUserID int) ([]models.Us
 	}
 	return users, nil
 }
+
+func (r *UserRepository) SetFCMToken(userID int, token string) error {
+	_, err := r.DB.Exec(`UPDATE users SET fcm_token = $1 WHERE id = $2`, token, userID)
+	return err
+}
+
+// FCMToken возвращает push-токен устройства; пустой — push не слать
+func (r *UserRepository) FCMToken(userID int) (string, error) {
+	var token string
+	err := r.DB.QueryRow(`SELECT COALESCE(fcm_token, '') FROM users WHERE id = $1`, userID).Scan(&token)
+	return token, err
+}
+
+// TouchLastSeen фиксирует время ухода пользователя из сети
+func (r *UserRepository) TouchLastSeen(userID int) (time.Time, error) {
+	var lastSeen time.Time
+	err := r.DB.QueryRow(
+		`UPDATE users SET last_seen_at = NOW() WHERE id = $1 RETURNING last_seen_at`, userID,
+	).Scan(&lastSeen)
+	return lastSeen, err
+}
+
+// SetUsername меняет имя для входа; занятость проверяется без учёта регистра
+func (r *UserRepository) SetUsername(userID int, username string) error {
+	var taken bool
+	err := r.DB.QueryRow(
+		`SELECT EXISTS(SELECT 1 FROM users WHERE LOWER(username) = LOWER($1) AND id != $2)`,
+		username, userID,
+	).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrUsernameTaken
+	}
+	_, err = r.DB.Exec(`UPDATE users SET username = $1 WHERE id = $2`, username, userID)
+	// между проверкой и UPDATE имя мог занять кто-то ещё
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		return ErrUsernameTaken
+	}
+	return err
+}
+
+// RequestDeletion планирует удаление аккаунта и возвращает время запроса
+func (r *UserRepository) RequestDeletion(userID int) (time.Time, error) {
+	var at time.Time
+	err := r.DB.QueryRow(
+		`UPDATE users SET deletion_requested_at = COALESCE(deletion_requested_at, NOW())
+		WHERE id = $1 AND deleted_at IS NULL RETURNING deletion_requested_at`,
+		userID,
+	).Scan(&at)
+	return at, err
+}
+
+// CancelDeletion отменяет запланированное удаление; false — отменять нечего
+func (r *UserRepository) CancelDeletion(userID int) (bool, error) {
+	res, err := r.DB.Exec(
+		`UPDATE users SET deletion_requested_at = NULL
+		WHERE id = $1 AND deletion_requested_at IS NOT NULL AND deleted_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// DueForDeletion — аккаунты, у которых истёк срок отмены удаления
+func (r *UserRepository) DueForDeletion(requestedBefore time.Time) ([]int, error) {
+	rows, err := r.DB.Query(
+		`SELECT id FROM users WHERE deletion_requested_at < $1 AND deleted_at IS NULL`,
+		requestedBefore,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// SoftDelete обезличивает аккаунт: строка и сообщения остаются, чтобы у
+// собеседников не ломалась история, но войти в аккаунт и найти его нельзя
+func (r *UserRepository) SoftDelete(userID int) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`
+		UPDATE users SET
+			username = 'deleted_' || id, password = '', display_name = 'Удалённый аккаунт',
+			user_tag = NULL, bio = NULL, avatar_url = NULL, fcm_token = NULL,
+			email = NULL, email_verified_at = NULL,
+			totp_secret = NULL, totp_enabled = FALSE,
+			deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL`,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	for _, q := range []string{
+		`DELETE FROM group_members WHERE user_id = $1`,
+		`DELETE FROM blocked_users WHERE user_id = $1 OR blocked_user_id = $1`,
+		`DELETE FROM recovery_codes WHERE user_id = $1`,
+		`DELETE FROM email_tokens WHERE user_id = $1`,
+		`DELETE FROM user_events WHERE user_id = $1`,
+	} {
+		if _, err := tx.Exec(q, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -1,4 +1,5 @@
--- Таблица заблокированных пользователей
+-- Заблокированные пользователи: блокировка действует в обе стороны
+
 CREATE TABLE IF NOT EXISTS blocked_users (
     id SERIAL PRIMARY KEY,
     user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
@@ -1,5 +1,3 @@
--- Запусти эти миграции в своей PostgreSQL базе данных
-
 -- Таблица групповых чатов
 CREATE TABLE IF NOT EXISTS group_chats (
     id         SERIAL PRIMARY KEY,
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS message_edits;
+
+ALTER TABLE group_messages DROP COLUMN IF EXISTS deleted;
+ALTER TABLE group_messages DROP COLUMN IF EXISTS edited_at;
+
+ALTER TABLE messages DROP COLUMN IF EXISTS deleted;
+ALTER TABLE messages DROP COLUMN IF EXISTS edited_at;
@@ -0,0 +1,18 @@
+-- Редактирование и удаление сообщений
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS deleted BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE group_messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP;
+ALTER TABLE group_messages ADD COLUMN IF NOT EXISTS deleted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- История правок: прежний текст сообщения до каждой правки
+CREATE TABLE IF NOT EXISTS message_edits (
+    id          SERIAL PRIMARY KEY,
+    chat_type   VARCHAR(10) NOT NULL, -- 'direct' (messages) или 'group' (group_messages)
+    message_id  INTEGER NOT NULL,
+    old_content TEXT NOT NULL DEFAULT '',
+    edited_at   TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_edits_message ON message_edits(chat_type, message_id);
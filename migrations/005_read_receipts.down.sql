@@ -0,0 +1,5 @@
+ALTER TABLE group_members DROP COLUMN IF EXISTS last_read_message_id;
+ALTER TABLE group_members DROP COLUMN IF EXISTS last_delivered_message_id;
+
+ALTER TABLE conversation_members DROP COLUMN IF EXISTS last_read_message_id;
+ALTER TABLE conversation_members DROP COLUMN IF EXISTS last_delivered_message_id;
@@ -0,0 +1,34 @@
+-- Отметки доставки и прочтения: для каждого участника хранится ID последнего
+-- доставленного и последнего прочитанного сообщения («прочитано до»)
+--
+-- Существующую историю считаем прочитанной, чтобы счётчики не взлетели.
+-- Делаем это только при добавлении колонок: на базах, где миграцию когда-то
+-- применили вручную, отметки пользователей не сбрасываем.
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'conversation_members' AND column_name = 'last_read_message_id'
+    ) THEN
+        ALTER TABLE conversation_members ADD COLUMN last_delivered_message_id INTEGER NOT NULL DEFAULT 0;
+        ALTER TABLE conversation_members ADD COLUMN last_read_message_id INTEGER NOT NULL DEFAULT 0;
+
+        UPDATE conversation_members cm SET
+            last_delivered_message_id = COALESCE((SELECT MAX(id) FROM messages WHERE conversation_id = cm.conversation_id), 0),
+            last_read_message_id      = COALESCE((SELECT MAX(id) FROM messages WHERE conversation_id = cm.conversation_id), 0);
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'group_members' AND column_name = 'last_read_message_id'
+    ) THEN
+        ALTER TABLE group_members ADD COLUMN last_delivered_message_id INTEGER NOT NULL DEFAULT 0;
+        ALTER TABLE group_members ADD COLUMN last_read_message_id INTEGER NOT NULL DEFAULT 0;
+
+        UPDATE group_members gm SET
+            last_delivered_message_id = COALESCE((SELECT MAX(id) FROM group_messages WHERE group_id = gm.group_id), 0),
+            last_read_message_id      = COALESCE((SELECT MAX(id) FROM group_messages WHERE group_id = gm.group_id), 0);
+    END IF;
+END
+$$;
@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id ON messages(conversation_id);
+CREATE INDEX IF NOT EXISTS idx_group_messages_group_id ON group_messages(group_id);
+
+DROP INDEX IF EXISTS idx_group_messages_group_id_id;
+DROP INDEX IF EXISTS idx_messages_conversation_id_id;
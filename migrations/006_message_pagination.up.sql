@@ -0,0 +1,8 @@
+-- Индексы для курсорной пагинации истории (WHERE chat = ? AND id < ? ORDER BY id)
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation_id_id ON messages(conversation_id, id);
+CREATE INDEX IF NOT EXISTS idx_group_messages_group_id_id ON group_messages(group_id, id);
+
+-- Перекрываются составными индексами выше
+DROP INDEX IF EXISTS idx_messages_conversation_id;
+DROP INDEX IF EXISTS idx_group_messages_group_id;
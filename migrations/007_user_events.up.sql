@@ -0,0 +1,15 @@
+-- Лента событий пользователя для догоняющей синхронизации после переподключения.
+-- seq растёт монотонно в пределах пользователя; счётчик хранится в users.event_seq.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS event_seq BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS user_events (
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    seq        BIGINT NOT NULL,
+    type       VARCHAR(40) NOT NULL,
+    payload    JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (user_id, seq)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_events_created_at ON user_events(created_at);
@@ -0,0 +1,2 @@
+-- Время последнего выхода из сети (обновляется при отключении последнего устройства)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP;
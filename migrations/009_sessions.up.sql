@@ -0,0 +1,19 @@
+-- Сессии устройств: ротируемый refresh-токен (хранится только хэш) и сведения об устройстве
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id                  VARCHAR(32) PRIMARY KEY,
+    user_id             INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refresh_token_hash  VARCHAR(64) NOT NULL UNIQUE,
+    -- предыдущий refresh-токен: его повторное предъявление означает кражу
+    previous_token_hash VARCHAR(64),
+    device_name         TEXT NOT NULL DEFAULT '',
+    ip                  TEXT NOT NULL DEFAULT '',
+    user_agent          TEXT NOT NULL DEFAULT '',
+    created_at          TIMESTAMP DEFAULT NOW(),
+    last_used_at        TIMESTAMP DEFAULT NOW(),
+    expires_at          TIMESTAMP NOT NULL,
+    revoked_at          TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_previous_token_hash ON sessions(previous_token_hash);
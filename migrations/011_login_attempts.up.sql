@@ -0,0 +1,14 @@
+-- Журнал неудачных входов: пользователь видит, кто и откуда подбирал его пароль
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id         SERIAL PRIMARY KEY,
+    -- NULL, если имя пользователя не существует
+    user_id    INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    username   TEXT NOT NULL,
+    ip         TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    reason     VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user ON login_attempts(user_id, created_at DESC);
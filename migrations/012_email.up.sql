@@ -0,0 +1,20 @@
+-- Email пользователя, подтверждение адреса и сброс пароля
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(LOWER(email)) WHERE email IS NOT NULL;
+
+-- Одноразовые токены из писем; хранится только хэш
+CREATE TABLE IF NOT EXISTS email_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose    VARCHAR(10) NOT NULL CHECK (purpose IN ('verify', 'reset')),
+    -- адрес, на который ушло письмо: подтверждается только он
+    email      VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at    TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_tokens_user ON email_tokens(user_id, purpose);
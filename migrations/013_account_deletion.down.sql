@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_deletion_requested;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_requested_at;
@@ -0,0 +1,8 @@
+-- Удаление аккаунта с отсрочкой: запрос можно отменить, пока не истёк срок,
+-- затем аккаунт обезличивается, но строка остаётся ради истории сообщений
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_requested_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_requested ON users(deletion_requested_at)
+    WHERE deletion_requested_at IS NOT NULL AND deleted_at IS NULL;
@@ -0,0 +1,6 @@
+-- Ответы на сообщения: ссылка на цитируемое сообщение того же чата.
+-- Удаление сообщения мягкое, поэтому цитата остаётся заглушкой; SET NULL
+-- срабатывает, только когда строки удаляются целиком (выход из диалога).
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS reply_to_id INTEGER REFERENCES messages(id) ON DELETE SET NULL;
+ALTER TABLE group_messages ADD COLUMN IF NOT EXISTS reply_to_id INTEGER REFERENCES group_messages(id) ON DELETE SET NULL;
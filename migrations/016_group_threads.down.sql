@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_group_messages_thread;
+
+ALTER TABLE group_messages DROP COLUMN IF EXISTS thread_last_reply_at;
+ALTER TABLE group_messages DROP COLUMN IF EXISTS thread_reply_count;
+ALTER TABLE group_messages DROP COLUMN IF EXISTS thread_root_id;
@@ -0,0 +1,9 @@
+-- Ветки в группах: ответы в ветке ссылаются на корневое сообщение основной
+-- ленты и в саму ленту не попадают. У корня храним счётчик ответов и время
+-- последнего, чтобы не считать их при каждой загрузке истории.
+
+ALTER TABLE group_messages ADD COLUMN IF NOT EXISTS thread_root_id INTEGER REFERENCES group_messages(id) ON DELETE CASCADE;
+ALTER TABLE group_messages ADD COLUMN IF NOT EXISTS thread_reply_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE group_messages ADD COLUMN IF NOT EXISTS thread_last_reply_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_group_messages_thread ON group_messages(thread_root_id, id) WHERE thread_root_id IS NOT NULL;
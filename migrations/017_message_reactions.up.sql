@@ -0,0 +1,12 @@
+-- Реакции на сообщения: у пользователя одна реакция на сообщение, новая
+-- заменяет прежнюю. Сообщения лежат в двух таблицах, поэтому, как и в
+-- message_edits, ссылка на сообщение — пара (chat_type, message_id).
+
+CREATE TABLE IF NOT EXISTS message_reactions (
+    chat_type  VARCHAR(10) NOT NULL, -- 'direct' (messages) или 'group' (group_messages)
+    message_id INTEGER NOT NULL,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    emoji      VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_type, message_id, user_id)
+);
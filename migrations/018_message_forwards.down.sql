@@ -0,0 +1,11 @@
+ALTER TABLE group_messages
+    DROP COLUMN IF EXISTS forward_message_id,
+    DROP COLUMN IF EXISTS forward_chat_id,
+    DROP COLUMN IF EXISTS forward_chat_type,
+    DROP COLUMN IF EXISTS forward_sender_id;
+
+ALTER TABLE messages
+    DROP COLUMN IF EXISTS forward_message_id,
+    DROP COLUMN IF EXISTS forward_chat_id,
+    DROP COLUMN IF EXISTS forward_chat_type,
+    DROP COLUMN IF EXISTS forward_sender_id;
@@ -0,0 +1,16 @@
+-- Пересылка: копия сообщения помнит первоисточник — автора, чат и ID
+-- оригинала. forward_chat_type NOT NULL означает, что сообщение переслано.
+-- forward_message_id без внешнего ключа: оригинал может лежать в другой
+-- таблице; автор при удалении аккаунта обнуляется, пометка остаётся.
+
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS forward_sender_id  INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS forward_chat_type  VARCHAR(10),
+    ADD COLUMN IF NOT EXISTS forward_chat_id    INTEGER,
+    ADD COLUMN IF NOT EXISTS forward_message_id INTEGER;
+
+ALTER TABLE group_messages
+    ADD COLUMN IF NOT EXISTS forward_sender_id  INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS forward_chat_type  VARCHAR(10),
+    ADD COLUMN IF NOT EXISTS forward_chat_id    INTEGER,
+    ADD COLUMN IF NOT EXISTS forward_message_id INTEGER;
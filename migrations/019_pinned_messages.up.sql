@@ -0,0 +1,15 @@
+-- Закреплённые сообщения. Как в message_reactions, сообщение задаётся парой
+-- (chat_type, message_id); chat_id хранится, чтобы выбирать закрепы чата
+-- без обхода обеих таблиц сообщений. Закрепы удалённых сообщений не
+-- показываются, строки исчезнувших вместе с диалогом сообщений отсекает JOIN.
+
+CREATE TABLE IF NOT EXISTS pinned_messages (
+    chat_type  VARCHAR(10) NOT NULL, -- 'direct' (messages) или 'group' (group_messages)
+    chat_id    INTEGER NOT NULL,
+    message_id INTEGER NOT NULL,
+    pinned_by  INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    pinned_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chat_type, message_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pinned_messages_chat ON pinned_messages (chat_type, chat_id, pinned_at DESC);
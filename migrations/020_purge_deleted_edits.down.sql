@@ -0,0 +1 @@
+-- Стёртую историю правок не восстановить; откат ничего не делает.
@@ -0,0 +1,10 @@
+-- История правок удалённого сообщения раскрывала бы его текст: удаление
+-- теперь стирает её вместе с сообщением, а здесь чистятся уже удалённые.
+
+DELETE FROM message_edits e
+USING messages m
+WHERE e.chat_type = 'direct' AND m.id = e.message_id AND m.deleted;
+
+DELETE FROM message_edits e
+USING group_messages m
+WHERE e.chat_type = 'group' AND m.id = e.message_id AND m.deleted;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_login_attempts_created;
+ALTER TABLE login_attempts ALTER COLUMN user_id DROP NOT NULL;
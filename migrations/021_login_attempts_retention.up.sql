@@ -0,0 +1,8 @@
+-- Журнал входов — только для владельца аккаунта: попытки с несуществующими
+-- именами никто не увидит, а перебором случайных имён таблицу можно было
+-- раздувать без предела. Старые записи удаляет фоновая очистка по created_at.
+
+DELETE FROM login_attempts WHERE user_id IS NULL;
+ALTER TABLE login_attempts ALTER COLUMN user_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_created ON login_attempts(created_at);
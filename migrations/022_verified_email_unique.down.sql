@@ -0,0 +1,12 @@
+-- Прежний индекс не допускает повторов и среди неподтверждённых адресов:
+-- у неподтверждённых дублей адрес снимается
+UPDATE users u SET email = NULL
+WHERE u.email IS NOT NULL AND u.email_verified_at IS NULL
+    AND EXISTS (
+        SELECT 1 FROM users o
+        WHERE o.id != u.id AND LOWER(o.email) = LOWER(u.email)
+            AND (o.email_verified_at IS NOT NULL OR o.id < u.id)
+    );
+
+DROP INDEX IF EXISTS idx_users_verified_email;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(LOWER(email)) WHERE email IS NOT NULL;
@@ -0,0 +1,8 @@
+-- Уникален только подтверждённый адрес. Иначе любой мог занять чужой email,
+-- указав его без подтверждения, а ответ 409 выдавал, какие адреса
+-- зарегистрированы. Неподтверждённые заявки на адрес снимаются, когда его
+-- подтверждает другой аккаунт.
+
+DROP INDEX IF EXISTS idx_users_email;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_verified_email ON users(LOWER(email))
+    WHERE email IS NOT NULL AND email_verified_at IS NOT NULL;
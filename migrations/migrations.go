@@ -0,0 +1,10 @@
+// Package migrations встраивает SQL-миграции схемы в бинарник.
+//
+// Файлы именуются NNN_название.up.sql / NNN_название.down.sql и применяются
+// по возрастанию номера командой `server migrate`.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS